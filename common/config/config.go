@@ -43,6 +43,67 @@ type Configuration struct {
 	MaxTxInBlock    int                `json:"MaxTransactionInBlock"`
 	MaxHdrSyncReqs  int                `json:"MaxConcurrentSyncHeaderReqs"`
 	TransactionFee  map[string]float64 `json:"TransactionFee"`
+	// The max amount of one asset pending issuance in the transaction pool, 0 means no limit
+	MaxPendingIssuancePerAsset float64 `json:"MaxPendingIssuancePerAsset"`
+	// The max milliseconds to verify one transaction before the pool rejects it, 0 means no limit
+	VerifyTimeout uint `json:"VerifyTimeout"`
+	// Allow a transaction to replace the pooled transactions spending the same inputs by paying more fee
+	EnableRBF bool `json:"EnableRBF"`
+	// Require the replacement to pay a higher fee rate too, not only a higher fee
+	RBFRequireHigherFeeRate bool `json:"RBFRequireHigherFeeRate"`
+	// The seconds between two samples of the transaction pool size, 0 means no sampling
+	PoolDepthSampleInterval uint `json:"PoolDepthSampleInterval"`
+	// The max number of transactions waiting for the transactions they spend from, 0 means they are rejected
+	MaxOrphanTransactions int `json:"MaxOrphanTransactions"`
+	// The metric of the transaction size used for the fee rate, the serialized bytes by default
+	TransactionWeight string `json:"TransactionWeight"`
+	// The max number of transactions in the transaction pool, 0 means no limit
+	MaxPoolSize int `json:"MaxPoolSize"`
+	// The max serialized bytes of the transactions in the transaction pool, 0 means no limit
+	MaxPoolBytes int `json:"MaxPoolBytes"`
+	// The min fee per thousand weight units of the transactions spending inputs, 0 means no minimum
+	MinTxFee float64 `json:"MinTxFee"`
+	// The max seconds without a committed block before the pooled transactions are considered stale, 0 means never
+	MaxBlockGap uint `json:"MaxBlockGap"`
+	// What to do with the stale pooled transactions, "revalidate" (default) or "clear"
+	StalePoolAction string `json:"StalePoolAction"`
+	// Log and count the transactions producing the same outputs as a pooled transaction
+	FlagDuplicateOutputs bool `json:"FlagDuplicateOutputs"`
+	// The serialized size of the transactions a block holds, used to report the pool occupancy
+	MaxBlockBytes int `json:"MaxBlockBytes"`
+	// The milliseconds within which conflicting transactions are resolved by fee only, ignoring RBFRequireHigherFeeRate
+	RBFConcurrentWindow uint `json:"RBFConcurrentWindow"`
+	// The max number of pooled transactions of a type by the type name, e.g. "DataFile", 0 means no limit
+	MaxPoolSizeByType map[string]int `json:"MaxPoolSizeByType"`
+	// Look up the ledger before verifying a transaction to reject the confirmed ones early
+	RejectConfirmedTransactions bool `json:"RejectConfirmedTransactions"`
+	// The window the transaction pool pauses admissions in, in RFC3339 e.g. "2018-01-01T02:00:00Z", empty means none
+	MaintenanceWindowStart string `json:"MaintenanceWindowStart"`
+	MaintenanceWindowEnd   string `json:"MaintenanceWindowEnd"`
+	// The seconds after which the maintenance window repeats, e.g. 86400 for daily, 0 means it doesn't repeat
+	MaintenanceWindowRecurrence uint `json:"MaintenanceWindowRecurrence"`
+	// The confirmations the output of a BookKeeping transaction needs before it can be spent, 0 means no maturity rule
+	MaturityDepth uint32 `json:"MaturityDepth"`
+	// How the transaction pool resolves conflicting transactions: "FirstSeen", "HighestFee" or "RBFOnly", empty follows EnableRBF
+	ConflictPolicy string `json:"ConflictPolicy"`
+	// The seconds a reservation of the transaction pool is kept without being released, 0 means until released
+	ReservationTTL uint `json:"ReservationTTL"`
+	// The min fee per thousand weight units to relay a transaction to the peers, it may be pooled below it
+	MinRelayFee float64 `json:"MinRelayFee"`
+	// The max amount of one asset the transaction pool admits for issuance within IssuanceRateWindow, 0 means no limit
+	IssuanceRateLimit float64 `json:"IssuanceRateLimit"`
+	// The seconds of the sliding window of IssuanceRateLimit
+	IssuanceRateWindow uint `json:"IssuanceRateWindow"`
+	// The max serialized size of the pooled transactions as a number of blocks of MaxBlockBytes, 0 means no limit
+	MaxPoolBlocks int `json:"MaxPoolBlocks"`
+	// The seconds for the min fee rate raised by the evictions of a full transaction pool to halve back toward MinTxFee, 0 means it's not raised
+	MinFeeHalfLife uint `json:"MinFeeHalfLife"`
+	// The addresses whose outputs the transactions admitted to the transaction pool may spend, empty means any
+	AdmissionAllowlist []string `json:"AdmissionAllowlist"`
+	// The addresses whose outputs the transactions admitted to the transaction pool must not spend
+	AdmissionDenylist []string `json:"AdmissionDenylist"`
+	// The seconds a transaction is kept in the transaction pool before it's dropped unmined, 0 means no limit
+	TxnPoolTTL uint `json:"TxnPoolTTL"`
 }
 
 type ConfigFile struct {
@@ -11,23 +11,44 @@ type ErrCoder interface {
 type ErrCode int32
 
 const (
-	ErrNoCode               ErrCode = -2
-	ErrNoError              ErrCode = 0
-	ErrUnknown              ErrCode = -1
-	ErrDuplicatedTx         ErrCode = 1
-	ErrDuplicateInput       ErrCode = 45003
-	ErrAssetPrecision       ErrCode = 45004
-	ErrTransactionBalance   ErrCode = 45005
-	ErrAttributeProgram     ErrCode = 45006
-	ErrTransactionContracts ErrCode = 45007
-	ErrTransactionPayload   ErrCode = 45008
-	ErrDoubleSpend          ErrCode = 45009
-	ErrTxHashDuplicate      ErrCode = 45010
-	ErrStateUpdaterVaild    ErrCode = 45011
-	ErrSummaryAsset         ErrCode = 45012
-	ErrLockedAsset          ErrCode = 45013
-	ErrDuplicateLockAsset   ErrCode = 45014
-	ErrXmitFail             ErrCode = 45015
+	ErrNoCode                     ErrCode = -2
+	ErrNoError                    ErrCode = 0
+	ErrUnknown                    ErrCode = -1
+	ErrDuplicatedTx               ErrCode = 1
+	ErrDuplicateInput             ErrCode = 45003
+	ErrAssetPrecision             ErrCode = 45004
+	ErrTransactionBalance         ErrCode = 45005
+	ErrAttributeProgram           ErrCode = 45006
+	ErrTransactionContracts       ErrCode = 45007
+	ErrTransactionPayload         ErrCode = 45008
+	ErrDoubleSpend                ErrCode = 45009
+	ErrTxHashDuplicate            ErrCode = 45010
+	ErrStateUpdaterVaild          ErrCode = 45011
+	ErrSummaryAsset               ErrCode = 45012
+	ErrLockedAsset                ErrCode = 45013
+	ErrDuplicateLockAsset         ErrCode = 45014
+	ErrXmitFail                   ErrCode = 45015
+	ErrSyncing                    ErrCode = 45016
+	ErrVerifyTimeout              ErrCode = 45017
+	ErrPoolFrozen                 ErrCode = 45018
+	ErrInvalidTransaction         ErrCode = 45019
+	ErrUnknownAsset               ErrCode = 45020
+	ErrOrphanTransaction          ErrCode = 45021
+	ErrInsufficientReplacementFee ErrCode = 45022
+	ErrInsufficientFee            ErrCode = 45023
+	ErrPoolFull                   ErrCode = 45024
+	ErrNonStandard                ErrCode = 45025
+	ErrTypeLimitReached           ErrCode = 45026
+	ErrAlreadyConfirmed           ErrCode = 45027
+	ErrDataIntegrity              ErrCode = 45028
+	ErrNegativeFee                ErrCode = 45029
+	ErrImmatureSpend              ErrCode = 45030
+	ErrInvalidReference           ErrCode = 45031
+	ErrIssuanceRateLimited        ErrCode = 45032
+	ErrAssetLocked                ErrCode = 45033
+	ErrDenied                     ErrCode = 45034
+	ErrPoolUnavailable            ErrCode = 45036
+	ErrExpired                    ErrCode = 45037
 )
 
 func (err ErrCode) Error() string {
@@ -66,6 +87,48 @@ func (err ErrCode) Error() string {
 		return "duplicate locking asset transaction detected"
 	case ErrXmitFail:
 		return "transmit error"
+	case ErrSyncing:
+		return "node is syncing blocks"
+	case ErrVerifyTimeout:
+		return "transaction verification timeout"
+	case ErrPoolFrozen:
+		return "transaction pool is not accepting transactions"
+	case ErrInvalidTransaction:
+		return "invalid transaction"
+	case ErrUnknownAsset:
+		return "asset is not registered"
+	case ErrOrphanTransaction:
+		return "transaction is waiting for the transactions it spends from"
+	case ErrInsufficientReplacementFee:
+		return "replacement transaction fee is insufficient"
+	case ErrInsufficientFee:
+		return "transaction fee rate is lower than the minimum"
+	case ErrPoolFull:
+		return "transaction pool is full"
+	case ErrNonStandard:
+		return "transaction outputs rejected by the output policy"
+	case ErrTypeLimitReached:
+		return "transaction pool is full for the transaction type"
+	case ErrAlreadyConfirmed:
+		return "transaction is already confirmed"
+	case ErrDataIntegrity:
+		return "ledger data is inconsistent"
+	case ErrNegativeFee:
+		return "transaction fee is negative"
+	case ErrImmatureSpend:
+		return "transaction spends an immature reward output"
+	case ErrInvalidReference:
+		return "transaction input references a missing output"
+	case ErrIssuanceRateLimited:
+		return "issuance of the asset exceeds the rate limit"
+	case ErrAssetLocked:
+		return "transaction spends an asset locked by a pending LockAsset"
+	case ErrDenied:
+		return "transaction spends an output of an address denied admission"
+	case ErrPoolUnavailable:
+		return "transaction store is unavailable, try again later"
+	case ErrExpired:
+		return "transaction expiry has already passed"
 	}
 
 	return fmt.Sprintf("Unknown error? Error code = %d", err)
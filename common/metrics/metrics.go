@@ -0,0 +1,89 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const (
+	Counter   = "counter"
+	Gauge     = "gauge"
+	Histogram = "histogram"
+)
+
+//one sample in the prometheus data model. the samples of a histogram are
+//named with the _bucket, _sum and _count suffixes of the histogram name.
+type Metric struct {
+	Name   string
+	Help   string
+	Type   string
+	Labels map[string]string
+	Value  float64
+}
+
+//the source of a group of metrics, collected on every Gather
+type Collector interface {
+	Collect() []Metric
+}
+
+var (
+	lock       sync.Mutex
+	collectors []Collector
+)
+
+//register the collector to be gathered
+func Register(c Collector) {
+	lock.Lock()
+	defer lock.Unlock()
+	collectors = append(collectors, c)
+}
+
+//collect the metrics of all the registered collectors
+func Gather() []Metric {
+	lock.Lock()
+	registered := append([]Collector(nil), collectors...)
+	lock.Unlock()
+	metrics := []Metric{}
+	for _, c := range registered {
+		metrics = append(metrics, c.Collect()...)
+	}
+	return metrics
+}
+
+//write the metrics in the prometheus text format
+func WriteText(w io.Writer, metrics []Metric) {
+	described := make(map[string]bool)
+	for _, m := range metrics {
+		family := m.Name
+		if m.Type == Histogram {
+			for _, suffix := range []string{"_bucket", "_sum", "_count"} {
+				family = strings.TrimSuffix(family, suffix)
+			}
+		}
+		if !described[family] {
+			described[family] = true
+			fmt.Fprintf(w, "# HELP %s %s\n", family, m.Help)
+			fmt.Fprintf(w, "# TYPE %s %s\n", family, m.Type)
+		}
+		fmt.Fprintf(w, "%s%s %v\n", m.Name, formatLabels(m.Labels), m.Value)
+	}
+}
+
+func formatLabels(labels map[string]string) string {
+	if len(labels) == 0 {
+		return ""
+	}
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	pairs := make([]string, 0, len(names))
+	for _, name := range names {
+		pairs = append(pairs, fmt.Sprintf("%s=%q", name, labels[name]))
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
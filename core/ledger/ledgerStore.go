@@ -2,13 +2,24 @@ package ledger
 
 import (
 	. "IPT/common"
+	. "IPT/common/errors"
 	"IPT/core/account"
 	. "IPT/core/asset"
 	tx "IPT/core/transaction"
 	"IPT/crypto"
 	"IPT/contracts/states"
+	"errors"
 )
 
+// ErrUnspentNotFound is returned by ContainsUnspent when no output of the
+// transaction is left unspent, any other error means the store failed to read it.
+var ErrUnspentNotFound = errors.New("unspent outputs not found")
+
+// IsUnspentNotFound tells weather the error of ContainsUnspent is a store miss.
+func IsUnspentNotFound(err error) bool {
+	return RootErr(err) == ErrUnspentNotFound
+}
+
 // ILedgerStore provides func with store package.
 type ILedgerStore interface {
 	//TODO: define the state store func
@@ -24,6 +35,7 @@ type ILedgerStore interface {
 	GetHeader(hash Uint256) (*Header, error)
 
 	GetTransaction(hash Uint256) (*tx.Transaction, error)
+	GetTransactionHeight(hash Uint256) (uint32, error)
 
 	SaveAsset(assetid Uint256, asset *Asset) error
 	GetAsset(hash Uint256) (*Asset, error)
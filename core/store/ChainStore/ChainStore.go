@@ -569,6 +569,9 @@ func (bd *ChainStore) GetTransaction(hash Uint256) (*tx.Transaction, error) {
 	t := new(tx.Transaction)
 	err := bd.getTx(t, hash)
 
+	if err != nil && err.Error() == ErrDBNotFound.Error() {
+		return nil, tx.ErrTxNotFound
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -576,6 +579,19 @@ func (bd *ChainStore) GetTransaction(hash Uint256) (*tx.Transaction, error) {
 	return t, nil
 }
 
+func (bd *ChainStore) GetTransactionHeight(hash Uint256) (uint32, error) {
+	prefix := []byte{byte(DATA_Transaction)}
+	tHash, err_get := bd.st.Get(append(prefix, hash.ToArray()...))
+	if err_get != nil {
+		return 0, err_get
+	}
+
+	r := bytes.NewReader(tHash)
+
+	// get height
+	return serialization.ReadUint32(r)
+}
+
 func (bd *ChainStore) getTx(tx *tx.Transaction, hash Uint256) error {
 	prefix := []byte{byte(DATA_Transaction)}
 	tHash, err_get := bd.st.Get(append(prefix, hash.ToArray()...))
@@ -1459,6 +1475,9 @@ func (bd *ChainStore) ContainsUnspent(txid Uint256, index uint16) (bool, error)
 	unspentPrefix := []byte{byte(IX_Unspent)}
 	unspentValue, err_get := bd.st.Get(append(unspentPrefix, txid.ToArray()...))
 
+	if err_get != nil && err_get.Error() == ErrDBNotFound.Error() {
+		return false, ErrUnspentNotFound
+	}
 	if err_get != nil {
 		return false, err_get
 	}
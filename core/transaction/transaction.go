@@ -284,13 +284,18 @@ func (tx *Transaction) DeserializeUnsignedWithoutType(r io.Reader) error {
 }
 
 func (tx *Transaction) GetProgramHashes() ([]Uint160, error) {
+	return tx.GetProgramHashesWithStore(TxStore)
+}
+
+//GetProgramHashesWithStore gets the program hashes resolving the references by the store
+func (tx *Transaction) GetProgramHashesWithStore(store ILedgerStore) ([]Uint160, error) {
 	if tx == nil {
 		return []Uint160{}, errors.New("[Transaction],GetProgramHashes transaction is nil.")
 	}
 	hashs := []Uint160{}
 	uniqHashes := []Uint160{}
 	// add inputUTXO's transaction
-	referenceWithUTXO_Output, err := tx.GetReference()
+	referenceWithUTXO_Output, err := tx.GetReferenceWithStore(store)
 	if err != nil {
 		return nil, NewDetailErr(err, ErrNoCode, "[Transaction], GetProgramHashes failed.")
 	}
@@ -328,7 +333,7 @@ func (tx *Transaction) GetProgramHashes() ([]Uint160, error) {
 			return nil, NewDetailErr(err, ErrNoCode, "[Transaction], GetTransactionResults failed.")
 		}
 		for k := range result {
-			tx, err := TxStore.GetTransaction(k)
+			tx, err := store.GetTransaction(k)
 			if err != nil {
 				return nil, NewDetailErr(err, ErrNoCode, fmt.Sprintf("[Transaction], GetTransaction failed With AssetID:=%x", k))
 			}
@@ -451,6 +456,11 @@ func (tx *Transaction) Verify() error {
 }
 
 func (tx *Transaction) GetReference() (map[*UTXOTxInput]*TxOutput, error) {
+	return tx.GetReferenceWithStore(TxStore)
+}
+
+//GetReferenceWithStore gets the referred outputs from the store instead of TxStore
+func (tx *Transaction) GetReferenceWithStore(store ILedgerStore) (map[*UTXOTxInput]*TxOutput, error) {
 	if tx.TxType == RegisterAsset {
 		return nil, nil
 	}
@@ -458,19 +468,27 @@ func (tx *Transaction) GetReference() (map[*UTXOTxInput]*TxOutput, error) {
 	reference := make(map[*UTXOTxInput]*TxOutput)
 	// Key index，v UTXOInput
 	for _, utxo := range tx.UTXOInputs {
-		transaction, err := TxStore.GetTransaction(utxo.ReferTxID)
+		transaction, err := store.GetTransaction(utxo.ReferTxID)
 		if err != nil {
 			return nil, NewDetailErr(err, ErrNoCode, "[Transaction], GetReference failed.")
 		}
 		index := utxo.ReferTxOutputIndex
+		if int(index) >= len(transaction.Outputs) {
+			return nil, NewDetailErr(errors.New("output index out of range"), ErrNoCode, "[Transaction], GetReference failed.")
+		}
 		reference[utxo] = transaction.Outputs[index]
 	}
 	return reference, nil
 }
 func (tx *Transaction) GetTransactionResults() (TransactionResult, error) {
+	return tx.GetTransactionResultsWithStore(TxStore)
+}
+
+//GetTransactionResultsWithStore gets the results resolving the references by the store
+func (tx *Transaction) GetTransactionResultsWithStore(store ILedgerStore) (TransactionResult, error) {
 	result := make(map[Uint256]Fixed64)
 	outputResult := tx.GetMergedAssetIDValueFromOutputs()
-	InputResult, err := tx.GetMergedAssetIDValueFromReference()
+	InputResult, err := tx.getMergedAssetIDValueFromReference(store)
 	if err != nil {
 		return nil, err
 	}
@@ -504,7 +522,11 @@ func (tx *Transaction) GetMergedAssetIDValueFromOutputs() TransactionResult {
 }
 
 func (tx *Transaction) GetMergedAssetIDValueFromReference() (TransactionResult, error) {
-	reference, err := tx.GetReference()
+	return tx.getMergedAssetIDValueFromReference(TxStore)
+}
+
+func (tx *Transaction) getMergedAssetIDValueFromReference(store ILedgerStore) (TransactionResult, error) {
+	reference, err := tx.GetReferenceWithStore(store)
 	if err != nil {
 		return nil, err
 	}
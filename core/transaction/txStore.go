@@ -2,8 +2,19 @@ package transaction
 
 import (
 . "IPT/common"
+. "IPT/common/errors"
+"errors"
 )
 
+// ErrTxNotFound is returned by GetTransaction when the store doesn't have the
+// transaction, any other error means the store failed to read it.
+var ErrTxNotFound = errors.New("transaction not found")
+
+// IsTxNotFound tells weather the error of GetTransaction is a store miss.
+func IsTxNotFound(err error) bool {
+	return RootErr(err) == ErrTxNotFound
+}
+
 // ILedgerStore provides func with store package.
 type ILedgerStore interface {
 	GetTransaction(hash Uint256) (*Transaction, error)
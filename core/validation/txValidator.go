@@ -17,6 +17,15 @@ import (
 )
 
 func VerifyTransaction(txn *tx.Transaction) ErrCode {
+	if errCode := VerifyTransactionStructure(txn); errCode != ErrNoError {
+		return errCode
+	}
+	return VerifyTransactionReferences(txn, tx.TxStore)
+}
+
+// VerifyTransactionStructure verifys a transaction without the outputs it spends,
+// e.g. before the transactions it spends are known
+func VerifyTransactionStructure(txn *tx.Transaction) ErrCode {
 
 	if err := CheckDuplicateInput(txn); err != nil {
 		log.Warn("[VerifyTransaction],", err)
@@ -28,24 +37,31 @@ func VerifyTransaction(txn *tx.Transaction) ErrCode {
 		return ErrAssetPrecision
 	}
 
-	if err := CheckTransactionBalance(txn); err != nil {
+	if err := CheckAttributeProgram(txn); err != nil {
 		log.Warn("[VerifyTransaction],", err)
-		return ErrTransactionBalance
+		return ErrAttributeProgram
 	}
 
-	if err := CheckAttributeProgram(txn); err != nil {
+	if err := CheckTransactionPayload(txn); err != nil {
 		log.Warn("[VerifyTransaction],", err)
-		return ErrAttributeProgram
+		return ErrTransactionPayload
 	}
 
-	if err := CheckTransactionContracts(txn); err != nil {
+	return ErrNoError
+}
+
+// VerifyTransactionReferences verifys the balance and the programs of a transaction
+// with the outputs it spends, which are resolved by the store
+func VerifyTransactionReferences(txn *tx.Transaction, store tx.ILedgerStore) ErrCode {
+
+	if err := checkTransactionBalance(txn, store); err != nil {
 		log.Warn("[VerifyTransaction],", err)
-		return ErrTransactionContracts
+		return ErrTransactionBalance
 	}
 
-	if err := CheckTransactionPayload(txn); err != nil {
+	if err := checkTransactionContracts(txn, store); err != nil {
 		log.Warn("[VerifyTransaction],", err)
-		return ErrTransactionPayload
+		return ErrTransactionContracts
 	}
 
 	return ErrNoError
@@ -127,6 +143,10 @@ func VerifyTransactionWithBlock(TxPool []*tx.Transaction) error {
 }
 
 func CheckLockedAsset(txn *tx.Transaction, ledger *ledger.Ledger) error {
+	return checkLockedAsset(txn, ledger, tx.TxStore)
+}
+
+func checkLockedAsset(txn *tx.Transaction, ledger *ledger.Ledger, store tx.ILedgerStore) error {
 	// onlu check locked asset for transfer transaction
 	if txn.TxType != tx.TransferAsset {
 		return nil
@@ -134,7 +154,7 @@ func CheckLockedAsset(txn *tx.Transaction, ledger *ledger.Ledger) error {
 
 	// get spend asset amount for each program hash and asset ID pair
 	result := make(map[Uint160]map[Uint256]Fixed64)
-	inputAsset, err := txn.GetReference()
+	inputAsset, err := txn.GetReferenceWithStore(store)
 	if err != nil {
 		return err
 	}
@@ -192,6 +212,40 @@ func VerifyTransactionWithLedger(txn *tx.Transaction, ledger *ledger.Ledger) Err
 	return ErrNoError
 }
 
+// VerifyTransactionWithLedgerAndStore verifys a transaction with the ledger and the outputs
+// it spends, which are resolved by the store. the inputs spending transactions which are not
+// in the ledger are not checked for double spend, it's up to the store holding them, e.g.
+// the txn pool.
+func VerifyTransactionWithLedgerAndStore(txn *tx.Transaction, ledger *ledger.Ledger, store tx.ILedgerStore) ErrCode {
+
+	if errCode := VerifyTransactionReferences(txn, store); errCode != ErrNoError {
+		return errCode
+	}
+
+	if exist := ledger.Store.IsTxHashDuplicate(txn.Hash()); exist {
+		log.Info("[VerifyTransactionWithLedgerAndStore] duplicated transaction detected.")
+		return ErrTxHashDuplicate
+	}
+
+	confirmed := new(tx.Transaction)
+	for _, input := range txn.UTXOInputs {
+		if ledger.Store.IsTxHashDuplicate(input.ReferTxID) {
+			confirmed.UTXOInputs = append(confirmed.UTXOInputs, input)
+		}
+	}
+	if IsDoubleSpend(confirmed, ledger) {
+		log.Info("[VerifyTransactionWithLedgerAndStore] double spend checking failed.")
+		return ErrDoubleSpend
+	}
+
+	if err := checkLockedAsset(txn, ledger, store); err != nil {
+		log.Info("[VerifyTransactionWithLedgerAndStore] .")
+		return ErrLockedAsset
+	}
+
+	return ErrNoError
+}
+
 //validate the transaction of duplicate UTXO input
 func CheckDuplicateInput(tx *tx.Transaction) error {
 	if len(tx.UTXOInputs) == 0 {
@@ -251,6 +305,10 @@ func CheckAssetPrecision(Tx *tx.Transaction) error {
 }
 
 func CheckTransactionBalance(Tx *tx.Transaction) error {
+	return checkTransactionBalance(Tx, tx.TxStore)
+}
+
+func checkTransactionBalance(Tx *tx.Transaction, store tx.ILedgerStore) error {
 	for _, v := range Tx.Outputs {
 		if v.Value <= Fixed64(0) {
 			return errors.New("Invalid transaction UTXO output.")
@@ -262,7 +320,7 @@ func CheckTransactionBalance(Tx *tx.Transaction) error {
 		}
 		return nil
 	}
-	results, err := Tx.GetTransactionResults()
+	results, err := Tx.GetTransactionResultsWithStore(store)
 	if err != nil {
 		return err
 	}
@@ -295,7 +353,11 @@ func CheckAttributeProgram(Tx *tx.Transaction) error {
 }
 
 func CheckTransactionContracts(Tx *tx.Transaction) error {
-	flag, err := VerifySignableData(Tx)
+	return checkTransactionContracts(Tx, tx.TxStore)
+}
+
+func checkTransactionContracts(Tx *tx.Transaction, store tx.ILedgerStore) error {
+	flag, err := VerifySignableData(storedTransaction{Tx, store})
 	if flag && err == nil {
 		return nil
 	} else {
@@ -303,6 +365,17 @@ func CheckTransactionContracts(Tx *tx.Transaction) error {
 	}
 }
 
+// storedTransaction is the signable data of a transaction whose program hashes are
+// resolved by the store instead of TxStore
+type storedTransaction struct {
+	*tx.Transaction
+	store tx.ILedgerStore
+}
+
+func (s storedTransaction) GetProgramHashes() ([]Uint160, error) {
+	return s.Transaction.GetProgramHashesWithStore(s.store)
+}
+
 func checkAmountPrecise(amount Fixed64, precision byte) bool {
 	return amount.GetData()%int64(math.Pow(10, 8-float64(precision))) != 0
 }
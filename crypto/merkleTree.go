@@ -109,3 +109,49 @@ func ComputeRoot(hashes []Uint256) (Uint256, error) {
 	tree, _ := NewMerkleTree(hashes)
 	return tree.Root.Hash, nil
 }
+
+//the sibling hash on the path from a leaf to the root
+type MerklePathNode struct {
+	Hash Uint256
+	Left bool // the sibling is the left child
+}
+
+//get the path proving the hash at the index is a leaf of the MerkleTree of
+//the hashes, the path is empty when there is only one hash.
+func MerkleProof(hashes []Uint256, index int) ([]MerklePathNode, error) {
+	if index < 0 || index >= len(hashes) {
+		return nil, NewDetailErr(errors.New("MerkleProof index out of range error."), ErrNoCode, "")
+	}
+	path := []MerklePathNode{}
+	level := hashes
+	for len(level) > 1 {
+		if index%2 == 1 {
+			path = append(path, MerklePathNode{Hash: level[index-1], Left: true})
+		} else if index+1 < len(level) {
+			path = append(path, MerklePathNode{Hash: level[index+1]})
+		} else {
+			//the last node of an odd level is paired with itself
+			path = append(path, MerklePathNode{Hash: level[index]})
+		}
+		next := []Uint256{}
+		for _, node := range levelUp(generateLeaves(level)) {
+			next = append(next, node.Hash)
+		}
+		level = next
+		index /= 2
+	}
+	return path, nil
+}
+
+//check weather the path leads from the leaf to the root
+func VerifyMerkleProof(leaf Uint256, path []MerklePathNode, root Uint256) bool {
+	hash := leaf
+	for _, node := range path {
+		if node.Left {
+			hash = DOUBLE_SHA256([]Uint256{node.Hash, hash})
+		} else {
+			hash = DOUBLE_SHA256([]Uint256{hash, node.Hash})
+		}
+	}
+	return hash == root
+}
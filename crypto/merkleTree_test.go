@@ -24,3 +24,84 @@ func TestHash(t *testing.T) {
 	fmt.Printf("[Root Hash]:%x\n", x)
 
 }
+
+func testHashes(n int) []Uint256 {
+	var hashes []Uint256
+	for i := 0; i < n; i++ {
+		hashes = append(hashes, Uint256(sha256.Sum256([]byte{byte(i)})))
+	}
+	return hashes
+}
+
+func TestMerkleProof(t *testing.T) {
+	tests := []struct {
+		name   string
+		leaves int
+		path   int // length of the path of every leaf
+	}{
+		{"single leaf", 1, 0},
+		{"two leaves", 2, 1},
+		{"odd leaves", 3, 2},
+		{"odd leaves paired at every level", 5, 3},
+		{"odd leaves paired with themselves", 7, 3},
+	}
+	for _, test := range tests {
+		hashes := testHashes(test.leaves)
+		root, _ := ComputeRoot(hashes)
+		for i := range hashes {
+			path, err := MerkleProof(hashes, i)
+			if err != nil {
+				t.Fatalf("%s: proof of leaf %d failed: %v", test.name, i, err)
+			}
+			if len(path) != test.path {
+				t.Fatalf("%s: expected a path of %d nodes for leaf %d, got %d", test.name, test.path, i, len(path))
+			}
+			if !VerifyMerkleProof(hashes[i], path, root) {
+				t.Fatalf("%s: expected the proof of leaf %d verified", test.name, i)
+			}
+		}
+	}
+
+	for _, index := range []int{-1, 3, 4} {
+		if _, err := MerkleProof(testHashes(3), index); err == nil {
+			t.Fatalf("expected index %d out of range", index)
+		}
+	}
+	if _, err := MerkleProof(nil, 0); err == nil {
+		t.Fatal("expected no proof without hashes")
+	}
+}
+
+func TestVerifyMerkleProofTampered(t *testing.T) {
+	hashes := testHashes(5)
+	root, _ := ComputeRoot(hashes)
+	path, _ := MerkleProof(hashes, 2)
+	tamper := func(f func(path []MerklePathNode) []MerklePathNode) []MerklePathNode {
+		tampered := make([]MerklePathNode, len(path))
+		copy(tampered, path)
+		return f(tampered)
+	}
+	tests := []struct {
+		name string
+		leaf Uint256
+		path []MerklePathNode
+	}{
+		{"sibling hash changed", hashes[2], tamper(func(p []MerklePathNode) []MerklePathNode {
+			p[0].Hash = hashes[4]
+			return p
+		})},
+		{"sibling side flipped", hashes[2], tamper(func(p []MerklePathNode) []MerklePathNode {
+			p[1].Left = !p[1].Left
+			return p
+		})},
+		{"node dropped", hashes[2], tamper(func(p []MerklePathNode) []MerklePathNode {
+			return p[:len(p)-1]
+		})},
+		{"other leaf", hashes[3], path},
+	}
+	for _, test := range tests {
+		if VerifyMerkleProof(test.leaf, test.path, root) {
+			t.Fatalf("%s: expected the proof rejected", test.name)
+		}
+	}
+}
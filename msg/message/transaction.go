@@ -32,12 +32,20 @@ func (msg trn) Handle(node Noder) error {
 	log.Debug()
 	log.Debug("RX Transaction message")
 	tx := &msg.txn
+	if node.LocalNode().WasRecentlyConfirmed(tx.Hash()) {
+		log.Debug("RX Transaction message of confirmed transaction", tx.Hash())
+		return nil
+	}
 	if !node.LocalNode().ExistedID(tx.Hash()) {
 		if errCode := node.LocalNode().AppendTxnPool(&(msg.txn), true); errCode != ErrNoError {
 			return errors.New("[message] VerifyTransaction failed when AppendTxnPool.")
 		}
-		node.LocalNode().Relay(node, tx)
-		log.Info("Relay transaction")
+		if node.LocalNode().ShouldRelay(tx) {
+			node.LocalNode().Relay(node, tx)
+			log.Info("Relay transaction")
+		} else {
+			log.Info("Transaction below the min relay fee not relayed", tx.Hash())
+		}
 		node.LocalNode().IncRxTxnCnt()
 		log.Debug("RX Transaction message hash", msg.txn.Hash())
 		log.Debug("RX Transaction message type", msg.txn.TxType)
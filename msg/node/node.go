@@ -4,6 +4,7 @@ import (
 	. "IPT/common"
 	. "IPT/common/config"
 	"IPT/common/log"
+	"IPT/common/metrics"
 	"IPT/core/ledger"
 	"IPT/core/transaction"
 	"IPT/crypto"
@@ -179,7 +180,9 @@ func InitNode(pubKey *crypto.PubKey) Noder {
 	n.nbrNodes.init()
 	n.local = n
 	n.publicKey = pubKey
-	n.TXNPool.init()
+	n.TXNPool.init(WithSyncState(n.IsSyncing))
+	n.TXNPool.Start()
+	metrics.Register(&n.TXNPool)
 	n.eventQueue.init()
 	n.idCache.init()
 	n.cachedHashes = make([]Uint256, 0)
@@ -421,6 +424,16 @@ func (node *node) WaitForSyncBlkFinish() {
 		<-time.After(2 * time.Second)
 	}
 }
+
+//the blocks are behind the headers while syncing
+func (node *node) IsSyncing() bool {
+	if ledger.DefaultLedger == nil {
+		return true
+	}
+	headerHeight := ledger.DefaultLedger.Store.GetHeaderHeight()
+	return ledger.DefaultLedger.Blockchain.BlockHeight < headerHeight
+}
+
 func (node *node) WaitForFourPeersStart() {
 	for {
 		log.Debug("WaitForFourPeersStart...")
@@ -9,84 +9,487 @@ import (
 	"IPT/core/transaction/payload"
 	va "IPT/core/validation"
 	. "IPT/common/errors"
+	"context"
 	"errors"
 	"fmt"
+	"math"
+	"sort"
+	"strconv"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
 type TXNPool struct {
 	sync.RWMutex
-	txnCnt        uint64                                      // count
-	txnList       map[common.Uint256]*transaction.Transaction // transaction which have been verifyed will put into this map
-	issueSummary  map[common.Uint256]common.Fixed64           // transaction which pass the verify will summary the amout to this map
-	inputUTXOList map[string]*transaction.Transaction         // transaction which pass the verify will add the UTXO to this map
-	lockAssetList map[string]struct{}                         // keep only one copy for each program hash and asset ID pair
+	commitLock      sync.Mutex                                           // serialize the admissions and the cleaning of committed blocks
+	txnCnt          uint64                                               // count
+	txnList         map[common.Uint256]*txnEntry                         // transaction which have been verifyed will put into this map
+	typeCounts      map[transaction.TransactionType]int                  // number of the transactions in txnList by type
+	txnBytes        int                                                  // serialized size of the transactions in txnList
+	issueSummary    map[common.Uint256]common.Fixed64                    // transaction which pass the verify will summary the amout to this map
+	inputUTXOList   map[transaction.UTXOTxInput]*transaction.Transaction // transaction which pass the verify will add the UTXO to this map
+	lockAssetList   map[string]struct{}                                  // keep only one copy for each program hash and asset ID pair
+	tagList         map[common.Uint256]map[string]struct{}               // tags attached to the pooled transactions by tooling
+	outputSets      map[common.Uint256]common.Uint256                    // the first pooled transaction producing each set of outputs
+	tiebreakKey     TiebreakKey                                          // order of the transactions with equal fee rates
+	isSyncing       func() bool                                          // report whether the node is still catching up with the chain
+	frozen          uint32                                               // set by Freeze to stop admissions, accessed atomically
+	feeCalculator   FeeCalculator                                        // compute the fee of the transactions
+	verifier        Verifier                                             // verify the transactions before they are pooled
+	outputPolicy    func(*transaction.Transaction) error                 // standardness check of the outputs of the relayed transactions
+	admissions      AdmissionCounts                                      // updated atomically
+	rejections      rejectionCounts                                      // count of the AppendTxnPool errors by reason
+	verifyDurations durationHistogram                                    // time spent in the verifier
+	verifySlots     chan struct{}                                        // held by the verifications running with a timeout
+	depthSamples    depthHistory                                         // recent samples of the pool size
+	clock           poolClock                                            // time source of the pool
+	quit            chan struct{}                                        // closed by Shutdown to stop the background goroutines
+	lastBlockTime   time.Duration                                        // clock.Elapsed when the last block was committed, or the pool started
+	tasks           scheduler                                            // periodic maintenance tasks run after Start
+	workers         sync.WaitGroup                                       // background goroutines started by Start
+	recentConfirmed recentHashes                                         // the transactions confirmed by the recent blocks
+	inputHeights    inputHeightCache                                     // block heights of the referenced transactions
+	issuedCache     quantityIssuedCache                                  // quantity issued of the assets by the ledger
+	issuanceRate    issuanceWindow                                       // the amounts recently admitted for issuance
+	reserved        map[common.Uint256]ReservationToken                  // the transactions reserved by SelectAndReserve
+	lastReservation ReservationToken                                     // the token of the last reservation
+	reservedUntil   map[ReservationToken]time.Duration                   // clock.Elapsed when the reservations expire with ReservationTTL
+	admissionSeq    uint64                                               // sequence number of the last pooled transaction
+	generation      uint64                                               // changed whenever a transaction is added to or removed from txnList
+	snapshots       snapshotCache                                        // the view of the last Snapshot
+	events          eventWindow                                          // the recent events counted for WindowStats
+	unspentIndex    UnspentIndex                                         // the unspent outputs of the ledger
+	journal         txnJournal                                           // the additions and removals are recorded to, set by WithJournal
+	minFeeBump      dynamicMinFee                                        // the min fee rate raised by the evictions of the full pool
+	addressIndex    map[common.Uint160]map[common.Uint256]struct{}       // the pooled transactions paying each program hash
+	subscriptions   txnSubscriptions                                     // the subscribers to the admitted transactions
+	assetLocks      map[common.Uint256]int                               // number of the pending LockAsset of each asset ID
+	assetSpends     map[string]map[common.Uint256]struct{}               // the pooled transactions spending the outputs of each program hash and asset ID pair
+	spendKeys       map[common.Uint256][]string                          // the assetSpends keys of each pooled transaction
+	assetIssues     map[common.Uint256]map[common.Uint256]struct{}       // the pooled transactions issuing each asset ID
+	orphanList      map[common.Uint256]*orphanEntry                      // transactions waiting for the transactions they spend from
+	orphanParents   map[common.Uint256]map[common.Uint256]struct{}       // the orphans waiting for each missing transaction
+	maintenance     maintenanceWindow                                    // the maintenance window of the config, reloaded by ReloadLimits
 }
 
-func (this *TXNPool) init() {
+//option applied when the pool is initialized
+type TXNPoolOption func(*TXNPool)
+
+//seed the tiebreak ordering of transactions with equal fee rate, so the
+//selection is reproducible for a seed but differs between seeds.
+func WithSelectionSeed(seed int64) TXNPoolOption {
+	return WithTiebreakKey(SeededTiebreak(seed))
+}
+
+//order the transactions with equal fee rate by the key, e.g. RotatingTiebreak
+//to change which of them are picked from block to block. the transactions are
+//ordered by hash by default.
+func WithTiebreakKey(key TiebreakKey) TXNPoolOption {
+	return func(pool *TXNPool) {
+		pool.tiebreakKey = key
+	}
+}
+
+//reject transactions while the node is syncing, the transactions may refer
+//to state which is not in the local ledger yet.
+func WithSyncState(isSyncing func() bool) TXNPoolOption {
+	return func(pool *TXNPool) {
+		pool.isSyncing = isSyncing
+	}
+}
+
+//only relay the transactions whose outputs are accepted by the policy, e.g.
+//the ones paying change to a recognized address. the transactions of blocks
+//are not checked. all the transactions are accepted by default.
+func WithOutputPolicy(policy func(*transaction.Transaction) error) TXNPoolOption {
+	return func(pool *TXNPool) {
+		pool.outputPolicy = policy
+	}
+}
+
+func acceptAllOutputs(txn *transaction.Transaction) error {
+	return nil
+}
+
+func (this *TXNPool) init(opts ...TXNPoolOption) {
 	this.Lock()
 	defer this.Unlock()
 	this.txnCnt = 0
-	this.inputUTXOList = make(map[string]*transaction.Transaction)
+	this.inputUTXOList = make(map[transaction.UTXOTxInput]*transaction.Transaction)
 	this.issueSummary = make(map[common.Uint256]common.Fixed64)
-	this.txnList = make(map[common.Uint256]*transaction.Transaction)
+	this.txnList = make(map[common.Uint256]*txnEntry)
 	this.lockAssetList = make(map[string]struct{})
+	this.assetLocks = make(map[common.Uint256]int)
+	this.assetSpends = make(map[string]map[common.Uint256]struct{})
+	this.spendKeys = make(map[common.Uint256][]string)
+	this.assetIssues = make(map[common.Uint256]map[common.Uint256]struct{})
+	this.tagList = make(map[common.Uint256]map[string]struct{})
+	this.outputSets = make(map[common.Uint256]common.Uint256)
+	this.addressIndex = make(map[common.Uint160]map[common.Uint256]struct{})
+	this.subscriptions.subscribers = make(map[*txnSubscriber]struct{})
+	this.typeCounts = make(map[transaction.TransactionType]int)
+	this.reserved = make(map[common.Uint256]ReservationToken)
+	this.reservedUntil = make(map[ReservationToken]time.Duration)
+	this.orphanList = make(map[common.Uint256]*orphanEntry)
+	this.orphanParents = make(map[common.Uint256]map[common.Uint256]struct{})
+	this.verifier = ledgerVerifier{}
+	this.feeCalculator = valueDifferenceFee{}
+	this.outputPolicy = acceptAllOutputs
+	this.unspentIndex = ledgerUnspent
+	this.tiebreakKey = RawHashTiebreak
+	this.verifyDurations.init(verifyDurationBounds)
+	this.verifySlots = make(chan struct{}, MAXTIMEDVERIFY)
+	this.rejections.init()
+	this.depthSamples.init(MAXDEPTHSAMPLES)
+	this.clock = newSystemClock()
+	this.quit = make(chan struct{})
+	this.inputHeights.init()
+	this.issuedCache.init()
+	this.issuanceRate.init()
+	this.recentConfirmed.init(RECENTCONFIRMEDHASHES)
+	this.maintenance = loadMaintenanceWindow()
+	this.tasks.add("depth sampling", depthSampleInterval, this.sampleDepth)
+	this.tasks.add("stale check", staleCheckInterval, this.checkStale)
+	this.tasks.add("reservation sweep", reservationSweepInterval, this.sweepReservations)
+	this.tasks.add("expiry sweep", expirySweepInterval, this.sweepExpired)
+	this.tasks.add("orphan sweep", orphanSweepInterval, this.sweepOrphans)
+	for _, opt := range opts {
+		opt(this)
+	}
 }
 
 //append transaction to txnpool when check ok.
 //1.check transaction. 2.check with ledger(db) 3.check with pool
 func (this *TXNPool) AppendTxnPool(txn *transaction.Transaction, poolVerify bool) ErrCode {
+	return this.AppendTxnPoolWithExpiry(txn, poolVerify, TxnExpiry{})
+}
+
+//run the admission of the transaction, with dryRun the checks run but the
+//pool is not changed and ErrNoError tells the transaction would be added.
+func (this *TXNPool) appendTxnPool(txn *transaction.Transaction, poolVerify, dryRun bool, expiry TxnExpiry) ErrCode {
+	if errCode := this.checkExpiry(txn, expiry); errCode != ErrNoError {
+		return errCode
+	}
+	//the transactions of a block being verified by the consensus are not admissions
+	if poolVerify {
+		if errCode := this.checkAdmission(); errCode != ErrNoError {
+			log.Info("Transaction rejected by txnpool", txn.Hash(), errCode)
+			return errCode
+		}
+	}
+	if err := checkOutputValue(txn); err != nil {
+		log.Info(fmt.Sprintf("Transaction =%x rejected before verification, %v", txn.Hash(), err))
+		return ErrInvalidTransaction
+	}
+	if this.isConfirmed(txn.Hash()) {
+		log.Info(fmt.Sprintf("Transaction =%x rejected, already confirmed", txn.Hash()))
+		return ErrAlreadyConfirmed
+	}
+	if errCode := this.checkInputIndexes(txn); errCode != ErrNoError {
+		return errCode
+	}
+	if poolVerify {
+		if errCode := this.checkMaturity(txn); errCode != ErrNoError {
+			return errCode
+		}
+		if err := this.outputPolicy(txn); err != nil {
+			log.Info(fmt.Sprintf("Transaction =%x rejected by the output policy, %v", txn.Hash(), err))
+			return ErrNonStandard
+		}
+	}
+	//keep the transaction until the transactions it spends from arrive
+	missing, err := this.missingParents(txn)
+	if err != nil {
+		log.Info(fmt.Sprintf("Transaction =%x not verified, the store is unavailable, %v", txn.Hash(), err))
+		return ErrPoolUnavailable
+	}
+	if len(missing) > 0 && dryRun {
+		return ErrOrphanTransaction
+	}
+	if len(missing) > 0 && poolVerify && config.Parameters.MaxOrphanTransactions > 0 {
+		return this.addOrphan(txn, missing, expiry)
+	}
 	//verify transaction with Concurrency
-	if errCode := va.VerifyTransaction(txn); errCode != ErrNoError {
-		log.Info("Transaction verification failed", txn.Hash())
+	if errCode := this.verifyWithTimeout(txn, poolVerify, this.verifier.VerifyTransaction, this.ledgerVerifierOf(txn, poolVerify)); errCode != ErrNoError {
 		return errCode
 	}
-	if errCode := va.VerifyTransactionWithLedger(txn, ledger.DefaultLedger); errCode != ErrNoError {
-		log.Info("Transaction verification with ledger failed", txn.Hash())
+	added, errCode := this.commitTransaction(txn, poolVerify, dryRun, expiry)
+	if errCode != ErrNoError || !added {
 		return errCode
 	}
+	atomic.AddUint64(&this.admissions.Submitted, 1)
+	this.events.admitted(this.clock.Elapsed())
+	this.promoteOrphans(txn.Hash())
+	return ErrNoError
+}
+
+//return the transaction of a block orphaned by a reorg to the pool, it's
+//verified again with the current ledger. the BookKeeping transactions only
+//belong to their block and are not returned.
+func (this *TXNPool) ReinsertFromOrphanedBlock(txn *transaction.Transaction) ErrCode {
+	if txn.TxType == transaction.BookKeeping {
+		return ErrInvalidTransaction
+	}
+	if errCode := this.verifyWithTimeout(txn, true, this.verifier.VerifyTransaction, this.ledgerVerifierOf(txn, true)); errCode != ErrNoError {
+		log.Info(fmt.Sprintf("Transaction =%x of orphaned block not reinserted, %v", txn.Hash(), errCode))
+		return errCode
+	}
+	if _, errCode := this.commitTransaction(txn, true, false, TxnExpiry{}); errCode != ErrNoError {
+		log.Info(fmt.Sprintf("Transaction =%x of orphaned block not reinserted, %v", txn.Hash(), errCode))
+		return errCode
+	}
+	atomic.AddUint64(&this.admissions.Reinserted, 1)
+	this.events.admitted(this.clock.Elapsed())
+	this.promoteOrphans(txn.Hash())
+	return ErrNoError
+}
+
+//verify the transaction with the pool and add it, the pool maps are updated
+//by several steps so it's serialized against the other admissions and the
+//cleaning of committed blocks. a transaction of a block which is pooled
+//already passes but is not added again. with dryRun only the checks run.
+func (this *TXNPool) commitTransaction(txn *transaction.Transaction, poolVerify, dryRun bool, expiry TxnExpiry) (bool, ErrCode) {
+	this.commitLock.Lock()
+	defer this.commitLock.Unlock()
+	if this.GetTransaction(txn.Hash()) != nil {
+		if !poolVerify {
+			return false, ErrNoError
+		}
+		log.Info(fmt.Sprintf("Transaction =%x rejected, already in the transaction pool", txn.Hash()))
+		return false, ErrDuplicatedTx
+	}
+	limits := this.Limits()
+	if errCode := this.checkNegativeFee(txn); errCode != ErrNoError {
+		return false, errCode
+	}
+	var admission *poolAdmission
 	if poolVerify {
+		if errCode := this.checkMinFee(txn, limits); errCode != ErrNoError {
+			return false, errCode
+		}
+		if errCode := this.checkTypeLimit(txn); errCode != ErrNoError {
+			return false, errCode
+		}
+		if errCode := this.checkAdmissionLists(txn); errCode != ErrNoError {
+			return false, errCode
+		}
 		//verify transaction by pool with lock
-		if errCode := this.verifyTransactionWithTxnPool(txn); errCode != ErrNoError {
+		var errCode ErrCode
+		if admission, errCode = this.checkTxnPool(txn); errCode != ErrNoError {
 			log.Info("Transaction verification with transaction pool failed", txn.Hash())
-			return errCode
+			return false, errCode
 		}
+		if dryRun {
+			return false, this.checkRoom(txn, limits, admission.replaced)
+		}
+		this.applyTxnPool(admission)
 	}
 
 	//add the transaction to process scope
-	this.addtxnList(txn)
+	if !this.addtxnList(txn, expiry) {
+		return false, ErrNoError
+	}
+	if !poolVerify {
+		this.publish(txn)
+		return true, ErrNoError
+	}
+	//the transaction itself is evicted when it has the lowest fee rate, the
+	//transactions it replaced are kept then
+	if _, ok := this.trimToLimits(limits)[txn.Hash()]; ok {
+		this.restoreReplaced(admission)
+		return false, ErrPoolFull
+	}
+	this.publish(txn)
+	return true, ErrNoError
+}
+
+//cheap sanity check before the verification, the output value summed by
+//asset must fit in Fixed64 or the transaction is malformed.
+func checkOutputValue(txn *transaction.Transaction) error {
+	total := make(map[common.Uint256]common.Fixed64)
+	for _, output := range txn.Outputs {
+		if output.Value < 0 {
+			return errors.New("negative output value")
+		}
+		if total[output.AssetID] > common.Fixed64(math.MaxInt64)-output.Value {
+			return errors.New(fmt.Sprintf("output value of asset %x overflow", output.AssetID))
+		}
+		total[output.AssetID] += output.Value
+	}
+	return nil
+}
+
+//check every input refers to an output the source transaction has, the
+//inputs of unknown sources are left to the orphan handling.
+func (this *TXNPool) checkInputIndexes(txn *transaction.Transaction) ErrCode {
+	for _, input := range txn.UTXOInputs {
+		source := this.GetTransaction(input.ReferTxID)
+		if source == nil {
+			var err error
+			if source, err = transaction.TxStore.GetTransaction(input.ReferTxID); err != nil {
+				continue
+			}
+		}
+		if int(input.ReferTxOutputIndex) >= len(source.Outputs) {
+			log.Info(fmt.Sprintf("Transaction =%x rejected, input refers to output %d of transaction =%x with %d outputs",
+				txn.Hash(), input.ReferTxOutputIndex, input.ReferTxID, len(source.Outputs)))
+			return ErrInvalidReference
+		}
+	}
 	return ErrNoError
 }
 
-//get the transaction in txnpool
-func (this *TXNPool) GetTxnPool(byCount bool) map[common.Uint256]*transaction.Transaction {
-	this.RLock()
-	count := config.Parameters.MaxTxInBlock
-	if count <= 0 {
-		byCount = false
+//verify the transactions before they are pooled
+type Verifier interface {
+	//verify the transaction itself, without the outputs it spends
+	VerifyTransaction(txn *transaction.Transaction) ErrCode
+	//verify the transaction with the ledger and the outputs it spends
+	VerifyTransactionWithLedger(txn *transaction.Transaction) ErrCode
+	//verify the transaction with the ledger resolving the outputs it spends by
+	//the store, the inputs spending transactions out of the ledger are not checked
+	VerifyTransactionWithLedgerAndStore(txn *transaction.Transaction, store transaction.ILedgerStore) ErrCode
+}
+
+//replace the verifier, e.g. by a mock in tests
+func WithVerifier(verifier Verifier) TXNPoolOption {
+	return func(pool *TXNPool) {
+		pool.verifier = verifier
 	}
-	if len(this.txnList) < count || !byCount {
-		count = len(this.txnList)
+}
+
+//the default verifier, verifying by the validation package with the default ledger
+type ledgerVerifier struct{}
+
+//verify the transaction itself
+func (ledgerVerifier) VerifyTransaction(txn *transaction.Transaction) ErrCode {
+	if errCode := va.VerifyTransactionStructure(txn); errCode != ErrNoError {
+		log.Info("Transaction verification failed", txn.Hash())
+		return errCode
 	}
-	var num int
-	txnMap := make(map[common.Uint256]*transaction.Transaction, count)
-	for txnId, tx := range this.txnList {
-		txnMap[txnId] = tx
-		num++
-		if num >= count {
-			break
+	return ErrNoError
+}
+
+//verify the transaction with the ledger(db)
+func (ledgerVerifier) VerifyTransactionWithLedger(txn *transaction.Transaction) ErrCode {
+	errCode := va.VerifyTransactionReferences(txn, transaction.TxStore)
+	if errCode == ErrNoError {
+		errCode = va.VerifyTransactionWithLedger(txn, ledger.DefaultLedger)
+	}
+	if errCode != ErrNoError {
+		log.Info("Transaction verification with ledger failed", txn.Hash())
+		return errCode
+	}
+	return ErrNoError
+}
+
+//verify the transaction with the ledger(db) and the references of the store
+func (ledgerVerifier) VerifyTransactionWithLedgerAndStore(txn *transaction.Transaction, store transaction.ILedgerStore) ErrCode {
+	if errCode := va.VerifyTransactionWithLedgerAndStore(txn, ledger.DefaultLedger, store); errCode != ErrNoError {
+		log.Info("Transaction verification with ledger failed", txn.Hash())
+		return errCode
+	}
+	return ErrNoError
+}
+
+const MAXTIMEDVERIFY = 64 // verifications running with a timeout, including the timed out ones
+
+//verify the transaction by the verifiers in order, giving up when a pool
+//admission takes longer than the configured VerifyTimeout. the transactions
+//of a block being verified are not timed out. the time spent is recorded to
+//the verify duration histogram.
+func (this *TXNPool) verifyWithTimeout(txn *transaction.Transaction, poolVerify bool, verifiers ...func(*transaction.Transaction) ErrCode) ErrCode {
+	ctx := context.Background()
+	if timeout := this.Limits().VerifyTimeout; timeout > 0 && poolVerify {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	start := time.Now()
+	errCode := this.verifyTransaction(ctx, txn, verifiers)
+	this.verifyDurations.observe(time.Since(start))
+	return errCode
+}
+
+//the verification itself can not be interrupted, on timeout it keeps running
+//in background and its result is dropped. at most MAXTIMEDVERIFY of them run,
+//a verification waiting longer for its turn times out too.
+func (this *TXNPool) verifyTransaction(ctx context.Context, txn *transaction.Transaction,
+	verifiers []func(*transaction.Transaction) ErrCode) ErrCode {
+	verify := func() ErrCode {
+		for _, verifier := range verifiers {
+			if errCode := verifier(txn); errCode != ErrNoError {
+				return errCode
+			}
 		}
+		return ErrNoError
 	}
-	this.RUnlock()
-	return txnMap
+	if ctx.Done() == nil {
+		return verify()
+	}
+	select {
+	case this.verifySlots <- struct{}{}:
+	case <-ctx.Done():
+		log.Info("Transaction verification timeout", txn.Hash())
+		return ErrVerifyTimeout
+	}
+	result := make(chan ErrCode, 1)
+	go func() {
+		defer func() { <-this.verifySlots }()
+		result <- verify()
+	}()
+	select {
+	case errCode := <-result:
+		return errCode
+	case <-ctx.Done():
+		log.Info("Transaction verification timeout", txn.Hash())
+		return ErrVerifyTimeout
+	}
+}
+
+//check weather the pool accepts new transactions at the moment
+func (this *TXNPool) checkAdmission() ErrCode {
+	if atomic.LoadUint32(&this.frozen) == 1 || this.inMaintenanceWindow(this.clock.Now()) {
+		return ErrPoolFrozen
+	}
+	if this.isSyncing != nil && this.isSyncing() {
+		return ErrSyncing
+	}
+	return ErrNoError
+}
+
+//stop accepting new transactions, e.g. during ledger maintenance.
+//the pooled transactions can still be read.
+func (this *TXNPool) Freeze() {
+	atomic.StoreUint32(&this.frozen, 1)
+}
+
+//accept new transactions again after Freeze
+func (this *TXNPool) Unfreeze() {
+	atomic.StoreUint32(&this.frozen, 0)
+}
+
+//get the transaction in txnpool, when limited by count the transactions
+//with the highest fee rate are picked first.
+func (this *TXNPool) GetTxnPool(byCount bool) map[common.Uint256]*transaction.Transaction {
+	return this.GetTxnPoolWithMinInputConfirmations(byCount, 0)
 }
 
 //clean the trasaction Pool with committed block.
 func (this *TXNPool) CleanSubmittedTransactions(block *ledger.Block) error {
+	this.commitLock.Lock()
 	this.cleanTransactionList(block.Transactions)
 	this.cleanUTXOList(block.Transactions)
 	this.cleanLockedAssetList(block.Transactions)
 	this.cleanIssueSummary(block.Transactions)
+	if block.Blockdata != nil {
+		this.expireByHeight(block.Blockdata.Height)
+	}
+	this.commitLock.Unlock()
+	this.blockCommitted()
+	for _, txn := range block.Transactions {
+		this.promoteOrphans(txn.Hash())
+	}
 	return nil
 }
 
@@ -94,39 +497,153 @@ func (this *TXNPool) CleanSubmittedTransactions(block *ledger.Block) error {
 func (this *TXNPool) GetTransaction(hash common.Uint256) *transaction.Transaction {
 	this.RLock()
 	defer this.RUnlock()
-	return this.txnList[hash]
+	entry, ok := this.txnList[hash]
+	if !ok {
+		return nil
+	}
+	return entry.txn
+}
+
+//the changes of the pool admitting a transaction, collected by checkTxnPool
+//before the pool is updated.
+type poolAdmission struct {
+	txn       *transaction.Transaction
+	reference map[*transaction.UTXOTxInput]*transaction.TxOutput // the outputs the transaction spends
+	replaced  []*transaction.Transaction                         // the transactions the admission replaces, descendants first
+	expiries  map[common.Uint256]TxnExpiry                       // the expiry of the replaced transactions, set by applyTxnPool
 }
 
 //verify transaction with txnpool
 func (this *TXNPool) verifyTransactionWithTxnPool(txn *transaction.Transaction) ErrCode {
+	admission, errCode := this.checkTxnPool(txn)
+	if errCode != ErrNoError {
+		return errCode
+	}
+	this.applyTxnPool(admission)
+	return ErrNoError
+}
+
+//run the checks of the transaction with the pool without changing it, the
+//transactions it replaces are left in the pool until applyTxnPool.
+func (this *TXNPool) checkTxnPool(txn *transaction.Transaction) (*poolAdmission, ErrCode) {
+	// check if the LockAsset transaction locks a registered asset
+	if err := checkLockAssetRegistered(txn); err != nil {
+		log.Info(err)
+		return nil, ErrUnknownAsset
+	}
+	// check if the transaction spends or issues an asset locked by a pooled LockAsset
+	if err := this.checkAssetLocked(txn); err != nil {
+		log.Info(err)
+		if ErrerCode(err) == ErrPoolUnavailable {
+			return nil, ErrPoolUnavailable
+		}
+		return nil, ErrAssetLocked
+	}
+	if err := this.checkIssuanceLocked(txn); err != nil {
+		log.Info(err)
+		return nil, ErrAssetLocked
+	}
 	// check if the transaction includes double spent UTXO inputs
-	if err := this.apendToUTXOPool(txn); err != nil {
+	admission, err := this.checkUTXOPool(txn)
+	if err != nil {
 		log.Info(err)
-		return ErrDoubleSpend
+		switch ErrerCode(err) {
+		case ErrInsufficientReplacementFee:
+			return nil, ErrInsufficientReplacementFee
+		case ErrPoolUnavailable:
+			return nil, ErrPoolUnavailable
+		}
+		return nil, ErrDoubleSpend
 	}
 	// check if exist duplicate LockAsset transactions in a block
-	if err := this.checkDuplicateLockAsset(txn); err != nil {
+	if err := this.checkDuplicateLockAsset(txn, admission.replaced); err != nil {
 		log.Info(err)
-		return ErrDuplicateLockAsset
+		return nil, ErrDuplicateLockAsset
 	}
 	//check issue transaction weather occur exceed issue range.
-	if ok := this.summaryAssetIssueAmount(txn); !ok {
-		log.Info(fmt.Sprintf("Check summary Asset Issue Amount failed with txn=%x", txn.Hash()))
-		this.removeTransaction(txn)
-		return ErrSummaryAsset
+	if errCode := this.checkAssetIssueAmount(txn, admission.replaced); errCode != ErrNoError {
+		log.Info(fmt.Sprintf("Check summary Asset Issue Amount failed with txn=%x, %v", txn.Hash(), errCode))
+		return nil, errCode
 	}
+	return admission, ErrNoError
+}
 
-	return ErrNoError
+//update the pool with the admission which passed checkTxnPool, the replaced
+//transactions are removed first. the caller must hold the commit lock.
+func (this *TXNPool) applyTxnPool(admission *poolAdmission) {
+	txn := admission.txn
+	admission.expiries = make(map[common.Uint256]TxnExpiry, len(admission.replaced))
+	for _, r := range admission.replaced {
+		log.Info(fmt.Sprintf("Transaction =%x replaced by %x", r.Hash(), txn.Hash()))
+		this.RLock()
+		if entry, ok := this.txnList[r.Hash()]; ok {
+			admission.expiries[r.Hash()] = entry.expiry
+		}
+		this.RUnlock()
+		this.removeTransaction(r)
+	}
+	for input := range admission.reference {
+		this.addInputUTXOList(txn, input)
+	}
+	this.Lock()
+	this.indexAssetSpends(txn, admission.reference)
+	this.Unlock()
+	this.addLockAsset(txn)
+	this.evictLockedSpends(txn)
+	this.summaryAssetIssueAmount(txn)
 }
 
-func (this *TXNPool) checkDuplicateLockAsset(txn *transaction.Transaction) error {
+//put back the transactions replaced by an admission which was evicted right
+//away, ancestors first. the caller must hold the commit lock.
+func (this *TXNPool) restoreReplaced(admission *poolAdmission) {
+	for i := len(admission.replaced) - 1; i >= 0; i-- {
+		r := admission.replaced[i]
+		if errCode := this.verifyTransactionWithTxnPool(r); errCode != ErrNoError {
+			log.Info(fmt.Sprintf("Transaction =%x replaced by an evicted transaction not restored, %v", r.Hash(), errCode))
+			continue
+		}
+		this.addtxnList(r, admission.expiries[r.Hash()])
+	}
+}
+
+//check the LockAsset transaction doesn't duplicate a pooled one, except the
+//ones it replaces.
+func (this *TXNPool) checkDuplicateLockAsset(txn *transaction.Transaction, replaced []*transaction.Transaction) error {
+	if txn.TxType != transaction.LockAsset {
+		return nil
+	}
+	str := txn.Payload.(*payload.LockAsset).ToString()
+	for _, r := range replaced {
+		if r.TxType == transaction.LockAsset && r.Payload.(*payload.LockAsset).ToString() == str {
+			return nil
+		}
+	}
+	this.RLock()
+	defer this.RUnlock()
+	if _, ok := this.lockAssetList[str]; ok {
+		return errors.New("duplicated locking asset detected")
+	}
+	return nil
+}
+
+func (this *TXNPool) addLockAsset(txn *transaction.Transaction) {
 	if txn.TxType == transaction.LockAsset {
 		lockAssetPayload := txn.Payload.(*payload.LockAsset)
-		str := lockAssetPayload.ToString()
-		if _, ok := this.lockAssetList[str]; ok {
-			return errors.New("duplicated locking asset detected")
-		}
-		this.lockAssetList[str] = struct{}{}
+		this.Lock()
+		defer this.Unlock()
+		this.lockAssetList[lockAssetPayload.ToString()] = struct{}{}
+		this.assetLocks[lockAssetPayload.AssetID]++
+	}
+}
+
+func checkLockAssetRegistered(txn *transaction.Transaction) error {
+	if txn.TxType != transaction.LockAsset {
+		return nil
+	}
+	lockAssetPayload := txn.Payload.(*payload.LockAsset)
+	reg, err := transaction.TxStore.GetTransaction(lockAssetPayload.AssetID)
+	if err != nil || reg.TxType != transaction.RegisterAsset {
+		return errors.New(fmt.Sprintf("locking unregistered asset %x", lockAssetPayload.AssetID))
 	}
 
 	return nil
@@ -136,8 +653,9 @@ func (this *TXNPool) checkDuplicateLockAsset(txn *transaction.Transaction) error
 func (this *TXNPool) removeTransaction(txn *transaction.Transaction) {
 	//1.remove from txnList
 	this.deltxnList(txn)
+	this.cleanLockedAssetList([]*transaction.Transaction{txn})
 	//2.remove from UTXO list map
-	result, err := txn.GetReference()
+	result, err := this.getReference(txn)
 	if err != nil {
 		log.Info(fmt.Sprintf("Transaction =%x not Exist in Pool when delete.", txn.Hash()))
 		return
@@ -149,32 +667,67 @@ func (this *TXNPool) removeTransaction(txn *transaction.Transaction) {
 	if txn.TxType != transaction.IssueAsset {
 		return
 	}
-	transactionResult := txn.GetMergedAssetIDValueFromOutputs()
-	for k, delta := range transactionResult {
+	for k, delta := range issuedAmounts(txn) {
 		this.decrAssetIssueAmountSummary(k, delta)
 	}
 }
 
-//check and add to utxo list pool
-func (this *TXNPool) apendToUTXOPool(txn *transaction.Transaction) error {
-	reference, err := txn.GetReference()
+//check the inputs aren't spent by the pooled transactions, or that the
+//transaction can replace the ones spending them.
+func (this *TXNPool) checkUTXOPool(txn *transaction.Transaction) (*poolAdmission, error) {
+	reference, err := this.getReference(txn)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	inputs := []*transaction.UTXOTxInput{}
+	admission := &poolAdmission{txn: txn, reference: reference}
+	conflicts := make(map[common.Uint256]*transaction.Transaction)
 	for k := range reference {
-		if txn := this.getInputUTXOList(k); txn != nil {
-			return errors.New(fmt.Sprintf("double spent UTXO inputs detected, "+
-				"transaction hash: %x, input: %s, index: %s",
-				txn.Hash(), k.ToString()[:64], k.ToString()[64:]))
+		if spender := this.getInputUTXOList(k); spender != nil {
+			if conflictPolicy() == CONFLICTFIRSTSEEN {
+				return nil, errors.New(fmt.Sprintf("double spent UTXO inputs detected, "+
+					"transaction hash: %x, input: %s, index: %s",
+					spender.Hash(), k.ToString()[:64], k.ToString()[64:]))
+			}
+			conflicts[spender.Hash()] = spender
 		}
-		inputs = append(inputs, k)
 	}
-	for _, v := range inputs {
-		this.addInputUTXOList(txn, v)
+	if len(conflicts) > 0 {
+		admission.replaced = this.replacedTransactions(conflicts)
+		if err := this.checkReplacement(txn, conflicts, admission.replaced); err != nil {
+			return nil, err
+		}
 	}
+	return admission, nil
+}
 
-	return nil
+//get the outputs referenced by the transaction inputs. the pool is checked
+//before the ledger so a transaction spending the output of a transaction
+//which is still in the pool can be resolved.
+func (this *TXNPool) getReference(txn *transaction.Transaction) (map[*transaction.UTXOTxInput]*transaction.TxOutput, error) {
+	if txn.TxType == transaction.RegisterAsset {
+		return nil, nil
+	}
+	reference := make(map[*transaction.UTXOTxInput]*transaction.TxOutput)
+	for _, utxo := range txn.UTXOInputs {
+		referTxn := this.GetTransaction(utxo.ReferTxID)
+		if referTxn == nil {
+			var err error
+			referTxn, err = transaction.TxStore.GetTransaction(utxo.ReferTxID)
+			if err != nil {
+				//a store failure is not a missing output, the admission may be tried again
+				errCode := ErrPoolUnavailable
+				if transaction.IsTxNotFound(err) {
+					errCode = ErrNoCode
+				}
+				return nil, NewDetailErr(err, errCode, "[TXNPool], getReference failed.")
+			}
+		}
+		if int(utxo.ReferTxOutputIndex) >= len(referTxn.Outputs) {
+			return nil, NewDetailErr(errors.New("output index out of range"), ErrNoCode, "[TXNPool], getReference failed.")
+		}
+		reference[utxo] = referTxn.Outputs[utxo.ReferTxOutputIndex]
+	}
+	return reference, nil
 }
 
 //clean txnpool utxo map
@@ -188,32 +741,84 @@ func (this *TXNPool) cleanUTXOList(txs []*transaction.Transaction) {
 }
 
 func (this *TXNPool) cleanLockedAssetList(txs []*transaction.Transaction) {
+	this.Lock()
+	defer this.Unlock()
 	for _, txn := range txs {
 		if txn.TxType == transaction.LockAsset {
 			lockAssetPayload := txn.Payload.(*payload.LockAsset)
+			if _, ok := this.lockAssetList[lockAssetPayload.ToString()]; !ok {
+				continue
+			}
 			delete(this.lockAssetList, lockAssetPayload.ToString())
+			if this.assetLocks[lockAssetPayload.AssetID]--; this.assetLocks[lockAssetPayload.AssetID] == 0 {
+				delete(this.assetLocks, lockAssetPayload.AssetID)
+			}
 		}
 	}
 }
 
-//check and summary to issue amount Pool
-func (this *TXNPool) summaryAssetIssueAmount(txn *transaction.Transaction) bool {
+//get the program hash and asset ID pairs with a pending LockAsset, each as
+//the hex of the program hash followed by the hex of the asset ID.
+func (this *TXNPool) LockedAssets() []string {
+	this.RLock()
+	defer this.RUnlock()
+	locked := make([]string, 0, len(this.lockAssetList))
+	for key := range this.lockAssetList {
+		locked = append(locked, key)
+	}
+	sort.Strings(locked)
+	return locked
+}
+
+//check the issue amount with the amount of the pool, the transactions it
+//replaces excluded. ErrUnknownAsset is returned when the asset is not
+//registered, ErrDataIntegrity when the ledger record of the asset ID is not a
+//registration, and ErrSummaryAsset when the amount exceeds.
+func (this *TXNPool) checkAssetIssueAmount(txn *transaction.Transaction, replaced []*transaction.Transaction) ErrCode {
 	if txn.TxType != transaction.IssueAsset {
-		return true
+		return ErrNoError
 	}
-	transactionResult := txn.GetMergedAssetIDValueFromOutputs()
+	transactionResult := issuedAmounts(txn)
+	//the amount in txnPool once the transaction replaced the ones it conflicts with
+	pending := make(map[common.Uint256]common.Fixed64, len(transactionResult))
 	for k, delta := range transactionResult {
-		//update the amount in txnPool
-		this.incrAssetIssueAmountSummary(k, delta)
+		pending[k] = this.getAssetIssueAmount(k) + delta
+	}
+	for _, r := range replaced {
+		if r.TxType != transaction.IssueAsset {
+			continue
+		}
+		for k, delta := range issuedAmounts(r) {
+			if _, ok := pending[k]; ok {
+				pending[k] -= delta
+			}
+		}
+	}
+	limits := this.Limits()
+	maxPending := limits.MaxPendingIssuancePerAsset
+	now := this.clock.Elapsed()
+	for k, delta := range transactionResult {
+		//throttle the amount pending in txnPool regardless of the registered amount
+		if maxPending > 0 && pending[k] > maxPending {
+			log.Info(fmt.Sprintf("Pending issue amount of asset=%x exceed the limit %v", k, maxPending))
+			return ErrSummaryAsset
+		}
+		//throttle the amount admitted recently regardless of what is still pending
+		if limits.IssuanceRateLimit > 0 && this.issuanceRate.amount(k, now-limits.IssuanceRateWindow)+delta > limits.IssuanceRateLimit {
+			log.Info(fmt.Sprintf("Issue amount of asset=%x exceed the rate limit %v per %v", k, limits.IssuanceRateLimit, limits.IssuanceRateWindow))
+			return ErrIssuanceRateLimited
+		}
 
 		//Check weather occur exceed the amount when RegisterAsseted
 		//1. Get the Asset amount when RegisterAsseted.
 		txn, err := transaction.TxStore.GetTransaction(k)
 		if err != nil {
-			return false
+			log.Info(fmt.Sprintf("Issue of asset=%x not registered", k))
+			return ErrUnknownAsset
 		}
 		if txn.TxType != transaction.RegisterAsset {
-			return false
+			log.Error(fmt.Sprintf("Data integrity error, the record of asset=%x is a transaction of type %x", k, byte(txn.TxType)))
+			return ErrDataIntegrity
 		}
 		AssetReg := txn.Payload.(*payload.RegisterAsset)
 
@@ -222,21 +827,37 @@ func (this *TXNPool) summaryAssetIssueAmount(txn *transaction.Transaction) bool
 		if AssetReg.Amount < common.Fixed64(0) {
 			continue
 		} else {
-			quantity_issued, err = transaction.TxStore.GetQuantityIssued(k)
+			quantity_issued, err = this.quantityIssued(k)
 			if err != nil {
-				return false
+				return ErrSummaryAsset
 			}
 		}
 
 		//3. calc weather out off the amount when Registed.
 		//AssetReg.Amount : amount when RegisterAsset of this assedID
 		//quantity_issued : amount has been issued of this assedID
-		//txnPool.issueSummary[k] : amount in transactionPool of this assedID
-		if AssetReg.Amount-quantity_issued < this.getAssetIssueAmount(k) {
-			return false
+		//pending[k] : amount in transactionPool of this assedID
+		if AssetReg.Amount-quantity_issued < pending[k] {
+			return ErrSummaryAsset
+		}
+	}
+	return ErrNoError
+}
+
+//summary the issue amount of the transaction which passed
+//checkAssetIssueAmount to the pool.
+func (this *TXNPool) summaryAssetIssueAmount(txn *transaction.Transaction) {
+	if txn.TxType != transaction.IssueAsset {
+		return
+	}
+	limits := this.Limits()
+	now := this.clock.Elapsed()
+	for k, delta := range issuedAmounts(txn) {
+		this.incrAssetIssueAmountSummary(k, delta)
+		if limits.IssuanceRateLimit > 0 {
+			this.issuanceRate.record(k, delta, now, now-limits.IssuanceRateWindow)
 		}
 	}
-	return true
 }
 
 // clean the trasaction Pool with committed transactions.
@@ -248,10 +869,13 @@ func (this *TXNPool) cleanTransactionList(txns []*transaction.Transaction) error
 			txnsNum = txnsNum - 1
 			continue
 		}
+		this.recentConfirmed.add(txn.Hash())
+		this.invalidateQuantityIssued(txn)
 		if this.deltxnList(txn) {
 			cleaned++
 		}
 	}
+	this.events.confirmed(this.clock.Elapsed(), cleaned)
 	if txnsNum != cleaned {
 		log.Info(fmt.Sprintf("The Transactions num Unmatched. Expect %d, got %d .\n", txnsNum, cleaned))
 	}
@@ -259,25 +883,51 @@ func (this *TXNPool) cleanTransactionList(txns []*transaction.Transaction) error
 	return nil
 }
 
-func (this *TXNPool) addtxnList(txn *transaction.Transaction) bool {
+func (this *TXNPool) addtxnList(txn *transaction.Transaction, expiry TxnExpiry) bool {
+	entry := this.newTxnEntry(txn)
+	entry.expiry = expiry
+	defer this.flushJournal()
 	this.Lock()
 	defer this.Unlock()
 	txnHash := txn.Hash()
 	if _, ok := this.txnList[txnHash]; ok {
 		return false
 	}
-	this.txnList[txnHash] = txn
+	this.admissionSeq++
+	entry.seq = this.admissionSeq
+	this.generation++
+	this.txnList[txnHash] = entry
+	this.typeCounts[txn.TxType]++
+	this.txnBytes += entry.size
+	this.indexOutputs(txnHash, entry.outputs)
+	this.indexAddresses(txnHash, txn)
+	this.updatePackage(entry)
+	this.updatePackages(this.descendants(txnHash))
+	this.journalAdd(txn, expiry)
 	return true
 }
 
 func (this *TXNPool) deltxnList(tx *transaction.Transaction) bool {
+	defer this.flushJournal()
 	this.Lock()
 	defer this.Unlock()
 	txHash := tx.Hash()
-	if _, ok := this.txnList[txHash]; !ok {
+	entry, ok := this.txnList[txHash]
+	if !ok {
 		return false
 	}
+	this.typeCounts[tx.TxType]--
+	this.txnBytes -= entry.size
+	this.unindexOutputs(txHash, entry.outputs)
+	this.unindexAddresses(txHash, tx)
+	this.unindexAssetSpends(tx)
+	descendants := this.descendants(txHash)
+	this.generation++
 	delete(this.txnList, tx.Hash())
+	this.updatePackages(descendants)
+	delete(this.tagList, txHash)
+	delete(this.reserved, txHash)
+	this.journalRemove(txHash)
 	return true
 }
 
@@ -285,8 +935,8 @@ func (this *TXNPool) copytxnList() map[common.Uint256]*transaction.Transaction {
 	this.RLock()
 	defer this.RUnlock()
 	txnMap := make(map[common.Uint256]*transaction.Transaction, len(this.txnList))
-	for txnId, txn := range this.txnList {
-		txnMap[txnId] = txn
+	for txnId, entry := range this.txnList {
+		txnMap[txnId] = entry.txn
 	}
 	return txnMap
 }
@@ -297,21 +947,33 @@ func (this *TXNPool) GetTransactionCount() int {
 	return len(this.txnList)
 }
 
+//the output value of the pooled transactions summed by asset
+func (this *TXNPool) PendingValueByAsset() map[common.Uint256]common.Fixed64 {
+	this.RLock()
+	defer this.RUnlock()
+	pending := make(map[common.Uint256]common.Fixed64)
+	for _, entry := range this.txnList {
+		for assetID, value := range entry.txn.GetMergedAssetIDValueFromOutputs() {
+			pending[assetID] += value
+		}
+	}
+	return pending
+}
+
 func (this *TXNPool) getInputUTXOList(input *transaction.UTXOTxInput) *transaction.Transaction {
 	this.RLock()
 	defer this.RUnlock()
-	return this.inputUTXOList[input.ToString()]
+	return this.inputUTXOList[*input]
 }
 
 func (this *TXNPool) addInputUTXOList(tx *transaction.Transaction, input *transaction.UTXOTxInput) bool {
 	this.Lock()
 	defer this.Unlock()
-	id := input.ToString()
-	_, ok := this.inputUTXOList[id]
+	_, ok := this.inputUTXOList[*input]
 	if ok {
 		return false
 	}
-	this.inputUTXOList[id] = tx
+	this.inputUTXOList[*input] = tx
 
 	return true
 }
@@ -319,12 +981,11 @@ func (this *TXNPool) addInputUTXOList(tx *transaction.Transaction, input *transa
 func (this *TXNPool) delInputUTXOList(input *transaction.UTXOTxInput) bool {
 	this.Lock()
 	defer this.Unlock()
-	id := input.ToString()
-	_, ok := this.inputUTXOList[id]
+	_, ok := this.inputUTXOList[*input]
 	if !ok {
 		return false
 	}
-	delete(this.inputUTXOList, id)
+	delete(this.inputUTXOList, *input)
 	return true
 }
 
@@ -342,8 +1003,10 @@ func (this *TXNPool) decrAssetIssueAmountSummary(assetId common.Uint256, delta c
 		return
 	}
 	amount = amount - delta
-	if amount < common.Fixed64(0) {
-		amount = common.Fixed64(0)
+	//no amount pending for the asset anymore, drop the key
+	if amount <= common.Fixed64(0) {
+		delete(this.issueSummary, assetId)
+		return
 	}
 	this.issueSummary[assetId] = amount
 }
@@ -351,14 +1014,34 @@ func (this *TXNPool) decrAssetIssueAmountSummary(assetId common.Uint256, delta c
 func (this *TXNPool) cleanIssueSummary(txs []*transaction.Transaction) {
 	for _, v := range txs {
 		if v.TxType == transaction.IssueAsset {
-			transactionResult := v.GetMergedAssetIDValueFromOutputs()
-			for k, delta := range transactionResult {
+			for k, delta := range issuedAmounts(v) {
 				this.decrAssetIssueAmountSummary(k, delta)
 			}
 		}
 	}
 }
 
+//the amount issued by the transaction per asset. the outputs are merged here
+//rather than relying on GetMergedAssetIDValueFromOutputs, so the summary is
+//updated once per asset with the combined amount.
+func issuedAmounts(txn *transaction.Transaction) map[common.Uint256]common.Fixed64 {
+	amounts := make(map[common.Uint256]common.Fixed64)
+	for _, output := range txn.Outputs {
+		amounts[output.AssetID] += output.Value
+	}
+	return amounts
+}
+
+//convert the amount configured in float to fixed point
+func configFixed64(amount float64) common.Fixed64 {
+	value, err := common.StringToFixed64(strconv.FormatFloat(amount, 'f', 8, 64))
+	if err != nil {
+		log.Warn("Invalid amount in config:", amount)
+		return common.Fixed64(0)
+	}
+	return value
+}
+
 func (this *TXNPool) getAssetIssueAmount(assetId common.Uint256) common.Fixed64 {
 	this.RLock()
 	defer this.RUnlock()
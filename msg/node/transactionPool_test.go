@@ -0,0 +1,4151 @@
+package node
+
+import (
+	"IPT/common"
+	"IPT/common/config"
+	. "IPT/common/errors"
+	"IPT/common/log"
+	"IPT/common/metrics"
+	"IPT/core/asset"
+	"IPT/core/ledger"
+	"IPT/core/transaction"
+	"IPT/core/transaction/payload"
+	"IPT/crypto"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func init() {
+	log.Init()
+}
+
+var testAssetID = common.Uint256{1}
+
+type testLedgerStore struct {
+	txns             map[common.Uint256]*transaction.Transaction
+	issued           map[common.Uint256]common.Fixed64
+	onGetTransaction func(hash common.Uint256)
+	issuedLookups    int
+	failure          error // returned by GetTransaction instead of reading the store
+}
+
+func (s *testLedgerStore) GetTransaction(hash common.Uint256) (*transaction.Transaction, error) {
+	if s.onGetTransaction != nil {
+		s.onGetTransaction(hash)
+	}
+	if s.failure != nil {
+		return nil, s.failure
+	}
+	txn, ok := s.txns[hash]
+	if !ok {
+		return nil, transaction.ErrTxNotFound
+	}
+	return txn, nil
+}
+
+func (s *testLedgerStore) GetQuantityIssued(assetId common.Uint256) (common.Fixed64, error) {
+	s.issuedLookups++
+	return s.issued[assetId], nil
+}
+
+func (s *testLedgerStore) addTxn(txn *transaction.Transaction) {
+	s.txns[txn.Hash()] = txn
+}
+
+func newTestPool(opts ...TXNPoolOption) (*TXNPool, *testLedgerStore) {
+	store := &testLedgerStore{
+		txns:   make(map[common.Uint256]*transaction.Transaction),
+		issued: make(map[common.Uint256]common.Fixed64),
+	}
+	transaction.TxStore = store
+	pool := new(TXNPool)
+	pool.init(opts...)
+	//the transactions built by the tests carry no programs to verify
+	if _, ok := pool.verifier.(ledgerVerifier); ok {
+		pool.verifier = &testVerifier{}
+	}
+	return pool, store
+}
+
+//verifier running the functions set by the tests, nil functions pass
+type testVerifier struct {
+	structure      func(*transaction.Transaction) ErrCode
+	ledger         func(*transaction.Transaction) ErrCode
+	ledgerAndStore func(*transaction.Transaction, transaction.ILedgerStore) ErrCode // the ledger of the chained ones, ledger by default
+}
+
+func (v *testVerifier) VerifyTransaction(txn *transaction.Transaction) ErrCode {
+	if v.structure == nil {
+		return ErrNoError
+	}
+	return v.structure(txn)
+}
+
+func (v *testVerifier) VerifyTransactionWithLedger(txn *transaction.Transaction) ErrCode {
+	if v.ledger == nil {
+		return ErrNoError
+	}
+	return v.ledger(txn)
+}
+
+func (v *testVerifier) VerifyTransactionWithLedgerAndStore(txn *transaction.Transaction, store transaction.ILedgerStore) ErrCode {
+	if v.ledgerAndStore != nil {
+		return v.ledgerAndStore(txn, store)
+	}
+	return v.VerifyTransactionWithLedger(txn)
+}
+
+func testVerifierOf(pool *TXNPool) *testVerifier {
+	return pool.verifier.(*testVerifier)
+}
+
+func newTestOutput(value common.Fixed64) *transaction.TxOutput {
+	return &transaction.TxOutput{AssetID: testAssetID, Value: value}
+}
+
+func newTestTxn(inputs []*transaction.UTXOTxInput, outputs ...*transaction.TxOutput) *transaction.Transaction {
+	return &transaction.Transaction{
+		TxType:     transaction.TransferAsset,
+		Payload:    new(payload.TransferAsset),
+		UTXOInputs: inputs,
+		Outputs:    outputs,
+	}
+}
+
+//a confirmed transaction in the store with one output per value
+func newTestFunding(store *testLedgerStore, values ...common.Fixed64) *transaction.Transaction {
+	outputs := []*transaction.TxOutput{}
+	for _, v := range values {
+		outputs = append(outputs, newTestOutput(v))
+	}
+	txn := newTestTxn(nil, outputs...)
+	store.addTxn(txn)
+	return txn
+}
+
+func spend(txn *transaction.Transaction, index uint16) *transaction.UTXOTxInput {
+	return &transaction.UTXOTxInput{ReferTxID: txn.Hash(), ReferTxOutputIndex: index}
+}
+
+//add the transaction with the pool checks only, the ledger is not available in tests
+func appendTestTxn(t *testing.T, pool *TXNPool, txn *transaction.Transaction) {
+	if errCode := pool.verifyTransactionWithTxnPool(txn); errCode != ErrNoError {
+		t.Fatalf("verify transaction with pool failed: %v", errCode)
+	}
+	pool.addtxnList(txn, TxnExpiry{})
+}
+
+func setMaxTxInBlock(count int) func() {
+	old := config.Parameters.MaxTxInBlock
+	config.Parameters.MaxTxInBlock = count
+	return func() { config.Parameters.MaxTxInBlock = old }
+}
+
+func TestPrioritiseTransaction(t *testing.T) {
+	pool, store := newTestPool()
+	defer setMaxTxInBlock(1)()
+	funding := newTestFunding(store, 1000, 1000)
+	highFee := newTestTxn([]*transaction.UTXOTxInput{spend(funding, 0)}, newTestOutput(900))
+	lowFee := newTestTxn([]*transaction.UTXOTxInput{spend(funding, 1)}, newTestOutput(990))
+	appendTestTxn(t, pool, highFee)
+	appendTestTxn(t, pool, lowFee)
+
+	if _, ok := pool.GetTxnPool(true)[highFee.Hash()]; !ok {
+		t.Fatal("expected the high fee transaction to be selected")
+	}
+
+	pool.PrioritiseTransaction(lowFee.Hash(), 1000)
+	selected := pool.GetTxnPool(true)
+	if _, ok := selected[lowFee.Hash()]; !ok || len(selected) != 1 {
+		t.Fatal("expected the prioritised transaction to be selected")
+	}
+	if fee := pool.txnList[lowFee.Hash()].fee; fee != 10 {
+		t.Fatalf("prioritise changed the actual fee to %v", fee)
+	}
+	weight := Weight(lowFee)
+	if rate := pool.EffectiveFeeRate(lowFee.Hash()); rate != getFeeRate(1010, weight) {
+		t.Fatalf("expected the effective fee rate to include the delta, got %v", rate)
+	}
+	if rate := pool.RawFeeRate(lowFee.Hash()); rate != getFeeRate(10, weight) {
+		t.Fatalf("expected the raw fee rate to exclude the delta, got %v", rate)
+	}
+}
+
+func TestGetTxnPoolBlockOrder(t *testing.T) {
+	pool, store := newTestPool()
+	funding := newTestFunding(store, 1000, 1000)
+	parent := newTestTxn([]*transaction.UTXOTxInput{spend(funding, 0)}, newTestOutput(999))
+	child := newTestTxn([]*transaction.UTXOTxInput{spend(parent, 0)}, newTestOutput(500))
+	other := newTestTxn([]*transaction.UTXOTxInput{spend(funding, 1)}, newTestOutput(900))
+	for _, txn := range []*transaction.Transaction{parent, child, other} {
+		appendTestTxn(t, pool, txn)
+	}
+	//the block is assembled in the order of the map like the consensus does
+	assemble := func(count int) []*transaction.Transaction {
+		defer setMaxTxInBlock(count)()
+		block := []*transaction.Transaction{}
+		for _, txn := range pool.GetTxnPool(true) {
+			block = append(block, txn)
+		}
+		return block
+	}
+	for count := 1; count <= 3; count++ {
+		block := assemble(count)
+		expected := count
+		if expected > 2 {
+			expected = 2
+		}
+		if len(block) != expected {
+			t.Fatalf("expected %d transactions selected, got %d", expected, len(block))
+		}
+		for i, txn := range block {
+			if txn == child {
+				t.Fatalf("expected the child of a pooled parent left out of the block of %d", count)
+			}
+			for _, later := range block[i:] {
+				for _, input := range txn.UTXOInputs {
+					if input.ReferTxID == later.Hash() {
+						t.Fatalf("expected no transaction before the one it spends in the block of %d", count)
+					}
+				}
+			}
+		}
+	}
+
+	//the child is a candidate once the parent is confirmed
+	store.addTxn(parent)
+	pool.CleanSubmittedTransactions(&ledger.Block{Transactions: []*transaction.Transaction{parent}})
+	block := assemble(2)
+	if len(block) != 2 || (block[0] != child && block[1] != child) {
+		t.Fatal("expected the child selected after its parent confirmed")
+	}
+}
+
+func TestReferenceResolvedThroughPool(t *testing.T) {
+	pool, store := newTestPool()
+	funding := newTestFunding(store, 1000)
+	parent := newTestTxn([]*transaction.UTXOTxInput{spend(funding, 0)}, newTestOutput(990))
+	appendTestTxn(t, pool, parent)
+
+	input := spend(parent, 0)
+	child := newTestTxn([]*transaction.UTXOTxInput{input}, newTestOutput(980))
+	if _, err := child.GetReference(); err == nil {
+		t.Fatal("expected the ledger to miss the pooled parent")
+	}
+	appendTestTxn(t, pool, child)
+
+	reference, err := pool.getReference(child)
+	if err != nil {
+		t.Fatalf("get reference failed: %v", err)
+	}
+	if reference[input] != parent.Outputs[0] {
+		t.Fatal("expected the child input to resolve to the pooled parent output")
+	}
+	if pool.getInputUTXOList(input) != child {
+		t.Fatal("expected the child input to be tracked by the pool")
+	}
+	if fee := pool.txnList[child.Hash()].fee; fee != 10 {
+		t.Fatalf("expected child fee 10, got %v", fee)
+	}
+}
+
+//a registration in the store for the asset with the registered amount
+func newTestAsset(store *testLedgerStore, assetID common.Uint256, amount common.Fixed64) {
+	reg := &transaction.Transaction{
+		TxType:  transaction.RegisterAsset,
+		Payload: &payload.RegisterAsset{Amount: amount},
+	}
+	reg.SetHash(assetID)
+	store.addTxn(reg)
+}
+
+func newTestIssue(assetID common.Uint256, values ...common.Fixed64) *transaction.Transaction {
+	outputs := []*transaction.TxOutput{}
+	for _, v := range values {
+		outputs = append(outputs, &transaction.TxOutput{AssetID: assetID, Value: v})
+	}
+	return &transaction.Transaction{
+		TxType:  transaction.IssueAsset,
+		Payload: new(payload.IssueAsset),
+		Outputs: outputs,
+	}
+}
+
+func TestIssuanceRateLimit(t *testing.T) {
+	clock := newTestClock()
+	pool, store := newTestPool(withClock(clock))
+	oldLimit, oldWindow := config.Parameters.IssuanceRateLimit, config.Parameters.IssuanceRateWindow
+	config.Parameters.IssuanceRateLimit, config.Parameters.IssuanceRateWindow = 1, 60
+	defer func() {
+		config.Parameters.IssuanceRateLimit, config.Parameters.IssuanceRateWindow = oldLimit, oldWindow
+	}()
+	assetID := common.Uint256{2}
+	newTestAsset(store, assetID, 1000*100000000)
+
+	first := newTestIssue(assetID, 60000000)
+	appendTestTxn(t, pool, first)
+	clock.Advance(30 * time.Second)
+	appendTestTxn(t, pool, newTestIssue(assetID, 30000000))
+	//the issuance confirmed in the meantime still counts for the window
+	pool.CleanSubmittedTransactions(&ledger.Block{Transactions: []*transaction.Transaction{first}})
+	if errCode := pool.verifyTransactionWithTxnPool(newTestIssue(assetID, 20000000)); errCode != ErrIssuanceRateLimited {
+		t.Fatalf("expected ErrIssuanceRateLimited within the window, got %v", errCode)
+	}
+
+	clock.Advance(31 * time.Second)
+	if errCode := pool.verifyTransactionWithTxnPool(newTestIssue(assetID, 20000000)); errCode != ErrNoError {
+		t.Fatalf("expected the issuance admitted once the first left the window, got %v", errCode)
+	}
+}
+
+func TestQuantityIssuedCache(t *testing.T) {
+	clock := newTestClock()
+	pool, store := newTestPool(withClock(clock))
+	assetID := common.Uint256{2}
+	newTestAsset(store, assetID, 1000)
+
+	committed := newTestIssue(assetID, 1)
+	appendTestTxn(t, pool, committed)
+	appendTestTxn(t, pool, newTestIssue(assetID, 2))
+	if store.issuedLookups != 1 {
+		t.Fatalf("expected the ledger queried once within the TTL, got %d", store.issuedLookups)
+	}
+	clock.Advance(QUANTITYISSUEDCACHETIME + time.Second)
+	appendTestTxn(t, pool, newTestIssue(assetID, 3))
+	if store.issuedLookups != 2 {
+		t.Fatalf("expected the ledger queried again after the TTL, got %d", store.issuedLookups)
+	}
+
+	store.issued[assetID] = 1
+	pool.cleanTransactionList([]*transaction.Transaction{committed})
+	appendTestTxn(t, pool, newTestIssue(assetID, 4))
+	if store.issuedLookups != 3 {
+		t.Fatalf("expected the block issuing the asset to invalidate the cache, got %d", store.issuedLookups)
+	}
+}
+
+func TestMaxPendingIssuancePerAsset(t *testing.T) {
+	pool, store := newTestPool()
+	old := config.Parameters.MaxPendingIssuancePerAsset
+	config.Parameters.MaxPendingIssuancePerAsset = 1
+	defer func() { config.Parameters.MaxPendingIssuancePerAsset = old }()
+	assetID := common.Uint256{2}
+	newTestAsset(store, assetID, 1000*100000000)
+
+	appendTestTxn(t, pool, newTestIssue(assetID, 60000000))
+	if errCode := pool.verifyTransactionWithTxnPool(newTestIssue(assetID, 50000000)); errCode != ErrSummaryAsset {
+		t.Fatalf("expected issuance over the pool throttle to be rejected, got %v", errCode)
+	}
+	if amount := pool.getAssetIssueAmount(assetID); amount != 60000000 {
+		t.Fatalf("expected pending issue amount 60000000, got %v", amount)
+	}
+	if errCode := pool.verifyTransactionWithTxnPool(newTestIssue(assetID, 40000000)); errCode != ErrNoError {
+		t.Fatalf("expected issuance up to the throttle to pass, got %v", errCode)
+	}
+}
+
+//transactions with equal fee rate spending each output of the funding
+func newTestEqualFeeTxns(funding *transaction.Transaction) []*transaction.Transaction {
+	txns := []*transaction.Transaction{}
+	for i := range funding.Outputs {
+		txns = append(txns, newTestTxn([]*transaction.UTXOTxInput{spend(funding, uint16(i))}, newTestOutput(990)))
+	}
+	return txns
+}
+
+func seededSelectionOrder(t *testing.T, opts ...TXNPoolOption) []common.Uint256 {
+	pool, store := newTestPool(opts...)
+	for _, txn := range newTestEqualFeeTxns(newTestFunding(store, 1000, 1000, 1000, 1000, 1000, 1000)) {
+		appendTestTxn(t, pool, txn)
+	}
+	order := []common.Uint256{}
+	for _, entry := range pool.sortedTxnList() {
+		order = append(order, entry.txn.Hash())
+	}
+	return order
+}
+
+func equalOrder(a, b []common.Uint256) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestSelectionSeed(t *testing.T) {
+	unseeded := seededSelectionOrder(t)
+	for i := 1; i < len(unseeded); i++ {
+		if unseeded[i-1].CompareTo(unseeded[i]) >= 0 {
+			t.Fatal("expected equal fee transactions ordered by hash without a seed")
+		}
+	}
+
+	first := seededSelectionOrder(t, WithSelectionSeed(1))
+	if !equalOrder(first, seededSelectionOrder(t, WithSelectionSeed(1))) {
+		t.Fatal("expected the same seed to yield the same order")
+	}
+	differs := false
+	for seed := int64(2); seed < 10 && !differs; seed++ {
+		differs = !equalOrder(first, seededSelectionOrder(t, WithSelectionSeed(seed)))
+	}
+	if !differs {
+		t.Fatal("expected different seeds to yield a different order")
+	}
+
+	defer setMaxTxInBlock(2)()
+	pool, store := newTestPool(WithSelectionSeed(1))
+	for _, txn := range newTestEqualFeeTxns(newTestFunding(store, 1000, 1000, 1000, 1000, 1000, 1000)) {
+		appendTestTxn(t, pool, txn)
+	}
+	selected := pool.GetTxnPool(true)
+	if _, ok := selected[first[0]]; !ok || len(selected) != 2 {
+		t.Fatal("expected the selection to follow the seeded order")
+	}
+	if _, ok := selected[first[1]]; !ok {
+		t.Fatal("expected the selection to follow the seeded order")
+	}
+}
+
+func TestRotatingTiebreak(t *testing.T) {
+	pool, store := newTestPool(WithTiebreakKey(RotatingTiebreak(1)))
+	for _, txn := range newTestEqualFeeTxns(newTestFunding(store, 1000, 1000, 1000, 1000, 1000, 1000)) {
+		appendTestTxn(t, pool, txn)
+	}
+	leader := func(height uint32) common.Uint256 {
+		defer setTestLedger(height, &testLedger{})()
+		first := pool.sortedTxnList()[0].txn.Hash()
+		if pool.sortedTxnList()[0].txn.Hash() != first {
+			t.Fatal("expected the same order within a round")
+		}
+		return first
+	}
+
+	first := leader(100)
+	rotated := false
+	for height := uint32(101); height < 110 && !rotated; height++ {
+		rotated = leader(height) != first
+	}
+	if !rotated {
+		t.Fatal("expected a different equal fee transaction to lead in later rounds")
+	}
+}
+
+func TestTagTransaction(t *testing.T) {
+	pool, store := newTestPool()
+	txns := newTestEqualFeeTxns(newTestFunding(store, 1000, 1000, 1000))
+	for _, txn := range txns {
+		appendTestTxn(t, pool, txn)
+	}
+	pool.TagTransaction(txns[0].Hash(), "locally-submitted")
+	pool.TagTransaction(txns[1].Hash(), "locally-submitted")
+	pool.TagTransaction(txns[1].Hash(), "from-peer-X")
+	if pool.TagTransaction(common.Uint256{0xff}, "locally-submitted") {
+		t.Fatal("expected tagging a transaction not in the pool to fail")
+	}
+
+	if tagged := pool.GetTransactionsByTag("locally-submitted"); len(tagged) != 2 {
+		t.Fatalf("expected 2 locally submitted transactions, got %d", len(tagged))
+	}
+	tagged := pool.GetTransactionsByTag("from-peer-X")
+	if len(tagged) != 1 || tagged[0] != txns[1] {
+		t.Fatal("expected the transaction tagged from peer")
+	}
+
+	pool.removeTransaction(txns[1])
+	if tagged := pool.GetTransactionsByTag("from-peer-X"); len(tagged) != 0 {
+		t.Fatal("expected the tags dropped with the transaction")
+	}
+	if _, ok := pool.tagList[txns[1].Hash()]; ok {
+		t.Fatal("expected the tag entry removed")
+	}
+}
+
+func TestConfirmationProbability(t *testing.T) {
+	pool, store := newTestPool()
+	defer setMaxTxInBlock(2)()
+	funding := newTestFunding(store, 1000, 1000, 1000, 1000)
+	txns := []*transaction.Transaction{}
+	for i, value := range []common.Fixed64{900, 950, 980, 990} {
+		txn := newTestTxn([]*transaction.UTXOTxInput{spend(funding, uint16(i))}, newTestOutput(value))
+		appendTestTxn(t, pool, txn)
+		txns = append(txns, txn)
+	}
+	top, bottom := txns[0].Hash(), txns[3].Hash()
+
+	if p := pool.ConfirmationProbability(top, 1); p != 1 {
+		t.Fatalf("expected the top fee transaction to confirm, got %v", p)
+	}
+	if p := pool.ConfirmationProbability(bottom, 1); p != 0 {
+		t.Fatalf("expected the bottom fee transaction not to fit the next block, got %v", p)
+	}
+	if p := pool.ConfirmationProbability(bottom, 2); p <= 0 || p >= 0.5 {
+		t.Fatalf("expected a low probability within two blocks, got %v", p)
+	}
+	if p := pool.ConfirmationProbability(common.Uint256{0xff}, 10); p != 0 {
+		t.Fatalf("expected 0 for a transaction not in the pool, got %v", p)
+	}
+}
+
+func TestSelectionRank(t *testing.T) {
+	pool, store := newTestPool()
+	funding := newTestFunding(store, 1000, 1000, 1000)
+	high := newTestTxn([]*transaction.UTXOTxInput{spend(funding, 0)}, newTestOutput(900))
+	mid := newTestTxn([]*transaction.UTXOTxInput{spend(funding, 1)}, newTestOutput(950))
+	parent := newTestTxn([]*transaction.UTXOTxInput{spend(funding, 2)}, newTestOutput(990))
+	//the child pays the highest fee rate but can't be included before its parent
+	child := newTestTxn([]*transaction.UTXOTxInput{spend(parent, 0)}, newTestOutput(500))
+	for _, txn := range []*transaction.Transaction{high, mid, parent, child} {
+		appendTestTxn(t, pool, txn)
+	}
+
+	for expected, txn := range []*transaction.Transaction{high, mid, parent, child} {
+		rank, total := pool.SelectionRank(txn.Hash())
+		if rank != expected || total != 4 {
+			t.Fatalf("expected rank %d of 4, got %d of %d", expected, rank, total)
+		}
+	}
+	if rank, total := pool.SelectionRank(common.Uint256{0xff}); rank != NOTRANKED || total != 4 {
+		t.Fatalf("expected NOTRANKED for a transaction not in the pool, got %d of %d", rank, total)
+	}
+}
+
+func TestFeeRateToOutrank(t *testing.T) {
+	pool, store := newTestPool()
+	funding := newTestFunding(store, 1000, 1000, 1000)
+	target := newTestTxn([]*transaction.UTXOTxInput{spend(funding, 0)}, newTestOutput(950))
+	appendTestTxn(t, pool, target)
+	pool.PrioritiseTransaction(target.Hash(), 20)
+
+	needed := pool.FeeRateToOutrank(target.Hash())
+	if needed != pool.EffectiveFeeRate(target.Hash())+1 {
+		t.Fatalf("expected just above the effective fee rate %v, got %v", pool.EffectiveFeeRate(target.Hash()), needed)
+	}
+	//find the fee of a competitor of the same weight reaching the needed fee rate
+	fee := common.Fixed64(50)
+	for pool.newTxnEntry(newTestTxn([]*transaction.UTXOTxInput{spend(funding, 1)}, newTestOutput(1000-fee))).feeRate() < needed {
+		fee++
+	}
+	competitor := newTestTxn([]*transaction.UTXOTxInput{spend(funding, 1)}, newTestOutput(1000-fee))
+	appendTestTxn(t, pool, competitor)
+	if rank, _ := pool.SelectionRank(competitor.Hash()); rank != 0 {
+		t.Fatalf("expected the competitor ranked ahead of the target, got rank %d", rank)
+	}
+	if pool.FeeRateToOutrank(common.Uint256{0xff}) != 0 {
+		t.Fatal("expected 0 for a transaction not in the pool")
+	}
+}
+
+func TestBlockSelectionFloor(t *testing.T) {
+	pool, store := newTestPool()
+	defer setMaxTxInBlock(2)()
+	funding := newTestFunding(store, 1000, 1000, 1000)
+	high := newTestTxn([]*transaction.UTXOTxInput{spend(funding, 0)}, newTestOutput(900))
+	mid := newTestTxn([]*transaction.UTXOTxInput{spend(funding, 1)}, newTestOutput(950))
+	low := newTestTxn([]*transaction.UTXOTxInput{spend(funding, 2)}, newTestOutput(990))
+	appendTestTxn(t, pool, high)
+	appendTestTxn(t, pool, mid)
+	if txn, _ := pool.BlockSelectionFloor(); txn != nil {
+		t.Fatal("expected no floor while the pool under-fills the block")
+	}
+
+	appendTestTxn(t, pool, low)
+	txn, rate := pool.BlockSelectionFloor()
+	if txn != mid || rate != pool.EffectiveFeeRate(mid.Hash()) {
+		t.Fatalf("expected the last transaction in the block as the floor, got %v", rate)
+	}
+	pool.PrioritiseTransaction(low.Hash(), 1000)
+	if txn, _ := pool.BlockSelectionFloor(); txn != high {
+		t.Fatal("expected the floor to follow the effective fee rates")
+	}
+}
+
+func TestIssueSummaryCleaned(t *testing.T) {
+	pool, store := newTestPool()
+	assetID := common.Uint256{3}
+	newTestAsset(store, assetID, 1000)
+	first := newTestIssue(assetID, 100)
+	second := newTestIssue(assetID, 200)
+	appendTestTxn(t, pool, first)
+	appendTestTxn(t, pool, second)
+
+	pool.CleanSubmittedTransactions(&ledger.Block{Transactions: []*transaction.Transaction{first}})
+	if amount := pool.getAssetIssueAmount(assetID); amount != 200 {
+		t.Fatalf("expected pending issue amount 200, got %v", amount)
+	}
+	pool.CleanSubmittedTransactions(&ledger.Block{Transactions: []*transaction.Transaction{second}})
+	if _, ok := pool.issueSummary[assetID]; ok {
+		t.Fatal("expected the asset key removed from the issue summary")
+	}
+}
+
+func TestRejectWhileSyncing(t *testing.T) {
+	syncing := true
+	pool, store := newTestPool(WithSyncState(func() bool { return syncing }))
+	txn := newTestTxn([]*transaction.UTXOTxInput{spend(newTestFunding(store, 1000), 0)}, newTestOutput(990))
+
+	if errCode := pool.AppendTxnPool(txn, true); errCode != ErrSyncing {
+		t.Fatalf("expected ErrSyncing while syncing, got %v", errCode)
+	}
+	if pool.GetTransactionCount() != 0 {
+		t.Fatal("expected no transaction admitted while syncing")
+	}
+	syncing = false
+	if errCode := pool.checkAdmission(); errCode != ErrNoError {
+		t.Fatalf("expected admissions to resume after sync, got %v", errCode)
+	}
+}
+
+func TestTransactionsNotIn(t *testing.T) {
+	pool, store := newTestPool()
+	txns := newTestEqualFeeTxns(newTestFunding(store, 1000, 1000, 1000, 1000))
+	for _, txn := range txns[:3] {
+		appendTestTxn(t, pool, txn)
+	}
+	block := &ledger.Block{Transactions: []*transaction.Transaction{txns[1], txns[3]}}
+
+	hashes := pool.TransactionsNotIn(block)
+	if len(hashes) != 2 {
+		t.Fatalf("expected 2 transactions not in the block, got %d", len(hashes))
+	}
+	for _, hash := range hashes {
+		if hash != txns[0].Hash() && hash != txns[2].Hash() {
+			t.Fatalf("unexpected transaction %x not in the block", hash)
+		}
+	}
+}
+
+func TestValidateBlockAgainstPool(t *testing.T) {
+	pool, store := newTestPool()
+	txns := newTestEqualFeeTxns(newTestFunding(store, 1000, 1000, 1000, 1000))
+	for _, txn := range txns[:2] {
+		appendTestTxn(t, pool, txn)
+	}
+	bookKeeping := &transaction.Transaction{TxType: transaction.BookKeeping, Payload: new(payload.BookKeeping)}
+	block := &ledger.Block{Transactions: []*transaction.Transaction{bookKeeping, txns[3], txns[0], txns[2]}}
+
+	unseen := pool.ValidateBlockAgainstPool(block)
+	if len(unseen) != 2 || unseen[0] != txns[3].Hash() || unseen[1] != txns[2].Hash() {
+		t.Fatal("expected the transactions the pool never held in block order")
+	}
+	if pool.GetTransactionCount() != 2 {
+		t.Fatal("expected the pool unchanged")
+	}
+}
+
+func TestVerifyTimeout(t *testing.T) {
+	pool, store := newTestPool()
+	old := config.Parameters.VerifyTimeout
+	config.Parameters.VerifyTimeout = 10
+	defer func() { config.Parameters.VerifyTimeout = old }()
+	funding := newTestFunding(store, 1000, 1000)
+
+	testVerifierOf(pool).ledger = func(txn *transaction.Transaction) ErrCode {
+		time.Sleep(100 * time.Millisecond)
+		return ErrNoError
+	}
+	slow := newTestTxn([]*transaction.UTXOTxInput{spend(funding, 0)}, newTestOutput(990))
+	if errCode := pool.AppendTxnPool(slow, true); errCode != ErrVerifyTimeout {
+		t.Fatalf("expected ErrVerifyTimeout, got %v", errCode)
+	}
+	if pool.GetTransaction(slow.Hash()) != nil {
+		t.Fatal("expected the slow transaction not admitted")
+	}
+
+	//the timed out verification is still running with the old verifier
+	pool.verifier = &testVerifier{}
+	fast := newTestTxn([]*transaction.UTXOTxInput{spend(funding, 1)}, newTestOutput(990))
+	if errCode := pool.AppendTxnPool(fast, true); errCode != ErrNoError {
+		t.Fatalf("expected the fast transaction admitted, got %v", errCode)
+	}
+
+	durations := pool.VerifyDurations()
+	if durations.Count != 2 || durations.Counts[0] != 1 {
+		t.Fatalf("expected 2 recorded verifications with one fast, got %+v", durations)
+	}
+	if durations.Sum < 10*time.Millisecond {
+		t.Fatalf("expected the timed out verification recorded, got %+v", durations)
+	}
+}
+
+func TestVerifyTimeoutBounded(t *testing.T) {
+	pool, store := newTestPool()
+	old := config.Parameters.VerifyTimeout
+	config.Parameters.VerifyTimeout = 10
+	defer func() { config.Parameters.VerifyTimeout = old }()
+	pool.verifySlots = make(chan struct{}, 1)
+	funding := newTestFunding(store, 1000, 1000, 1000)
+	release := make(chan struct{})
+	verified := make(map[common.Uint256]bool)
+	var mutex sync.Mutex
+	testVerifierOf(pool).ledger = func(txn *transaction.Transaction) ErrCode {
+		mutex.Lock()
+		verified[txn.Hash()] = true
+		mutex.Unlock()
+		<-release
+		return ErrNoError
+	}
+	slow := newTestTxn([]*transaction.UTXOTxInput{spend(funding, 0)}, newTestOutput(990))
+	if errCode := pool.AppendTxnPool(slow, true); errCode != ErrVerifyTimeout {
+		t.Fatalf("expected ErrVerifyTimeout, got %v", errCode)
+	}
+	//the timed out verification still holds the only slot
+	waiting := newTestTxn([]*transaction.UTXOTxInput{spend(funding, 1)}, newTestOutput(990))
+	if errCode := pool.AppendTxnPool(waiting, true); errCode != ErrVerifyTimeout {
+		t.Fatalf("expected ErrVerifyTimeout waiting for a slot, got %v", errCode)
+	}
+	mutex.Lock()
+	started := verified[waiting.Hash()]
+	mutex.Unlock()
+	if started {
+		t.Fatal("expected no verification started beyond the slots")
+	}
+
+	//the transactions of a block are verified without the timeout
+	block := newTestTxn([]*transaction.UTXOTxInput{spend(funding, 2)}, newTestOutput(990))
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		close(release)
+	}()
+	if errCode := pool.AppendTxnPool(block, false); errCode != ErrNoError {
+		t.Fatalf("expected the block transaction verified without the timeout, got %v", errCode)
+	}
+}
+
+func TestFreezePool(t *testing.T) {
+	pool, store := newTestPool()
+	testVerifierOf(pool).ledger = func(txn *transaction.Transaction) ErrCode { return ErrNoError }
+	funding := newTestFunding(store, 1000, 1000)
+	pooled := newTestTxn([]*transaction.UTXOTxInput{spend(funding, 0)}, newTestOutput(990))
+	if errCode := pool.AppendTxnPool(pooled, true); errCode != ErrNoError {
+		t.Fatalf("append transaction failed: %v", errCode)
+	}
+
+	pool.Freeze()
+	txn := newTestTxn([]*transaction.UTXOTxInput{spend(funding, 1)}, newTestOutput(990))
+	if errCode := pool.AppendTxnPool(txn, true); errCode != ErrPoolFrozen {
+		t.Fatalf("expected ErrPoolFrozen while frozen, got %v", errCode)
+	}
+	if pool.GetTransaction(pooled.Hash()) == nil || len(pool.GetTxnPool(false)) != 1 {
+		t.Fatal("expected reads to be served while frozen")
+	}
+	//the transactions of a block proposal are still verified
+	if errCode := pool.AppendTxnPool(txn, false); errCode != ErrNoError {
+		t.Fatalf("expected the block verification unaffected while frozen, got %v", errCode)
+	}
+	pool.removeTransaction(txn)
+
+	pool.Unfreeze()
+	if errCode := pool.AppendTxnPool(txn, true); errCode != ErrNoError {
+		t.Fatalf("expected admissions to resume after unfreeze, got %v", errCode)
+	}
+}
+
+func TestRejectOverflowOutputValue(t *testing.T) {
+	pool, store := newTestPool()
+	verified := 0
+	testVerifierOf(pool).ledger = func(txn *transaction.Transaction) ErrCode {
+		verified++
+		return ErrNoError
+	}
+	funding := newTestFunding(store, 1000)
+	half := common.Fixed64(math.MaxInt64/2 + 1)
+	txn := newTestTxn([]*transaction.UTXOTxInput{spend(funding, 0)}, newTestOutput(half), newTestOutput(half))
+
+	if errCode := pool.AppendTxnPool(txn, true); errCode != ErrInvalidTransaction {
+		t.Fatalf("expected ErrInvalidTransaction, got %v", errCode)
+	}
+	if verified != 0 {
+		t.Fatal("expected the transaction rejected before verification")
+	}
+}
+
+func setRBF(enable bool, requireHigherFeeRate bool) func() {
+	oldEnable, oldRate := config.Parameters.EnableRBF, config.Parameters.RBFRequireHigherFeeRate
+	config.Parameters.EnableRBF = enable
+	config.Parameters.RBFRequireHigherFeeRate = requireHigherFeeRate
+	return func() {
+		config.Parameters.EnableRBF = oldEnable
+		config.Parameters.RBFRequireHigherFeeRate = oldRate
+	}
+}
+
+//a replacement of the original with a higher fee but a lower fee rate
+func newTestLowRateReplacement(funding *transaction.Transaction) (*transaction.Transaction, *transaction.Transaction) {
+	original := newTestTxn([]*transaction.UTXOTxInput{spend(funding, 0)}, newTestOutput(990))
+	outputs := []*transaction.TxOutput{}
+	for i := 0; i < 10; i++ {
+		outputs = append(outputs, newTestOutput(common.Fixed64(94+i)))
+	}
+	return original, newTestTxn([]*transaction.UTXOTxInput{spend(funding, 0)}, outputs...)
+}
+
+func TestReplaceByFee(t *testing.T) {
+	pool, store := newTestPool()
+	defer setRBF(false, false)()
+	original, replacement := newTestLowRateReplacement(newTestFunding(store, 1000))
+	appendTestTxn(t, pool, original)
+	if errCode := pool.verifyTransactionWithTxnPool(replacement); errCode != ErrDoubleSpend {
+		t.Fatalf("expected ErrDoubleSpend without RBF, got %v", errCode)
+	}
+
+	config.Parameters.EnableRBF = true
+	appendTestTxn(t, pool, replacement)
+	if pool.GetTransaction(original.Hash()) != nil || pool.GetTransaction(replacement.Hash()) == nil {
+		t.Fatal("expected the original replaced")
+	}
+	if pool.getInputUTXOList(replacement.UTXOInputs[0]) != replacement {
+		t.Fatal("expected the input spent by the replacement")
+	}
+	if pool.verifyTransactionWithTxnPool(original) != ErrInsufficientReplacementFee {
+		t.Fatal("expected the original not to replace a higher fee transaction")
+	}
+}
+
+func TestEvictedReplacementKeepsOriginal(t *testing.T) {
+	pool, store := newTestPool()
+	defer setRBF(true, false)()
+	funding := newTestFunding(store, 1000, 1000)
+	original := newTestTxn([]*transaction.UTXOTxInput{spend(funding, 0)}, newTestOutput(990))
+	other := newTestTxn([]*transaction.UTXOTxInput{spend(funding, 1)}, newTestOutput(500))
+	child := newTestTxn([]*transaction.UTXOTxInput{spend(original, 0)}, newTestOutput(989))
+	outputs := []*transaction.TxOutput{}
+	for i := 0; i < 20; i++ {
+		outputs = append(outputs, newTestOutput(48))
+	}
+	replacement := newTestTxn([]*transaction.UTXOTxInput{spend(funding, 0)}, outputs...)
+	for _, txn := range []*transaction.Transaction{other, original, child} {
+		if errCode := pool.AppendTxnPool(txn, true); errCode != ErrNoError {
+			t.Fatalf("append transaction failed: %v", errCode)
+		}
+	}
+	//the larger replacement has the lowest fee rate of the full pool
+	defer setPoolLimits(0, pool.txnBytes, 0)()
+	if errCode := pool.AppendTxnPool(replacement, true); errCode != ErrPoolFull {
+		t.Fatalf("expected the replacement evicted from the full pool, got %v", errCode)
+	}
+	for _, txn := range []*transaction.Transaction{other, original, child} {
+		if pool.GetTransaction(txn.Hash()) == nil {
+			t.Fatal("expected the replaced transactions kept")
+		}
+	}
+	if pool.getInputUTXOList(original.UTXOInputs[0]) != original || pool.getInputUTXOList(child.UTXOInputs[0]) != child {
+		t.Fatal("expected the inputs spent by the kept transactions")
+	}
+}
+
+func setConflictPolicy(policy string) func() {
+	old := config.Parameters.ConflictPolicy
+	config.Parameters.ConflictPolicy = policy
+	return func() { config.Parameters.ConflictPolicy = old }
+}
+
+//a pooled original with a high fee child, and a replacement paying more than
+//the original but less than the original and the child together
+func newTestConflict(t *testing.T, pool *TXNPool, store *testLedgerStore) (*transaction.Transaction, *transaction.Transaction, *transaction.Transaction) {
+	funding := newTestFunding(store, 1000)
+	original := newTestTxn([]*transaction.UTXOTxInput{spend(funding, 0)}, newTestOutput(990))
+	child := newTestTxn([]*transaction.UTXOTxInput{spend(original, 0)}, newTestOutput(890))
+	appendTestTxn(t, pool, original)
+	appendTestTxn(t, pool, child)
+	return original, child, newTestTxn([]*transaction.UTXOTxInput{spend(funding, 0)}, newTestOutput(950))
+}
+
+func TestConflictPolicyFirstSeen(t *testing.T) {
+	pool, store := newTestPool()
+	defer setRBF(true, false)()
+	defer setConflictPolicy(CONFLICTFIRSTSEEN)()
+	original, _, replacement := newTestConflict(t, pool, store)
+	if errCode := pool.verifyTransactionWithTxnPool(replacement); errCode != ErrDoubleSpend {
+		t.Fatalf("expected ErrDoubleSpend with the first seen policy, got %v", errCode)
+	}
+	if pool.GetTransaction(original.Hash()) == nil {
+		t.Fatal("expected the first seen transaction kept")
+	}
+}
+
+func TestConflictPolicyHighestFee(t *testing.T) {
+	pool, store := newTestPool()
+	defer setRBF(false, true)()
+	defer setConflictPolicy(CONFLICTHIGHESTFEE)()
+	original, child, replacement := newTestConflict(t, pool, store)
+	appendTestTxn(t, pool, replacement)
+	if pool.GetTransaction(original.Hash()) != nil || pool.GetTransaction(child.Hash()) != nil {
+		t.Fatal("expected the lower fee conflict replaced with its descendants")
+	}
+	if pool.verifyTransactionWithTxnPool(original) != ErrInsufficientReplacementFee {
+		t.Fatal("expected the lower fee transaction not to replace the pooled one")
+	}
+}
+
+func TestConflictPolicyRBFOnly(t *testing.T) {
+	pool, store := newTestPool()
+	defer setRBF(false, false)()
+	defer setConflictPolicy(CONFLICTRBFONLY)()
+	original, child, replacement := newTestConflict(t, pool, store)
+	if errCode := pool.verifyTransactionWithTxnPool(replacement); errCode != ErrInsufficientReplacementFee {
+		t.Fatalf("expected the replacement to pay for the descendants too, got %v", errCode)
+	}
+	bumped := newTestTxn(replacement.UTXOInputs, newTestOutput(850))
+	appendTestTxn(t, pool, bumped)
+	if pool.GetTransaction(original.Hash()) != nil || pool.GetTransaction(child.Hash()) != nil {
+		t.Fatal("expected the conflict replaced with its descendants")
+	}
+}
+
+func TestReplaceRequireHigherFeeRate(t *testing.T) {
+	pool, store := newTestPool()
+	defer setRBF(true, true)()
+	original, replacement := newTestLowRateReplacement(newTestFunding(store, 1000))
+	appendTestTxn(t, pool, original)
+	originalEntry := pool.txnList[original.Hash()]
+	replacementEntry := pool.newTxnEntry(replacement)
+	if replacementEntry.fee <= originalEntry.fee || replacementEntry.feeRate() >= originalEntry.feeRate() {
+		t.Fatal("expected the replacement to pay a higher fee with a lower fee rate")
+	}
+
+	if errCode := pool.verifyTransactionWithTxnPool(replacement); errCode != ErrInsufficientReplacementFee {
+		t.Fatalf("expected the lower fee rate replacement rejected, got %v", errCode)
+	}
+	if required := pool.RequiredReplacementFee(replacement); getFeeRate(required, replacementEntry.weight) <= originalEntry.feeRate() {
+		t.Fatalf("expected the required fee %v to give a higher fee rate", required)
+	}
+	if pool.GetTransaction(original.Hash()) == nil {
+		t.Fatal("expected the original kept")
+	}
+}
+
+func TestDescendantCount(t *testing.T) {
+	pool, store := newTestPool()
+	funding := newTestFunding(store, 1000)
+	root := newTestTxn([]*transaction.UTXOTxInput{spend(funding, 0)}, newTestOutput(500), newTestOutput(490))
+	left := newTestTxn([]*transaction.UTXOTxInput{spend(root, 0)}, newTestOutput(490))
+	right := newTestTxn([]*transaction.UTXOTxInput{spend(root, 1)}, newTestOutput(480))
+	leaf := newTestTxn([]*transaction.UTXOTxInput{spend(left, 0)}, newTestOutput(480))
+	for _, txn := range []*transaction.Transaction{root, left, right, leaf} {
+		appendTestTxn(t, pool, txn)
+	}
+
+	if count := pool.DescendantCount(root.Hash()); count != 3 {
+		t.Fatalf("expected 3 descendants of the root, got %d", count)
+	}
+	if count := pool.DescendantCount(left.Hash()); count != 1 {
+		t.Fatalf("expected 1 descendant of the left branch, got %d", count)
+	}
+	if count := pool.DescendantCount(leaf.Hash()); count != 0 {
+		t.Fatalf("expected no descendant of the leaf, got %d", count)
+	}
+}
+
+func TestRemovalImpact(t *testing.T) {
+	pool, store := newTestPool()
+	funding := newTestFunding(store, 1000)
+	root := newTestTxn([]*transaction.UTXOTxInput{spend(funding, 0)}, newTestOutput(500), newTestOutput(490))
+	left := newTestTxn([]*transaction.UTXOTxInput{spend(root, 0)}, newTestOutput(490))
+	right := newTestTxn([]*transaction.UTXOTxInput{spend(root, 1)}, newTestOutput(480))
+	leaf := newTestTxn([]*transaction.UTXOTxInput{spend(left, 0)}, newTestOutput(480))
+	for _, txn := range []*transaction.Transaction{root, left, right, leaf} {
+		appendTestTxn(t, pool, txn)
+	}
+
+	impact := pool.RemovalImpact(left.Hash())
+	if len(impact) != 1 || impact[0].Hash() != leaf.Hash() {
+		t.Fatal("expected removing the left branch to take the leaf")
+	}
+	impact = pool.RemovalImpact(root.Hash())
+	position := make(map[common.Uint256]int)
+	for i, txn := range impact {
+		position[txn.Hash()] = i
+	}
+	if len(impact) != 3 || len(position) != 3 {
+		t.Fatalf("expected 3 transactions removed with the root, got %d", len(impact))
+	}
+	for _, txn := range []*transaction.Transaction{left, right, leaf} {
+		if _, ok := position[txn.Hash()]; !ok {
+			t.Fatal("expected every descendant of the root in the impact")
+		}
+	}
+	if position[leaf.Hash()] > position[left.Hash()] {
+		t.Fatal("expected the leaf listed before its parent")
+	}
+	if len(pool.RemovalImpact(leaf.Hash())) != 0 {
+		t.Fatal("expected no impact beyond the leaf itself")
+	}
+	if len(pool.GetTxnPool(false)) != 4 {
+		t.Fatal("expected the pool unchanged")
+	}
+}
+
+func TestRemoveTransactions(t *testing.T) {
+	pool, store := newTestPool()
+	funding := newTestFunding(store, 1000, 1000, 1000)
+	parent := newTestTxn([]*transaction.UTXOTxInput{spend(funding, 0)}, newTestOutput(990))
+	child := newTestTxn([]*transaction.UTXOTxInput{spend(parent, 0)}, newTestOutput(980))
+	other := newTestTxn([]*transaction.UTXOTxInput{spend(funding, 1)}, newTestOutput(985))
+	kept := newTestTxn([]*transaction.UTXOTxInput{spend(funding, 2)}, newTestOutput(970))
+	for _, txn := range []*transaction.Transaction{parent, child, other, kept} {
+		appendTestTxn(t, pool, txn)
+	}
+	pool.TagTransaction(child.Hash(), "wallet")
+
+	removed := pool.RemoveTransactions([]common.Uint256{parent.Hash(), child.Hash(), other.Hash(), {9}})
+	if removed != 3 {
+		t.Fatalf("expected 3 transactions removed, got %d", removed)
+	}
+	if pool.GetTransactionCount() != 1 || pool.GetTransaction(kept.Hash()) == nil {
+		t.Fatal("expected only the transaction not listed kept")
+	}
+	if len(pool.inputUTXOList) != 1 || pool.getInputUTXOList(spend(funding, 2)) != kept {
+		t.Fatal("expected the inputs of the removed transactions released")
+	}
+	if len(pool.outputSets) != 1 || len(pool.tagList) != 0 || pool.txnBytes != len(kept.ToArray()) {
+		t.Fatal("expected the derived maps to hold only the kept transaction")
+	}
+	if pool.typeCounts[transaction.TransferAsset] != 1 {
+		t.Fatalf("expected the type count updated, got %d", pool.typeCounts[transaction.TransferAsset])
+	}
+}
+
+func TestInvalidatedBySpend(t *testing.T) {
+	pool, store := newTestPool()
+	funding := newTestFunding(store, 1000, 1000)
+	spender := newTestTxn([]*transaction.UTXOTxInput{spend(funding, 0)}, newTestOutput(990))
+	descendant := newTestTxn([]*transaction.UTXOTxInput{spend(spender, 0)}, newTestOutput(980))
+	unrelated := newTestTxn([]*transaction.UTXOTxInput{spend(funding, 1)}, newTestOutput(990))
+	for _, txn := range []*transaction.Transaction{spender, descendant, unrelated} {
+		appendTestTxn(t, pool, txn)
+	}
+
+	invalidated := pool.InvalidatedBySpend(spend(funding, 0))
+	if len(invalidated) != 2 || invalidated[0] != descendant || invalidated[1] != spender {
+		t.Fatalf("expected the descendant then the spender, got %d transactions", len(invalidated))
+	}
+	if len(pool.InvalidatedBySpend(spend(funding, 2))) != 0 {
+		t.Fatal("expected nothing invalidated by an outpoint no pooled transaction spends")
+	}
+	if pool.GetTransactionCount() != 3 {
+		t.Fatal("expected the pool unchanged")
+	}
+}
+
+func TestAncestorChain(t *testing.T) {
+	pool, store := newTestPool()
+	funding := newTestFunding(store, 1000, 1000)
+	grandparent := newTestTxn([]*transaction.UTXOTxInput{spend(funding, 0)}, newTestOutput(995))
+	parent := newTestTxn([]*transaction.UTXOTxInput{spend(grandparent, 0)}, newTestOutput(900))
+	other := newTestTxn([]*transaction.UTXOTxInput{spend(funding, 1)}, newTestOutput(900))
+	child := newTestTxn([]*transaction.UTXOTxInput{spend(parent, 0), spend(other, 0)}, newTestOutput(1700))
+	for _, txn := range []*transaction.Transaction{grandparent, parent, other, child} {
+		appendTestTxn(t, pool, txn)
+	}
+
+	chain := pool.AncestorChain(child.Hash())
+	if len(chain) != 3 || chain[2] != grandparent {
+		t.Fatalf("expected the parents then the grandparent, got %d ancestors", len(chain))
+	}
+	if !(chain[0] == parent && chain[1] == other) && !(chain[0] == other && chain[1] == parent) {
+		t.Fatal("expected both parents before the grandparent")
+	}
+	if chain := pool.AncestorChain(parent.Hash()); len(chain) != 1 || chain[0] != grandparent {
+		t.Fatal("expected the grandparent as the only ancestor of the parent")
+	}
+	if len(pool.AncestorChain(grandparent.Hash())) != 0 {
+		t.Fatal("expected no pooled ancestor of a transaction spending confirmed outputs")
+	}
+}
+
+func TestDependencyGraph(t *testing.T) {
+	pool, store := newTestPool()
+	funding := newTestFunding(store, 1000, 1000)
+	first := newTestTxn([]*transaction.UTXOTxInput{spend(funding, 0)}, newTestOutput(500), newTestOutput(490))
+	second := newTestTxn([]*transaction.UTXOTxInput{spend(first, 0), spend(first, 1)}, newTestOutput(980))
+	third := newTestTxn([]*transaction.UTXOTxInput{spend(second, 0)}, newTestOutput(970))
+	unrelated := newTestTxn([]*transaction.UTXOTxInput{spend(funding, 1)}, newTestOutput(990))
+	for _, txn := range []*transaction.Transaction{first, second, third, unrelated} {
+		appendTestTxn(t, pool, txn)
+	}
+
+	graph := pool.DependencyGraph()
+	if len(graph.Nodes) != 4 {
+		t.Fatalf("expected a node per pooled transaction, got %d", len(graph.Nodes))
+	}
+	expected := map[DependencyEdge]struct{}{
+		{Parent: first.Hash(), Child: second.Hash()}: {},
+		{Parent: second.Hash(), Child: third.Hash()}: {},
+	}
+	if len(graph.Edges) != len(expected) {
+		t.Fatalf("expected an edge per spent parent, got %d", len(graph.Edges))
+	}
+	for _, edge := range graph.Edges {
+		if _, ok := expected[edge]; !ok {
+			t.Fatalf("unexpected edge %x -> %x", edge.Parent, edge.Child)
+		}
+	}
+}
+
+func TestValidateInputsSpendable(t *testing.T) {
+	ledgerSpent := map[uint16]bool{2: true}
+	pool, store := newTestPool(WithUnspentIndex(func(txid common.Uint256, index uint16) (bool, error) {
+		if txid == (common.Uint256{0xee}) {
+			return false, errors.New("leveldb: closed")
+		}
+		return !ledgerSpent[index], nil
+	}))
+	funding := newTestFunding(store, 1000, 1000, 1000)
+	pooled := newTestTxn([]*transaction.UTXOTxInput{spend(funding, 0)}, newTestOutput(990))
+	appendTestTxn(t, pool, pooled)
+
+	inputs := []*transaction.UTXOTxInput{spend(funding, 0), spend(funding, 1), spend(funding, 2), spend(pooled, 0)}
+	spent, err := pool.ValidateInputsSpendable(inputs)
+	if err != nil {
+		t.Fatalf("expected the inputs checked, got %v", err)
+	}
+	if len(spent) != 2 || spent[0] != inputs[0] || spent[1] != inputs[2] {
+		t.Fatalf("expected the inputs spent in the pool and in the ledger, got %d", len(spent))
+	}
+
+	if _, err := pool.ValidateInputsSpendable([]*transaction.UTXOTxInput{{ReferTxID: common.Uint256{0xee}}}); err == nil {
+		t.Fatal("expected the ledger error returned")
+	}
+}
+
+//ledger store answering ContainsUnspent with the error
+type unspentErrLedger struct {
+	ledger.ILedgerStore
+	err error
+}
+
+func (l *unspentErrLedger) ContainsUnspent(txid common.Uint256, index uint16) (bool, error) {
+	return false, l.err
+}
+
+func TestLedgerUnspentNotFound(t *testing.T) {
+	old := ledger.DefaultLedger
+	defer func() { ledger.DefaultLedger = old }()
+	store := &unspentErrLedger{err: NewDetailErr(ledger.ErrUnspentNotFound, ErrNoCode, "")}
+	ledger.DefaultLedger = &ledger.Ledger{Blockchain: &ledger.Blockchain{}, Store: store}
+	if unspent, err := ledgerUnspent(common.Uint256{1}, 0); unspent || err != nil {
+		t.Fatalf("expected the missing unspent outputs reported spent, got %v, %v", unspent, err)
+	}
+	store.err = errors.New("disk failure")
+	if _, err := ledgerUnspent(common.Uint256{1}, 0); err == nil {
+		t.Fatal("expected the store failure returned")
+	}
+}
+
+func TestReplayJournal(t *testing.T) {
+	journal := new(bytes.Buffer)
+	pool, store := newTestPool(WithJournal(journal))
+	funding := newTestFunding(store, 1000, 1000, 1000, 1000)
+	kept := newTestTxn([]*transaction.UTXOTxInput{spend(funding, 0)}, newTestOutput(990))
+	child := newTestTxn([]*transaction.UTXOTxInput{spend(kept, 0)}, newTestOutput(980))
+	removed := newTestTxn([]*transaction.UTXOTxInput{spend(funding, 1)}, newTestOutput(970))
+	invalid := newTestTxn([]*transaction.UTXOTxInput{spend(funding, 2)}, newTestOutput(960))
+	for _, txn := range []*transaction.Transaction{kept, child, removed, invalid} {
+		if errCode := pool.AppendTxnPool(txn, true); errCode != ErrNoError {
+			t.Fatalf("expected the transaction admitted, got %v", errCode)
+		}
+	}
+	pool.RemoveTransactions([]common.Uint256{removed.Hash()})
+
+	replayed, _ := newTestPool()
+	transaction.TxStore = store
+	if err := replayed.ReplayJournal(bytes.NewReader(journal.Bytes())); err != nil {
+		t.Fatalf("replay failed: %v", err)
+	}
+	if !equalOrder(replayed.Snapshot().Hashes, pool.Snapshot().Hashes) {
+		t.Fatal("expected the replayed pool to match the journaled one")
+	}
+
+	//the entries failing against the current ledger are skipped
+	skipping, _ := newTestPool()
+	transaction.TxStore = store
+	testVerifierOf(skipping).ledger = func(txn *transaction.Transaction) ErrCode {
+		if txn.Hash() == invalid.Hash() {
+			return ErrDoubleSpend
+		}
+		return ErrNoError
+	}
+	if err := skipping.ReplayJournal(bytes.NewReader(journal.Bytes())); err != nil {
+		t.Fatalf("replay failed: %v", err)
+	}
+	if skipping.GetTransactionCount() != 2 || skipping.GetTransaction(invalid.Hash()) != nil {
+		t.Fatal("expected the transaction failing the ledger verification skipped")
+	}
+	if err := skipping.ReplayJournal(bytes.NewReader([]byte{0xff})); err == nil {
+		t.Fatal("expected an unknown record to fail the replay")
+	}
+	if err := skipping.ReplayJournal(io.MultiReader(bytes.NewReader(journal.Bytes()), failingReader{})); err == nil {
+		t.Fatal("expected the read error to fail the replay")
+	}
+}
+
+type failingReader struct{}
+
+func (failingReader) Read(p []byte) (int, error) {
+	return 0, errors.New("disk failure")
+}
+
+//journal writer reading the pool, blocks when written with the pool locked
+type poolReadingWriter struct {
+	pool   *TXNPool
+	counts []int
+}
+
+func (w *poolReadingWriter) Write(p []byte) (int, error) {
+	w.counts = append(w.counts, w.pool.GetTransactionCount())
+	return len(p), nil
+}
+
+func TestJournalWrittenUnlocked(t *testing.T) {
+	writer := new(poolReadingWriter)
+	pool, store := newTestPool(WithJournal(writer))
+	writer.pool = pool
+	funding := newTestFunding(store, 1000)
+	txn := newTestTxn([]*transaction.UTXOTxInput{spend(funding, 0)}, newTestOutput(990))
+	if errCode := pool.AppendTxnPool(txn, true); errCode != ErrNoError {
+		t.Fatalf("expected the transaction admitted, got %v", errCode)
+	}
+	pool.RemoveTransactions([]common.Uint256{txn.Hash()})
+	if len(writer.counts) == 0 {
+		t.Fatal("expected the records written")
+	}
+}
+
+func TestGetTransactionsByOutputAddress(t *testing.T) {
+	pool, store := newTestPool()
+	alice, bob, carol := common.Uint160{1}, common.Uint160{2}, common.Uint160{3}
+	pay := func(programHash common.Uint160, value common.Fixed64) *transaction.TxOutput {
+		output := newTestOutput(value)
+		output.ProgramHash = programHash
+		return output
+	}
+	funding := newTestFunding(store, 1000, 1000, 1000)
+	toAlice := newTestTxn([]*transaction.UTXOTxInput{spend(funding, 0)}, pay(alice, 990))
+	toBoth := newTestTxn([]*transaction.UTXOTxInput{spend(funding, 1)}, pay(alice, 490), pay(bob, 490))
+	toBob := newTestTxn([]*transaction.UTXOTxInput{spend(funding, 2)}, pay(bob, 500), pay(bob, 480))
+	for _, txn := range []*transaction.Transaction{toAlice, toBoth, toBob} {
+		appendTestTxn(t, pool, txn)
+	}
+
+	if txns := pool.GetTransactionsByOutputAddress(alice); len(txns) != 2 || txns[0] != toAlice || txns[1] != toBoth {
+		t.Fatal("expected the transactions paying alice in admission order")
+	}
+	if txns := pool.GetTransactionsByOutputAddress(bob); len(txns) != 2 || txns[0] != toBoth || txns[1] != toBob {
+		t.Fatal("expected each transaction paying bob listed once")
+	}
+	if txns := pool.GetTransactionsByOutputAddress(carol); len(txns) != 0 {
+		t.Fatal("expected nothing paying carol")
+	}
+
+	pool.removeTransaction(toBoth)
+	if txns := pool.GetTransactionsByOutputAddress(alice); len(txns) != 1 || txns[0] != toAlice {
+		t.Fatal("expected the removed transaction unindexed")
+	}
+	pool.removeTransaction(toAlice)
+	if _, ok := pool.addressIndex[alice]; ok {
+		t.Fatal("expected the index of an address without pooled payments dropped")
+	}
+}
+
+func TestPackageFeeRate(t *testing.T) {
+	pool, store := newTestPool()
+	funding := newTestFunding(store, 1000)
+	grandparent := newTestTxn([]*transaction.UTXOTxInput{spend(funding, 0)}, newTestOutput(990))
+	parent := newTestTxn([]*transaction.UTXOTxInput{spend(grandparent, 0)}, newTestOutput(900))
+	child := newTestTxn([]*transaction.UTXOTxInput{spend(parent, 0)}, newTestOutput(850))
+	weight := Weight(grandparent)
+	for _, txn := range []*transaction.Transaction{grandparent, parent, child} {
+		if Weight(txn) != weight {
+			t.Fatal("expected the transactions of the chain to weigh the same")
+		}
+		appendTestTxn(t, pool, txn)
+	}
+	expect := func(txn *transaction.Transaction, fee common.Fixed64, members int) {
+		if rate := pool.PackageFeeRate(txn.Hash()); rate != getFeeRate(fee, members*weight) {
+			t.Fatalf("expected the package fee rate of %v fee over %d transactions, got %v", fee, members, rate)
+		}
+	}
+	expect(grandparent, 10, 1)
+	expect(parent, 100, 2)
+	expect(child, 150, 3)
+
+	pool.PrioritiseTransaction(grandparent.Hash(), 30)
+	expect(grandparent, 40, 1)
+	expect(child, 180, 3)
+
+	//the confirmed grandparent is no longer part of the packages
+	pool.removeTransaction(grandparent)
+	expect(parent, 90, 1)
+	expect(child, 140, 2)
+	pool.removeTransaction(parent)
+	expect(child, 50, 1)
+	if rate := pool.PackageFeeRate(parent.Hash()); rate != 0 {
+		t.Fatalf("expected no package fee rate of a transaction not pooled, got %v", rate)
+	}
+}
+
+func TestSelectionOrderCycle(t *testing.T) {
+	pool, _ := newTestPool()
+	//transactions spending from each other in a cycle, pooled without the checks
+	cycleHash := common.Uint256{0xcc}
+	pooled := newTestTxn([]*transaction.UTXOTxInput{{ReferTxID: cycleHash}}, newTestOutput(990))
+	child := newTestTxn([]*transaction.UTXOTxInput{spend(pooled, 0)}, newTestOutput(980))
+	closing := newTestTxn([]*transaction.UTXOTxInput{spend(child, 0)}, newTestOutput(970))
+	closing.SetHash(cycleHash)
+	for _, txn := range []*transaction.Transaction{pooled, child, closing} {
+		pool.addtxnList(txn, TxnExpiry{})
+		pool.addInputUTXOList(txn, txn.UTXOInputs[0])
+	}
+
+	//the selection still ends and keeps every transaction
+	pool.RLock()
+	order := pool.selectionOrder()
+	pool.RUnlock()
+	if len(order) != 3 {
+		t.Fatalf("expected the 3 transactions of the cycle in the selection order, got %d", len(order))
+	}
+}
+
+func TestWouldAccept(t *testing.T) {
+	pool, store := newTestPool()
+	defer setPoolLimits(2, 0, 0.0000001)()
+	defer setRBF(true, false)()
+	funding := newTestFunding(store, 1000, 1000, 1000)
+	original := newTestTxn([]*transaction.UTXOTxInput{spend(funding, 0)}, newTestOutput(980))
+	if accepted, reason := pool.WouldAccept(original); !accepted || reason != ErrNoError {
+		t.Fatalf("expected the transaction accepted, got %v", reason)
+	}
+	if pool.GetTransactionCount() != 0 || pool.getInputUTXOList(spend(funding, 0)) != nil {
+		t.Fatal("expected the pool unchanged by the query")
+	}
+	if errCode := pool.AppendTxnPool(original, true); errCode != ErrNoError {
+		t.Fatalf("expected the accepted transaction admitted, got %v", errCode)
+	}
+
+	rejected := []struct {
+		txn    *transaction.Transaction
+		reason ErrCode
+	}{
+		{original, ErrDuplicatedTx},
+		{newTestTxn([]*transaction.UTXOTxInput{spend(funding, 1)}, newTestOutput(1000)), ErrInsufficientFee},
+		{newTestTxn([]*transaction.UTXOTxInput{spend(funding, 0)}, newTestOutput(985)), ErrInsufficientReplacementFee},
+		{newTestTxn([]*transaction.UTXOTxInput{{ReferTxID: common.Uint256{0xee}}}, newTestOutput(10)), ErrOrphanTransaction},
+	}
+	for _, r := range rejected {
+		if accepted, reason := pool.WouldAccept(r.txn); accepted || reason != r.reason {
+			t.Fatalf("expected the transaction rejected with %v, got %v", r.reason, reason)
+		}
+	}
+	bump := newTestTxn([]*transaction.UTXOTxInput{spend(funding, 0)}, newTestOutput(900))
+	if accepted, _ := pool.WouldAccept(bump); !accepted {
+		t.Fatal("expected the fee bump accepted")
+	}
+
+	//the pool is full and the transaction would be the lowest fee rate
+	if errCode := pool.AppendTxnPool(newTestTxn([]*transaction.UTXOTxInput{spend(funding, 1)}, newTestOutput(970)), true); errCode != ErrNoError {
+		t.Fatalf("expected the transaction admitted, got %v", errCode)
+	}
+	low := newTestTxn([]*transaction.UTXOTxInput{spend(funding, 2)}, newTestOutput(990))
+	if accepted, reason := pool.WouldAccept(low); accepted || reason != ErrPoolFull {
+		t.Fatalf("expected ErrPoolFull, got %v", reason)
+	}
+	if errCode := pool.AppendTxnPool(low, true); errCode != ErrPoolFull {
+		t.Fatalf("expected the admission to agree with the query, got %v", errCode)
+	}
+
+	defer setConflictPolicy(CONFLICTFIRSTSEEN)()
+	if accepted, reason := pool.WouldAccept(bump); accepted || reason != ErrDoubleSpend {
+		t.Fatalf("expected ErrDoubleSpend under the first seen policy, got %v", reason)
+	}
+}
+
+func TestEvictionQueue(t *testing.T) {
+	pool, store := newTestPool()
+	funding := newTestFunding(store, 1000, 1000, 1000, 1000)
+	parent := newTestTxn([]*transaction.UTXOTxInput{spend(funding, 0)}, newTestOutput(500), newTestOutput(499))
+	txns := []*transaction.Transaction{
+		parent,
+		newTestTxn([]*transaction.UTXOTxInput{spend(parent, 0)}, newTestOutput(300)),
+		newTestTxn([]*transaction.UTXOTxInput{spend(parent, 1)}, newTestOutput(498)),
+		newTestTxn([]*transaction.UTXOTxInput{spend(funding, 1)}, newTestOutput(990)),
+		newTestTxn([]*transaction.UTXOTxInput{spend(funding, 2)}, newTestOutput(950)),
+		newTestTxn([]*transaction.UTXOTxInput{spend(funding, 3)}, newTestOutput(995)),
+	}
+	for _, txn := range txns {
+		appendTestTxn(t, pool, txn)
+	}
+	//the queue built once agrees with ranking the remaining pool again
+	pool.RLock()
+	queue := pool.newEvictionQueue(nil)
+	pool.RUnlock()
+	for pool.GetTransactionCount() > 0 {
+		pool.RLock()
+		expected, expectedRate := pool.newEvictionQueue(nil).peek()
+		victim, rate := queue.peek()
+		pool.RUnlock()
+		if victim != expected || rate != expectedRate {
+			t.Fatalf("expected the victim %x at %v, got %x at %v", expected.Hash(), expectedRate, victim.Hash(), rate)
+		}
+		pool.RLock()
+		pool.dropEvicted(queue, append(pool.descendants(victim.Hash()), victim))
+		pool.RUnlock()
+		pool.removeWithDescendants(victim)
+	}
+	if victim, _ := queue.peek(); victim != nil {
+		t.Fatal("expected the queue emptied with the pool")
+	}
+}
+
+func TestWouldAcceptIssue(t *testing.T) {
+	pool, store := newTestPool()
+	assetID := common.Uint256{9}
+	newTestAsset(store, assetID, 1000)
+	if errCode := pool.AppendTxnPool(newTestIssue(assetID, 600), true); errCode != ErrNoError {
+		t.Fatalf("expected the issue admitted, got %v", errCode)
+	}
+	over := newTestIssue(assetID, 500)
+	if accepted, reason := pool.WouldAccept(over); accepted || reason != ErrSummaryAsset {
+		t.Fatalf("expected the issue over the registered amount rejected, got %v", reason)
+	}
+	if amount := pool.getAssetIssueAmount(assetID); amount != 600 {
+		t.Fatalf("expected the pending issue amount unchanged by the query, got %v", amount)
+	}
+	if errCode := pool.AppendTxnPool(over, true); errCode != ErrSummaryAsset {
+		t.Fatalf("expected the admission to agree with the query, got %v", errCode)
+	}
+}
+
+func TestDumpForExplorer(t *testing.T) {
+	clock := newTestClock()
+	pool, store := newTestPool(withClock(clock))
+	owner := common.Uint160{7}
+	funding, _ := newTestOwnedFunding(t, store, owner)
+	output := newTestOutput(900)
+	output.ProgramHash = owner
+	parent := newTestTxn([]*transaction.UTXOTxInput{spend(funding, 0)}, output)
+	appendTestTxn(t, pool, parent)
+	clock.Advance(time.Minute)
+	child := newTestTxn([]*transaction.UTXOTxInput{spend(parent, 0)}, newTestOutput(850))
+	appendTestTxn(t, pool, child)
+	pool.PrioritiseTransaction(parent.Hash(), 50)
+	pool.SelectAndReserve(1, 0)
+
+	dump := pool.DumpForExplorer()
+	if len(dump) != 2 || dump[0].Hash != parent.Hash() || dump[1].Hash != child.Hash() {
+		t.Fatal("expected the pooled transactions in admission order")
+	}
+	exported := dump[0]
+	address, _ := owner.ToAddress()
+	weight := Weight(parent)
+	switch {
+	case exported.Size != len(parent.ToArray()):
+		t.Fatalf("unexpected size %d", exported.Size)
+	case exported.Fee != 100 || exported.FeeRate != getFeeRate(100, weight) || exported.EffectiveFeeRate != getFeeRate(150, weight):
+		t.Fatalf("unexpected fee %v, fee rate %v and effective fee rate %v", exported.Fee, exported.FeeRate, exported.EffectiveFeeRate)
+	case len(exported.Inputs) != 1 || exported.Inputs[0] != (ExplorerInput{ReferTxID: funding.Hash(), ReferTxOutputIndex: 0}):
+		t.Fatalf("unexpected inputs %+v", exported.Inputs)
+	case len(exported.Outputs) != 1 || exported.Outputs[0] != (ExplorerOutput{Address: address, AssetID: testAssetID, Value: 900}):
+		t.Fatalf("unexpected outputs %+v", exported.Outputs)
+	case exported.Age != time.Minute:
+		t.Fatalf("unexpected age %v", exported.Age)
+	case exported.Ancestors != 0 || exported.Descendants != 1:
+		t.Fatalf("unexpected %d ancestors and %d descendants", exported.Ancestors, exported.Descendants)
+	case exported.Status != EXPLORERRESERVED:
+		t.Fatalf("expected the selected transaction reserved, got %s", exported.Status)
+	}
+	if dump[1].Ancestors != 1 || dump[1].Descendants != 0 || dump[1].Status != EXPLORERPENDING {
+		t.Fatalf("unexpected child %+v", dump[1])
+	}
+}
+
+func TestDiffSnapshots(t *testing.T) {
+	pool, store := newTestPool()
+	funding := newTestFunding(store, 1000, 1000, 1000)
+	removed := newTestTxn([]*transaction.UTXOTxInput{spend(funding, 0)}, newTestOutput(990))
+	kept := newTestTxn([]*transaction.UTXOTxInput{spend(funding, 1)}, newTestOutput(990))
+	appendTestTxn(t, pool, removed)
+	appendTestTxn(t, pool, kept)
+	before := pool.Snapshot()
+	if len(before.Hashes) != 2 || before.Hashes[0].CompareTo(before.Hashes[1]) >= 0 {
+		t.Fatal("expected the pooled hashes in ascending order")
+	}
+
+	added := newTestTxn([]*transaction.UTXOTxInput{spend(funding, 2)}, newTestOutput(990))
+	appendTestTxn(t, pool, added)
+	pool.removeTransaction(removed)
+	after := pool.Snapshot()
+
+	addedHashes, removedHashes := DiffSnapshots(before, after)
+	if len(addedHashes) != 1 || addedHashes[0] != added.Hash() {
+		t.Fatal("expected only the transaction admitted between the snapshots added")
+	}
+	if len(removedHashes) != 1 || removedHashes[0] != removed.Hash() {
+		t.Fatal("expected only the transaction removed between the snapshots removed")
+	}
+	if addedHashes, removedHashes := DiffSnapshots(after, after); len(addedHashes) != 0 || len(removedHashes) != 0 {
+		t.Fatal("expected no churn between identical snapshots")
+	}
+}
+
+func TestSnapshotGenerations(t *testing.T) {
+	pool, store := newTestPool()
+	funding := newTestFunding(store, 1000, 1000)
+	first := newTestTxn([]*transaction.UTXOTxInput{spend(funding, 0)}, newTestOutput(990))
+	appendTestTxn(t, pool, first)
+	before := pool.Snapshot()
+	again := pool.Snapshot()
+	if again.view != before.view {
+		t.Fatal("expected the view reused while the pool is unchanged")
+	}
+	again.Hashes[0] = common.Uint256{}
+	if pool.Snapshot().Hashes[0] != first.Hash() || before.Hashes[0] != first.Hash() {
+		t.Fatal("expected the hashes of a snapshot not shared with the others")
+	}
+
+	second := newTestTxn([]*transaction.UTXOTxInput{spend(funding, 1)}, newTestOutput(990))
+	appendTestTxn(t, pool, second)
+	after := pool.Snapshot()
+	if after.view == before.view || len(after.Hashes) != 2 {
+		t.Fatal("expected a new view after an admission")
+	}
+	if txns := before.Transactions(); len(txns) != 1 || txns[0] != first {
+		t.Fatal("expected the earlier snapshot unchanged by the admission")
+	}
+	for i, txn := range after.Transactions() {
+		if txn.Hash() != after.Hashes[i] {
+			t.Fatal("expected the transactions in the order of the hashes")
+		}
+	}
+
+	pool.removeTransaction(first)
+	if removed := pool.Snapshot(); len(removed.Hashes) != 1 || removed.Hashes[0] != second.Hash() {
+		t.Fatal("expected a new view after a removal")
+	}
+}
+
+//the pool lock held for the whole snapshot, sorting and materializing included
+func naiveSnapshot(pool *TXNPool) ([]common.Uint256, []*transaction.Transaction) {
+	pool.RLock()
+	defer pool.RUnlock()
+	hashes := make([]common.Uint256, 0, len(pool.txnList))
+	for hash := range pool.txnList {
+		hashes = append(hashes, hash)
+	}
+	sort.Slice(hashes, func(i, j int) bool { return hashes[i].CompareTo(hashes[j]) < 0 })
+	txns := make([]*transaction.Transaction, len(hashes))
+	for i, hash := range hashes {
+		txns[i] = pool.txnList[hash].txn
+	}
+	return hashes, txns
+}
+
+func newBenchmarkPool(b *testing.B, count int) *TXNPool {
+	pool, store := newTestPool()
+	values := make([]common.Fixed64, count)
+	for i := range values {
+		values[i] = 1000
+	}
+	funding := newTestFunding(store, values...)
+	for i := 0; i < count; i++ {
+		txn := newTestTxn([]*transaction.UTXOTxInput{spend(funding, uint16(i))}, newTestOutput(common.Fixed64(900+i%90)))
+		if errCode := pool.verifyTransactionWithTxnPool(txn); errCode != ErrNoError {
+			b.Fatalf("verify transaction with pool failed: %v", errCode)
+		}
+		pool.addtxnList(txn, TxnExpiry{})
+	}
+	return pool
+}
+
+//the longest wait of a writer taking the pool lock, as an admission does,
+//while the snapshots are taken
+func benchmarkSnapshotLockHold(b *testing.B, snapshot func(*TXNPool)) {
+	pool := newBenchmarkPool(b, 5000)
+	stop := make(chan struct{})
+	waited := make(chan time.Duration)
+	go func() {
+		var longest time.Duration
+		for {
+			select {
+			case <-stop:
+				waited <- longest
+				return
+			default:
+			}
+			start := time.Now()
+			pool.Lock()
+			//a change between the snapshots, as when monitoring a busy pool
+			pool.generation++
+			pool.Unlock()
+			if wait := time.Since(start); wait > longest {
+				longest = wait
+			}
+			runtime.Gosched()
+		}
+	}()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		snapshot(pool)
+	}
+	b.StopTimer()
+	close(stop)
+	b.ReportMetric(float64((<-waited).Nanoseconds()), "max-blocked-ns")
+}
+
+func BenchmarkSnapshot(b *testing.B) {
+	benchmarkSnapshotLockHold(b, func(pool *TXNPool) { pool.Snapshot() })
+}
+
+func BenchmarkNaiveSnapshot(b *testing.B) {
+	benchmarkSnapshotLockHold(b, func(pool *TXNPool) { naiveSnapshot(pool) })
+}
+
+//the inputs of a transaction indexed, checked as by the admission and the
+//graph queries, then removed
+func benchmarkInputUTXOCycle(b *testing.B, add, check, del func(*transaction.UTXOTxInput)) {
+	inputs := make([]*transaction.UTXOTxInput, 4)
+	for i := range inputs {
+		inputs[i] = &transaction.UTXOTxInput{ReferTxID: common.Uint256{0xee}, ReferTxOutputIndex: uint16(i)}
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, input := range inputs {
+			add(input)
+		}
+		for j := 0; j < 3; j++ {
+			for _, input := range inputs {
+				check(input)
+			}
+		}
+		for _, input := range inputs {
+			del(input)
+		}
+	}
+}
+
+func BenchmarkInputUTXOList(b *testing.B) {
+	pool := newBenchmarkPool(b, 1000)
+	txn := newTestTxn(nil, newTestOutput(1))
+	benchmarkInputUTXOCycle(b,
+		func(input *transaction.UTXOTxInput) { pool.addInputUTXOList(txn, input) },
+		func(input *transaction.UTXOTxInput) { pool.getInputUTXOList(input) },
+		func(input *transaction.UTXOTxInput) { pool.delInputUTXOList(input) })
+}
+
+//the key formatted on every access, as before the inputs were the keys
+func BenchmarkNaiveInputUTXOList(b *testing.B) {
+	pool := newBenchmarkPool(b, 1000)
+	txn := newTestTxn(nil, newTestOutput(1))
+	list := make(map[string]*transaction.Transaction)
+	benchmarkInputUTXOCycle(b,
+		func(input *transaction.UTXOTxInput) {
+			pool.Lock()
+			list[input.ToString()] = txn
+			pool.Unlock()
+		},
+		func(input *transaction.UTXOTxInput) {
+			pool.RLock()
+			_ = list[input.ToString()]
+			pool.RUnlock()
+		},
+		func(input *transaction.UTXOTxInput) {
+			pool.Lock()
+			delete(list, input.ToString())
+			pool.Unlock()
+		})
+}
+
+func TestTransactionsSince(t *testing.T) {
+	pool, store := newTestPool()
+	funding := newTestFunding(store, 1000, 1000, 1000)
+	first := newTestTxn([]*transaction.UTXOTxInput{spend(funding, 0)}, newTestOutput(990))
+	second := newTestTxn([]*transaction.UTXOTxInput{spend(funding, 1)}, newTestOutput(990))
+	appendTestTxn(t, pool, first)
+	appendTestTxn(t, pool, second)
+
+	txns, seq := pool.TransactionsSince(0)
+	if len(txns) != 2 || txns[0] != first || txns[1] != second || seq != 2 {
+		t.Fatal("expected all the transactions in the order of admission")
+	}
+	if txns, next := pool.TransactionsSince(seq); len(txns) != 0 || next != seq {
+		t.Fatal("expected no delta without admissions")
+	}
+
+	third := newTestTxn([]*transaction.UTXOTxInput{spend(funding, 2)}, newTestOutput(990))
+	appendTestTxn(t, pool, third)
+	pool.removeTransaction(first)
+	txns, next := pool.TransactionsSince(seq)
+	if len(txns) != 1 || txns[0] != third || next != seq+1 {
+		t.Fatal("expected only the transaction admitted after the sequence number")
+	}
+	if txns, _ := pool.TransactionsSince(0); len(txns) != 2 {
+		t.Fatal("expected the removed transaction left out")
+	}
+}
+
+func TestPendingValueByAsset(t *testing.T) {
+	pool, store := newTestPool()
+	otherAssetID := common.Uint256{3}
+	funding := newTestFunding(store, 1000, 1000)
+	appendTestTxn(t, pool, newTestTxn([]*transaction.UTXOTxInput{spend(funding, 0)},
+		newTestOutput(600), newTestOutput(390)))
+	appendTestTxn(t, pool, newTestTxn([]*transaction.UTXOTxInput{spend(funding, 1)},
+		newTestOutput(500), &transaction.TxOutput{AssetID: otherAssetID, Value: 70}))
+
+	pending := pool.PendingValueByAsset()
+	if len(pending) != 2 {
+		t.Fatalf("expected 2 assets pending, got %d", len(pending))
+	}
+	if pending[testAssetID] != 1490 {
+		t.Fatalf("expected pending value 1490, got %v", pending[testAssetID])
+	}
+	if pending[otherAssetID] != 70 {
+		t.Fatalf("expected pending value 70, got %v", pending[otherAssetID])
+	}
+}
+
+func TestPendingFeesByAsset(t *testing.T) {
+	pool, store := newTestPool()
+	otherAssetID := common.Uint256{3}
+	funding := newTestFunding(store, 1000)
+	otherFunding := newTestTxn(nil, &transaction.TxOutput{AssetID: otherAssetID, Value: 500})
+	store.addTxn(otherFunding)
+	appendTestTxn(t, pool, newTestTxn([]*transaction.UTXOTxInput{spend(funding, 0)}, newTestOutput(990)))
+	appendTestTxn(t, pool, newTestTxn([]*transaction.UTXOTxInput{spend(otherFunding, 0)},
+		&transaction.TxOutput{AssetID: otherAssetID, Value: 480}))
+
+	fees := pool.PendingFeesByAsset()
+	if len(fees) != 2 || fees[testAssetID] != 10 || fees[otherAssetID] != 20 {
+		t.Fatalf("expected fees 10 and 20 by asset, got %v", fees)
+	}
+
+	pool.feeCalculator = fixedFee(5)
+	fees = pool.PendingFeesByAsset()
+	if len(fees) != 1 || fees[common.Uint256{}] != 10 {
+		t.Fatalf("expected the fees of a calculator without assets under the zero asset ID, got %v", fees)
+	}
+}
+
+func newTestLock(assetID common.Uint256) *transaction.Transaction {
+	return &transaction.Transaction{
+		TxType:  transaction.LockAsset,
+		Payload: &payload.LockAsset{AssetID: assetID, Amount: 100, UnlockHeight: 10},
+	}
+}
+
+func TestLockUnknownAsset(t *testing.T) {
+	pool, store := newTestPool()
+	if errCode := pool.verifyTransactionWithTxnPool(newTestLock(common.Uint256{4})); errCode != ErrUnknownAsset {
+		t.Fatalf("expected locking an unregistered asset to be rejected, got %v", errCode)
+	}
+	funding := newTestFunding(store, 1000)
+	if errCode := pool.verifyTransactionWithTxnPool(newTestLock(funding.Hash())); errCode != ErrUnknownAsset {
+		t.Fatalf("expected locking a non registration to be rejected, got %v", errCode)
+	}
+
+	newTestAsset(store, common.Uint256{4}, 1000)
+	if errCode := pool.verifyTransactionWithTxnPool(newTestLock(common.Uint256{4})); errCode != ErrNoError {
+		t.Fatalf("expected locking a registered asset to pass, got %v", errCode)
+	}
+}
+
+func TestLockWinsOverSpend(t *testing.T) {
+	pool, store := newTestPool()
+	newTestAsset(store, testAssetID, 1000)
+	funding := newTestFunding(store, 1000, 1000)
+
+	appendTestTxn(t, pool, newTestLock(testAssetID))
+	if errCode := pool.verifyTransactionWithTxnPool(newTestTxn([]*transaction.UTXOTxInput{spend(funding, 0)}, newTestOutput(900))); errCode != ErrAssetLocked {
+		t.Fatalf("expected spending a locked asset to be rejected, got %v", errCode)
+	}
+	if spender := pool.getInputUTXOList(spend(funding, 0)); spender != nil {
+		t.Fatalf("expected the rejected spend to leave its input unclaimed")
+	}
+
+	//a lock admitted after the spend evicts it
+	pool, store = newTestPool()
+	newTestAsset(store, testAssetID, 1000)
+	store.addTxn(funding)
+	spender := newTestTxn([]*transaction.UTXOTxInput{spend(funding, 1)}, newTestOutput(900))
+	other := newTestTxn(nil, &transaction.TxOutput{AssetID: testAssetID, Value: 1000, ProgramHash: common.Uint160{9}})
+	store.addTxn(other)
+	otherSpender := newTestTxn([]*transaction.UTXOTxInput{spend(other, 0)}, newTestOutput(900))
+	appendTestTxn(t, pool, spender)
+	appendTestTxn(t, pool, otherSpender)
+	appendTestTxn(t, pool, newTestLock(testAssetID))
+	if pool.GetTransaction(spender.Hash()) != nil {
+		t.Fatalf("expected the spend to be evicted by the lock")
+	}
+	if pool.GetTransaction(otherSpender.Hash()) == nil {
+		t.Fatal("expected the spend of another program hash kept")
+	}
+	pool.removeTransaction(otherSpender)
+	if len(pool.assetSpends) != 0 || len(pool.spendKeys) != 0 {
+		t.Fatal("expected the removed spends unindexed")
+	}
+}
+
+func TestLockWinsOverIssue(t *testing.T) {
+	pool, store := newTestPool()
+	assetID := common.Uint256{5}
+	newTestAsset(store, assetID, 1000)
+	appendTestTxn(t, pool, newTestLock(assetID))
+	if errCode := pool.verifyTransactionWithTxnPool(newTestIssue(assetID, 100)); errCode != ErrAssetLocked {
+		t.Fatalf("expected issuing a locked asset to be rejected, got %v", errCode)
+	}
+	if summary := pool.issueSummary[assetID]; summary != 0 {
+		t.Fatalf("expected the rejected issuance not summed, got %v", summary)
+	}
+
+	//a lock admitted after the issuance evicts it
+	pool, store = newTestPool()
+	newTestAsset(store, assetID, 1000)
+	issue := newTestIssue(assetID, 100)
+	appendTestTxn(t, pool, issue)
+	lock := newTestLock(assetID)
+	appendTestTxn(t, pool, lock)
+	if pool.GetTransaction(issue.Hash()) != nil || pool.issueSummary[assetID] != 0 {
+		t.Fatal("expected the issuance evicted by the lock")
+	}
+
+	pool.removeTransaction(lock)
+	if errCode := pool.verifyTransactionWithTxnPool(newTestIssue(assetID, 200)); errCode != ErrNoError {
+		t.Fatalf("expected the issuance admitted once the lock left the pool, got %v", errCode)
+	}
+}
+
+func TestCancelByResubmission(t *testing.T) {
+	pool, store := newTestPool()
+	defer setRBF(true, false)()
+	funding := newTestFunding(store, 1000, 1000)
+	original := newTestTxn([]*transaction.UTXOTxInput{spend(funding, 0), spend(funding, 1)}, newTestOutput(1990))
+	child := newTestTxn([]*transaction.UTXOTxInput{spend(original, 0)}, newTestOutput(1980))
+	appendTestTxn(t, pool, original)
+	appendTestTxn(t, pool, child)
+
+	//pays more than the original but not more than the original and its child
+	underpaid := newTestTxn([]*transaction.UTXOTxInput{spend(funding, 0)}, newTestOutput(985))
+	if errCode := pool.verifyTransactionWithTxnPool(underpaid); errCode != ErrInsufficientReplacementFee {
+		t.Fatalf("expected the cancel paying less than the replaced fee rejected, got %v", errCode)
+	}
+
+	cancel := newTestTxn([]*transaction.UTXOTxInput{spend(funding, 0)}, newTestOutput(950))
+	appendTestTxn(t, pool, cancel)
+	if pool.GetTransaction(original.Hash()) != nil || pool.GetTransaction(child.Hash()) != nil {
+		t.Fatal("expected the original and its child removed")
+	}
+	if pool.GetTransactionCount() != 1 {
+		t.Fatalf("expected only the cancel pooled, got %d", pool.GetTransactionCount())
+	}
+	if pool.getInputUTXOList(spend(funding, 1)) != nil || pool.getInputUTXOList(spend(original, 0)) != nil {
+		t.Fatal("expected the inputs of the replaced transactions released")
+	}
+	if pool.getInputUTXOList(spend(funding, 0)) != cancel {
+		t.Fatal("expected the input spent by the cancel")
+	}
+}
+
+//clock advanced by the test, the tickers fire when the time passes their deadline
+type testClock struct {
+	sync.Mutex
+	now     time.Time
+	elapsed time.Duration
+	tickers []*testTicker
+}
+
+type testTicker struct {
+	interval time.Duration
+	next     time.Duration
+	c        chan time.Time
+}
+
+func (t *testTicker) C() <-chan time.Time { return t.c }
+func (t *testTicker) Stop()               {}
+
+func newTestClock() *testClock {
+	return &testClock{now: time.Unix(1500000000, 0)}
+}
+
+func (c *testClock) Now() time.Time {
+	c.Lock()
+	defer c.Unlock()
+	return c.now
+}
+
+func (c *testClock) Elapsed() time.Duration {
+	c.Lock()
+	defer c.Unlock()
+	return c.elapsed
+}
+
+func (c *testClock) NewTicker(d time.Duration) poolTicker {
+	c.Lock()
+	defer c.Unlock()
+	ticker := &testTicker{interval: d, next: c.elapsed + d, c: make(chan time.Time, 1)}
+	c.tickers = append(c.tickers, ticker)
+	return ticker
+}
+
+func (c *testClock) Advance(d time.Duration) {
+	c.Lock()
+	defer c.Unlock()
+	c.now = c.now.Add(d)
+	c.elapsed += d
+	for _, ticker := range c.tickers {
+		for ticker.next <= c.elapsed {
+			select {
+			case ticker.c <- c.now:
+			default:
+			}
+			ticker.next += ticker.interval
+		}
+	}
+}
+
+//change the wall clock only, like the system time set by an operator or NTP
+func (c *testClock) JumpWall(d time.Duration) {
+	c.Lock()
+	defer c.Unlock()
+	c.now = c.now.Add(d)
+}
+
+func (c *testClock) tickerCount() int {
+	c.Lock()
+	defer c.Unlock()
+	return len(c.tickers)
+}
+
+//wait for the background goroutines to catch up with the test
+func waitFor(t *testing.T, cond func() bool) {
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("timeout waiting for the pool")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+//subscribe with a buffer of 2 and admit 3 transactions without reading
+func subscribeSlowly(policy BackpressurePolicy) (*TXNPool, <-chan *transaction.Transaction, func(), []*transaction.Transaction) {
+	pool, store := newTestPool()
+	txns := newTestEqualFeeTxns(newTestFunding(store, 1000, 1000, 1000))
+	received, unsubscribe := pool.Subscribe(2, policy)
+	return pool, received, unsubscribe, txns
+}
+
+func receivedHashes(received <-chan *transaction.Transaction, count int) []common.Uint256 {
+	hashes := []common.Uint256{}
+	for i := 0; i < count; i++ {
+		hashes = append(hashes, (<-received).Hash())
+	}
+	return hashes
+}
+
+func TestAppendPooledTransaction(t *testing.T) {
+	pool, store := newTestPool()
+	received, unsubscribe := pool.Subscribe(2, DROPNEWEST)
+	defer unsubscribe()
+	txn := newTestTxn([]*transaction.UTXOTxInput{spend(newTestFunding(store, 1000), 0)}, newTestOutput(990))
+	if errCode := pool.AppendTxnPool(txn, true); errCode != ErrNoError {
+		t.Fatalf("expected the transaction admitted, got %v", errCode)
+	}
+	//the block transaction relayed before passes the block verification
+	if errCode := pool.AppendTxnPool(txn, false); errCode != ErrNoError {
+		t.Fatalf("expected the pooled block transaction verified, got %v", errCode)
+	}
+	if errCode := pool.AppendTxnPool(txn, true); errCode != ErrDuplicatedTx {
+		t.Fatalf("expected the pooled transaction rejected, got %v", errCode)
+	}
+	if count := pool.AdmissionCounts().Submitted; count != 1 {
+		t.Fatalf("expected one admission counted, got %d", count)
+	}
+	if stats := pool.WindowStats(time.Minute); stats.Admissions != 1 {
+		t.Fatalf("expected one admission event, got %d", stats.Admissions)
+	}
+	<-received
+	select {
+	case <-received:
+		t.Fatal("expected the transaction published once")
+	default:
+	}
+}
+
+func TestSubscribeDropNewest(t *testing.T) {
+	pool, received, unsubscribe, txns := subscribeSlowly(DROPNEWEST)
+	for _, txn := range txns {
+		if errCode := pool.AppendTxnPool(txn, true); errCode != ErrNoError {
+			t.Fatalf("expected the transaction admitted, got %v", errCode)
+		}
+	}
+	if hashes := receivedHashes(received, 2); !equalOrder(hashes, []common.Uint256{txns[0].Hash(), txns[1].Hash()}) {
+		t.Fatal("expected the newest transaction dropped")
+	}
+	unsubscribe()
+	if _, ok := <-received; ok {
+		t.Fatal("expected the channel closed by unsubscribing")
+	}
+}
+
+func TestSubscribeDropOldest(t *testing.T) {
+	pool, received, unsubscribe, txns := subscribeSlowly(DROPOLDEST)
+	defer unsubscribe()
+	for _, txn := range txns {
+		if errCode := pool.AppendTxnPool(txn, true); errCode != ErrNoError {
+			t.Fatalf("expected the transaction admitted, got %v", errCode)
+		}
+	}
+	if hashes := receivedHashes(received, 2); !equalOrder(hashes, []common.Uint256{txns[1].Hash(), txns[2].Hash()}) {
+		t.Fatal("expected the oldest transaction dropped")
+	}
+}
+
+func TestSubscribeBlockWhenFull(t *testing.T) {
+	pool, received, unsubscribe, txns := subscribeSlowly(BLOCKWHENFULL)
+	defer unsubscribe()
+	admitted := make(chan ErrCode, len(txns))
+	go func() {
+		for _, txn := range txns {
+			admitted <- pool.AppendTxnPool(txn, true)
+		}
+	}()
+	waitFor(t, func() bool { return len(admitted) == 2 })
+	time.Sleep(10 * time.Millisecond)
+	if len(admitted) != 2 {
+		t.Fatal("expected the admission to wait for the subscriber")
+	}
+
+	if hashes := receivedHashes(received, 3); !equalOrder(hashes, []common.Uint256{txns[0].Hash(), txns[1].Hash(), txns[2].Hash()}) {
+		t.Fatal("expected every transaction received in admission order")
+	}
+	for range txns {
+		if errCode := <-admitted; errCode != ErrNoError {
+			t.Fatalf("expected the transaction admitted, got %v", errCode)
+		}
+	}
+
+	//unsubscribing releases a blocked admission
+	more := newTestTxn([]*transaction.UTXOTxInput{spend(txns[0], 0)}, newTestOutput(980))
+	extra := newTestTxn([]*transaction.UTXOTxInput{spend(txns[1], 0)}, newTestOutput(980))
+	third := newTestTxn([]*transaction.UTXOTxInput{spend(txns[2], 0)}, newTestOutput(980))
+	go func() {
+		for _, txn := range []*transaction.Transaction{more, extra, third} {
+			admitted <- pool.AppendTxnPool(txn, true)
+		}
+	}()
+	waitFor(t, func() bool { return len(admitted) == 2 })
+	unsubscribe()
+	waitFor(t, func() bool { return len(admitted) == 3 })
+	if pool.GetTransaction(third.Hash()) == nil {
+		t.Fatal("expected the blocked admission released by unsubscribing")
+	}
+}
+
+func TestDepthHistory(t *testing.T) {
+	old := config.Parameters.PoolDepthSampleInterval
+	config.Parameters.PoolDepthSampleInterval = 10
+	defer func() { config.Parameters.PoolDepthSampleInterval = old }()
+	clock := newTestClock()
+	start := clock.Now()
+	pool, store := newTestPool(withClock(clock))
+	txns := newTestEqualFeeTxns(newTestFunding(store, 1000, 1000))
+	appendTestTxn(t, pool, txns[0])
+	pool.Start()
+	defer pool.Shutdown()
+	waitFor(t, func() bool { return clock.tickerCount() == 1 })
+
+	clock.Advance(5 * time.Second)
+	clock.Advance(5 * time.Second)
+	waitFor(t, func() bool { return len(pool.DepthHistory()) == 1 })
+	appendTestTxn(t, pool, txns[1])
+	clock.Advance(10 * time.Second)
+	waitFor(t, func() bool { return len(pool.DepthHistory()) == 2 })
+
+	samples := pool.DepthHistory()
+	size := len(txns[0].ToArray())
+	if !samples[0].Time.Equal(start.Add(10*time.Second)) || samples[0].Count != 1 || samples[0].Bytes != size {
+		t.Fatalf("unexpected first sample %+v", samples[0])
+	}
+	if !samples[1].Time.Equal(start.Add(20*time.Second)) || samples[1].Count != 2 || samples[1].Bytes != 2*size {
+		t.Fatalf("unexpected second sample %+v", samples[1])
+	}
+
+	var history depthHistory
+	history.init(3)
+	for i := 1; i <= 5; i++ {
+		history.record(DepthSample{Count: i})
+	}
+	samples = history.snapshot()
+	if len(samples) != 3 || samples[0].Count != 3 || samples[2].Count != 5 {
+		t.Fatalf("expected the 3 newest samples kept in order, got %+v", samples)
+	}
+}
+
+//ledger answering the block height of the stored transactions
+type testLedger struct {
+	ledger.ILedgerStore
+	heights map[common.Uint256]uint32
+	lookups int
+	lookup  func() // called on each height lookup
+}
+
+func (l *testLedger) GetTransactionHeight(hash common.Uint256) (uint32, error) {
+	l.lookups++
+	if l.lookup != nil {
+		l.lookup()
+	}
+	height, ok := l.heights[hash]
+	if !ok {
+		return 0, errors.New("transaction not found")
+	}
+	return height, nil
+}
+
+//the assets of the tests take any amount
+func (l *testLedger) GetAsset(hash common.Uint256) (*asset.Asset, error) {
+	return &asset.Asset{Precision: asset.MaxPrecision}, nil
+}
+
+func setTestLedger(height uint32, store *testLedger) func() {
+	old := ledger.DefaultLedger
+	ledger.DefaultLedger = &ledger.Ledger{Blockchain: &ledger.Blockchain{BlockHeight: height}, Store: store}
+	return func() { ledger.DefaultLedger = old }
+}
+
+func TestInvalidReference(t *testing.T) {
+	pool, store := newTestPool()
+	funding := newTestFunding(store, 1000)
+	txn := newTestTxn([]*transaction.UTXOTxInput{spend(funding, 0), spend(funding, 1)}, newTestOutput(990))
+	if errCode := pool.AppendTxnPool(txn, true); errCode != ErrInvalidReference {
+		t.Fatalf("expected ErrInvalidReference, got %v", errCode)
+	}
+	if _, err := pool.getReference(txn); err == nil {
+		t.Fatal("expected the reference of an out of range input to fail")
+	}
+
+	parent := newTestTxn([]*transaction.UTXOTxInput{spend(funding, 0)}, newTestOutput(990))
+	appendTestTxn(t, pool, parent)
+	child := newTestTxn([]*transaction.UTXOTxInput{spend(parent, 1)}, newTestOutput(980))
+	if errCode := pool.AppendTxnPool(child, true); errCode != ErrInvalidReference {
+		t.Fatalf("expected ErrInvalidReference for a pooled source, got %v", errCode)
+	}
+}
+
+func TestImmatureSpend(t *testing.T) {
+	pool, store := newTestPool()
+	old := config.Parameters.MaturityDepth
+	config.Parameters.MaturityDepth = 5
+	defer func() { config.Parameters.MaturityDepth = old }()
+	newReward := func(nonce uint64) *transaction.Transaction {
+		txn := &transaction.Transaction{
+			TxType:  transaction.BookKeeping,
+			Payload: &payload.BookKeeping{Nonce: nonce},
+			Outputs: []*transaction.TxOutput{newTestOutput(1000)},
+		}
+		store.addTxn(txn)
+		return txn
+	}
+	immature := newReward(1)
+	mature := newReward(2)
+	funding := newTestFunding(store, 1000)
+	chain := &testLedger{heights: map[common.Uint256]uint32{immature.Hash(): 98, mature.Hash(): 96, funding.Hash(): 100}}
+	defer setTestLedger(100, chain)()
+
+	spendImmature := newTestTxn([]*transaction.UTXOTxInput{spend(immature, 0)}, newTestOutput(990))
+	if errCode := pool.AppendTxnPool(spendImmature, true); errCode != ErrImmatureSpend {
+		t.Fatalf("expected ErrImmatureSpend, got %v", errCode)
+	}
+	//the maturity of a block being verified is left to the consensus
+	if errCode := pool.AppendTxnPool(spendImmature, false); errCode != ErrNoError {
+		t.Fatalf("expected the block verification to skip the maturity, got %v", errCode)
+	}
+	pool.removeTransaction(spendImmature)
+	for _, txn := range []*transaction.Transaction{
+		newTestTxn([]*transaction.UTXOTxInput{spend(mature, 0)}, newTestOutput(990)),
+		newTestTxn([]*transaction.UTXOTxInput{spend(funding, 0)}, newTestOutput(990)),
+	} {
+		if errCode := pool.AppendTxnPool(txn, true); errCode != ErrNoError {
+			t.Fatalf("expected the transaction admitted, got %v", errCode)
+		}
+	}
+}
+
+func TestMinInputConfirmations(t *testing.T) {
+	clock := newTestClock()
+	pool, store := newTestPool(withClock(clock))
+	deep := newTestFunding(store, 1000)
+	shallow := newTestFunding(store, 1000, 1000)
+	chain := &testLedger{heights: map[common.Uint256]uint32{deep.Hash(): 10, shallow.Hash(): 98}}
+	defer setTestLedger(100, chain)()
+
+	spendDeep := newTestTxn([]*transaction.UTXOTxInput{spend(deep, 0)}, newTestOutput(990))
+	spendShallow := newTestTxn([]*transaction.UTXOTxInput{spend(shallow, 0)}, newTestOutput(990))
+	spendBoth := newTestTxn([]*transaction.UTXOTxInput{spend(shallow, 1)}, newTestOutput(990))
+	spendBoth.UTXOInputs = append(spendBoth.UTXOInputs, spend(deep, 0))
+	spendPooled := newTestTxn([]*transaction.UTXOTxInput{spend(spendShallow, 0)}, newTestOutput(980))
+	for _, txn := range []*transaction.Transaction{spendDeep, spendShallow, spendPooled} {
+		appendTestTxn(t, pool, txn)
+	}
+	pool.addtxnList(spendBoth, TxnExpiry{})
+
+	selected := pool.GetTxnPoolWithMinInputConfirmations(false, 3)
+	if _, ok := selected[spendPooled.Hash()]; ok || len(selected) != 3 {
+		t.Fatal("expected the transactions spending confirmed outputs selected")
+	}
+	selected = pool.GetTxnPoolWithMinInputConfirmations(false, 4)
+	if _, ok := selected[spendDeep.Hash()]; !ok || len(selected) != 1 {
+		t.Fatal("expected only the transaction with deep inputs selected")
+	}
+	if selected := pool.GetTxnPoolWithMinInputConfirmations(false, 0); len(selected) != 4 {
+		t.Fatalf("expected no filter without minimum, got %d", len(selected))
+	}
+
+	lookups := chain.lookups
+	pool.GetTxnPoolWithMinInputConfirmations(false, 4)
+	if chain.lookups != lookups {
+		t.Fatal("expected the input heights cached")
+	}
+	clock.Advance(INPUTHEIGHTCACHETIME + time.Second)
+	pool.GetTxnPoolWithMinInputConfirmations(false, 4)
+	if chain.lookups == lookups {
+		t.Fatal("expected the cached input heights to expire")
+	}
+
+	//the ledger is read without holding the pool lock
+	chain.lookup = func() {
+		if !pool.TryLock() {
+			t.Fatal("expected the input heights looked up without the pool lock")
+		}
+		pool.Unlock()
+	}
+	clock.Advance(INPUTHEIGHTCACHETIME + time.Second)
+	if selected := pool.GetTxnPoolWithMinInputConfirmations(false, 3); len(selected) != 3 {
+		t.Fatalf("expected the transactions spending confirmed outputs selected, got %d", len(selected))
+	}
+}
+
+func setMaxOrphanTransactions(count int) func() {
+	old := config.Parameters.MaxOrphanTransactions
+	config.Parameters.MaxOrphanTransactions = count
+	return func() { config.Parameters.MaxOrphanTransactions = old }
+}
+
+func TestPromoteOrphan(t *testing.T) {
+	pool, store := newTestPool()
+	defer setMaxOrphanTransactions(10)()
+	structureVerified := make(map[common.Uint256]int)
+	testVerifierOf(pool).structure = func(txn *transaction.Transaction) ErrCode {
+		structureVerified[txn.Hash()]++
+		return ErrNoError
+	}
+	ledgerVerified := make(map[common.Uint256]int)
+	testVerifierOf(pool).ledger = func(txn *transaction.Transaction) ErrCode {
+		ledgerVerified[txn.Hash()]++
+		return ErrNoError
+	}
+	funding := newTestFunding(store, 1000)
+	parent := newTestTxn([]*transaction.UTXOTxInput{spend(funding, 0)}, newTestOutput(990))
+	child := newTestTxn([]*transaction.UTXOTxInput{spend(parent, 0)}, newTestOutput(980))
+	grandchild := newTestTxn([]*transaction.UTXOTxInput{spend(child, 0)}, newTestOutput(970))
+
+	if errCode := pool.AppendTxnPool(grandchild, true); errCode != ErrOrphanTransaction {
+		t.Fatalf("expected the grandchild kept as orphan, got %v", errCode)
+	}
+	if errCode := pool.AppendTxnPool(child, true); errCode != ErrOrphanTransaction {
+		t.Fatalf("expected the child kept as orphan, got %v", errCode)
+	}
+	if pool.GetTransactionCount() != 0 || structureVerified[child.Hash()] != 1 || ledgerVerified[child.Hash()] != 0 {
+		t.Fatal("expected the orphans verified only themselves and not pooled")
+	}
+
+	verified := pool.VerifyDurations().Count
+	if errCode := pool.AppendTxnPool(parent, true); errCode != ErrNoError {
+		t.Fatalf("expected the parent admitted, got %v", errCode)
+	}
+	if pool.GetTransaction(child.Hash()) == nil || pool.GetTransaction(grandchild.Hash()) == nil {
+		t.Fatal("expected the orphans promoted after the parent arrived")
+	}
+	if len(pool.orphanList) != 0 || len(pool.orphanParents) != 0 {
+		t.Fatal("expected the orphan buffer emptied")
+	}
+	for _, txn := range []*transaction.Transaction{child, grandchild} {
+		if structureVerified[txn.Hash()] != 1 {
+			t.Fatal("expected the structural verification not repeated on promotion")
+		}
+		if ledgerVerified[txn.Hash()] != 1 {
+			t.Fatal("expected the promotion to verify the orphans with the ledger")
+		}
+	}
+	if count := pool.VerifyDurations().Count - verified; count != 3 {
+		t.Fatalf("expected the promotions timed, got %d verifications", count)
+	}
+}
+
+func TestOrphanBuffering(t *testing.T) {
+	clock := newTestClock()
+	pool, store := newTestPool(withClock(clock))
+	defer setMaxOrphanTransactions(10)()
+	funding := newTestFunding(store, 1000, 1000)
+	parent := newTestTxn([]*transaction.UTXOTxInput{spend(funding, 0)}, newTestOutput(990))
+	input := spend(parent, 0)
+	duplicate := newTestTxn([]*transaction.UTXOTxInput{input, input}, newTestOutput(980))
+	testVerifierOf(pool).structure = func(txn *transaction.Transaction) ErrCode {
+		if txn == duplicate {
+			return ErrDuplicateInput
+		}
+		return ErrNoError
+	}
+	if errCode := pool.AppendTxnPool(duplicate, true); errCode != ErrDuplicateInput {
+		t.Fatalf("expected the duplicate input rejected before buffering, got %v", errCode)
+	}
+	if len(pool.OrphanReport()) != 0 {
+		t.Fatal("expected the transaction failing its verification not buffered")
+	}
+	//the transactions of a block proposal are not buffered
+	orphan := newTestTxn([]*transaction.UTXOTxInput{spend(parent, 0)}, newTestOutput(980))
+	pool.AppendTxnPool(orphan, false)
+	if len(pool.OrphanReport()) != 0 {
+		t.Fatal("expected the block verification not to buffer orphans")
+	}
+
+	if errCode := pool.AppendTxnPool(orphan, true); errCode != ErrOrphanTransaction {
+		t.Fatalf("expected the transaction kept as orphan, got %v", errCode)
+	}
+	clock.Advance(ORPHANTTL - time.Second)
+	pool.sweepOrphans()
+	if len(pool.OrphanReport()) != 1 {
+		t.Fatal("expected the orphan kept until ORPHANTTL")
+	}
+	clock.Advance(time.Second)
+	pool.sweepOrphans()
+	if len(pool.OrphanReport()) != 0 || len(pool.orphanParents) != 0 {
+		t.Fatal("expected the orphan dropped after ORPHANTTL")
+	}
+}
+
+func TestChildOfPooledParentVerified(t *testing.T) {
+	pool, store := newTestPool()
+	//resolve the references like the balance check of the validation package
+	testVerifierOf(pool).ledger = func(txn *transaction.Transaction) ErrCode {
+		if _, err := txn.GetReference(); err != nil {
+			return ErrTransactionBalance
+		}
+		return ErrNoError
+	}
+	testVerifierOf(pool).ledgerAndStore = func(txn *transaction.Transaction, resolving transaction.ILedgerStore) ErrCode {
+		if _, err := txn.GetReferenceWithStore(resolving); err != nil {
+			return ErrTransactionBalance
+		}
+		return ErrNoError
+	}
+	funding := newTestFunding(store, 1000)
+	parent := newTestTxn([]*transaction.UTXOTxInput{spend(funding, 0)}, newTestOutput(990))
+	child := newTestTxn([]*transaction.UTXOTxInput{spend(parent, 0)}, newTestOutput(980))
+	if errCode := pool.AppendTxnPool(parent, true); errCode != ErrNoError {
+		t.Fatalf("expected the parent admitted, got %v", errCode)
+	}
+	if !pool.spendsPooled(child) || pool.spendsPooled(parent) {
+		t.Fatal("expected only the child to spend a pooled transaction")
+	}
+	//the transactions of a block are verified with the ledger alone
+	if errCode := pool.AppendTxnPool(child, false); errCode != ErrTransactionBalance {
+		t.Fatalf("expected the ledger store to miss the pooled parent, got %v", errCode)
+	}
+	if errCode := pool.AppendTxnPool(child, true); errCode != ErrNoError {
+		t.Fatalf("expected the child verified with its pooled parent, got %v", errCode)
+	}
+	if _, err := child.GetReference(); err == nil {
+		t.Fatal("expected the pooled parent kept out of TxStore")
+	}
+	old := config.Parameters.RejectConfirmedTransactions
+	defer func() { config.Parameters.RejectConfirmedTransactions = old }()
+	config.Parameters.RejectConfirmedTransactions = true
+	if pool.isConfirmed(parent.Hash()) || !pool.isConfirmed(funding.Hash()) {
+		t.Fatal("expected only the ledger transactions to be confirmed")
+	}
+}
+
+func TestBlockChildOfPooledParentRejected(t *testing.T) {
+	pool, store := newTestPool()
+	funding := newTestFunding(store, 1000)
+	parent := newTestTxn([]*transaction.UTXOTxInput{spend(funding, 0)}, newTestOutput(990))
+	child := newTestTxn([]*transaction.UTXOTxInput{spend(parent, 0)}, newTestOutput(980))
+	appendTestTxn(t, pool, parent)
+
+	//a block spending the pooled but unconfirmed parent is not valid
+	defer setTestLedger(1, &testLedger{})()
+	pool.verifier = ledgerVerifier{}
+	if errCode := pool.AppendTxnPool(child, false); errCode != ErrTransactionBalance {
+		t.Fatalf("expected the child of a block rejected by the ledger verifier, got %v", errCode)
+	}
+	if pool.GetTransaction(child.Hash()) != nil {
+		t.Fatal("expected the rejected child not pooled")
+	}
+}
+
+func TestStoreMissAndFailure(t *testing.T) {
+	pool, store := newTestPool()
+	defer setMaxOrphanTransactions(10)()
+	funding := newTestFunding(store, 1000, 1000)
+	parent := newTestTxn([]*transaction.UTXOTxInput{spend(funding, 0)}, newTestOutput(990))
+	orphan := newTestTxn([]*transaction.UTXOTxInput{spend(parent, 0)}, newTestOutput(980))
+	if errCode := pool.AppendTxnPool(orphan, true); errCode != ErrOrphanTransaction {
+		t.Fatalf("expected the store miss buffered as orphan, got %v", errCode)
+	}
+	if _, ok := pool.OrphanReport()[orphan.Hash()]; !ok {
+		t.Fatal("expected the orphan buffered")
+	}
+
+	store.failure = errors.New("leveldb: closed")
+	txn := newTestTxn([]*transaction.UTXOTxInput{spend(funding, 1)}, newTestOutput(990))
+	if errCode := pool.AppendTxnPool(txn, true); errCode != ErrPoolUnavailable {
+		t.Fatalf("expected the store failure reported as unavailable, got %v", errCode)
+	}
+	if _, ok := pool.OrphanReport()[txn.Hash()]; ok || pool.GetTransaction(txn.Hash()) != nil {
+		t.Fatal("expected the transaction neither buffered nor pooled on a store failure")
+	}
+	if errCode := pool.verifyTransactionWithTxnPool(txn); errCode != ErrPoolUnavailable {
+		t.Fatalf("expected the pool verification unavailable, got %v", errCode)
+	}
+
+	//the same transaction is admitted once the store is back
+	store.failure = nil
+	if errCode := pool.AppendTxnPool(txn, true); errCode != ErrNoError {
+		t.Fatalf("expected the transaction admitted once the store is back, got %v", errCode)
+	}
+}
+
+func TestOrphanReport(t *testing.T) {
+	pool, store := newTestPool()
+	defer setMaxOrphanTransactions(10)()
+	funding := newTestFunding(store, 1000, 1000)
+	first := newTestTxn([]*transaction.UTXOTxInput{spend(funding, 0)}, newTestOutput(490), newTestOutput(490))
+	second := newTestTxn([]*transaction.UTXOTxInput{spend(funding, 1)}, newTestOutput(990))
+	single := newTestTxn([]*transaction.UTXOTxInput{spend(first, 0)}, newTestOutput(480))
+	both := newTestTxn([]*transaction.UTXOTxInput{spend(first, 1), spend(second, 0)}, newTestOutput(1400))
+	for _, txn := range []*transaction.Transaction{single, both} {
+		if errCode := pool.AppendTxnPool(txn, true); errCode != ErrOrphanTransaction {
+			t.Fatalf("expected the transaction kept as orphan, got %v", errCode)
+		}
+	}
+
+	report := pool.OrphanReport()
+	if len(report) != 2 {
+		t.Fatalf("expected 2 orphans reported, got %d", len(report))
+	}
+	if parents := report[single.Hash()]; len(parents) != 1 || parents[0] != first.Hash() {
+		t.Fatal("expected the orphan waiting for its only parent")
+	}
+	if parents := report[both.Hash()]; len(parents) != 2 || parents[0].CompareTo(parents[1]) >= 0 {
+		t.Fatal("expected the orphan waiting for both parents in ascending order")
+	}
+
+	if errCode := pool.AppendTxnPool(first, true); errCode != ErrNoError {
+		t.Fatalf("expected the parent admitted, got %v", errCode)
+	}
+	report = pool.OrphanReport()
+	if _, ok := report[single.Hash()]; ok || len(report) != 1 {
+		t.Fatal("expected the promoted orphan no longer reported")
+	}
+	if parents := report[both.Hash()]; len(parents) != 1 || parents[0] != second.Hash() {
+		t.Fatal("expected only the parent still missing reported")
+	}
+}
+
+func TestTransactionWeight(t *testing.T) {
+	defer setMaxTxInBlock(1)()
+	old := config.Parameters.TransactionWeight
+	defer func() { config.Parameters.TransactionWeight = old }()
+	RegisterTxnWeight("flat", func(txn *transaction.Transaction) int { return 1000 })
+
+	//the transaction selected out of a small one and a large one paying more fee
+	selectOne := func(weight string) (selected, small, large *transaction.Transaction) {
+		config.Parameters.TransactionWeight = weight
+		pool, store := newTestPool()
+		funding := newTestFunding(store, 1000, 1000)
+		small = newTestTxn([]*transaction.UTXOTxInput{spend(funding, 0)}, newTestOutput(990))
+		outputs := []*transaction.TxOutput{}
+		for i := 0; i < 10; i++ {
+			outputs = append(outputs, newTestOutput(common.Fixed64(94+i)))
+		}
+		large = newTestTxn([]*transaction.UTXOTxInput{spend(funding, 1)}, outputs...)
+		appendTestTxn(t, pool, small)
+		appendTestTxn(t, pool, large)
+		for _, txn := range pool.GetTxnPool(true) {
+			selected = txn
+		}
+		return selected, small, large
+	}
+
+	if selected, small, _ := selectOne(""); selected != small {
+		t.Fatal("expected the small transaction selected by the byte fee rate")
+	}
+	if selected, _, large := selectOne("flat"); selected != large {
+		t.Fatal("expected the higher fee transaction selected by the flat weight")
+	}
+}
+
+func TestRegisterTxnWeightConcurrently(t *testing.T) {
+	txn := newTestTxn(nil, newTestOutput(1))
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(2)
+		go func(i int) {
+			defer wg.Done()
+			RegisterTxnWeight(fmt.Sprintf("concurrent%d", i), func(txn *transaction.Transaction) int { return i })
+		}(i)
+		go func() {
+			defer wg.Done()
+			Weight(txn)
+		}()
+	}
+	wg.Wait()
+}
+
+func TestCleanDuringAppend(t *testing.T) {
+	pool, store := newTestPool()
+	testVerifierOf(pool).ledger = func(txn *transaction.Transaction) ErrCode { return ErrNoError }
+	assetID := common.Uint256{5}
+	newTestAsset(store, assetID, 1000)
+	issue := newTestIssue(assetID, 100)
+
+	//commit the block with the issue while the pool is checking its registration
+	cleaned := make(chan struct{})
+	store.onGetTransaction = func(hash common.Uint256) {
+		if hash != assetID {
+			return
+		}
+		store.onGetTransaction = nil
+		go func() {
+			pool.CleanSubmittedTransactions(&ledger.Block{Transactions: []*transaction.Transaction{issue}})
+			close(cleaned)
+		}()
+		select {
+		case <-cleaned:
+		case <-time.After(50 * time.Millisecond):
+		}
+	}
+	if errCode := pool.AppendTxnPool(issue, true); errCode != ErrNoError {
+		t.Fatalf("expected the issue admitted, got %v", errCode)
+	}
+	<-cleaned
+
+	if pool.GetTransaction(issue.Hash()) != nil || pool.getAssetIssueAmount(assetID) != 0 {
+		t.Fatal("expected the committed issue cleaned from all the pool maps")
+	}
+}
+
+func TestReplacementPaysForDescendants(t *testing.T) {
+	pool, store := newTestPool()
+	defer setRBF(true, false)()
+	funding := newTestFunding(store, 1000)
+	conflict := newTestTxn([]*transaction.UTXOTxInput{spend(funding, 0)}, newTestOutput(990))
+	expensive := newTestTxn([]*transaction.UTXOTxInput{spend(conflict, 0)}, newTestOutput(890))
+	appendTestTxn(t, pool, conflict)
+	appendTestTxn(t, pool, expensive)
+
+	if required := pool.RequiredReplacementFee(newTestTxn([]*transaction.UTXOTxInput{spend(funding, 0)})); required != 111 {
+		t.Fatalf("expected the required fee to cover the conflict and its descendant, got %v", required)
+	}
+	//pays more than the conflict alone
+	replacement := newTestTxn([]*transaction.UTXOTxInput{spend(funding, 0)}, newTestOutput(900))
+	if errCode := pool.verifyTransactionWithTxnPool(replacement); errCode != ErrInsufficientReplacementFee {
+		t.Fatalf("expected ErrInsufficientReplacementFee, got %v", errCode)
+	}
+	if pool.GetTransaction(conflict.Hash()) == nil || pool.GetTransaction(expensive.Hash()) == nil {
+		t.Fatal("expected the conflict and its descendant kept")
+	}
+
+	replacement = newTestTxn([]*transaction.UTXOTxInput{spend(funding, 0)}, newTestOutput(889))
+	appendTestTxn(t, pool, replacement)
+	if pool.GetTransactionCount() != 1 {
+		t.Fatal("expected the conflict package replaced")
+	}
+}
+
+func setPoolLimits(maxPoolSize, maxPoolBytes int, minTxFee float64) func() {
+	old := *config.Parameters
+	config.Parameters.MaxPoolSize = maxPoolSize
+	config.Parameters.MaxPoolBytes = maxPoolBytes
+	config.Parameters.MinTxFee = minTxFee
+	return func() {
+		config.Parameters.MaxPoolSize = old.MaxPoolSize
+		config.Parameters.MaxPoolBytes = old.MaxPoolBytes
+		config.Parameters.MinTxFee = old.MinTxFee
+	}
+}
+
+func TestLimits(t *testing.T) {
+	pool, _ := newTestPool()
+	defer setPoolLimits(100, 1<<20, 0.001)()
+	defer setMaxTxInBlock(-1)()
+	defer setMaxOrphanTransactions(10)()
+	old := config.Parameters.VerifyTimeout
+	config.Parameters.VerifyTimeout = 200
+	defer func() { config.Parameters.VerifyTimeout = old }()
+
+	limits := pool.Limits()
+	expected := PoolLimits{
+		MaxPoolSize:           100,
+		MaxPoolBytes:          1 << 20,
+		MinTxFee:              100000,
+		MaxOrphanTransactions: 10,
+		VerifyTimeout:         200 * time.Millisecond,
+	}
+	if limits != expected {
+		t.Fatalf("expected limits %+v, got %+v", expected, limits)
+	}
+}
+
+func TestMinRelayFee(t *testing.T) {
+	pool, store := newTestPool()
+	defer setPoolLimits(0, 0, 0.0000005)()
+	old := config.Parameters.MinRelayFee
+	config.Parameters.MinRelayFee = 0.000005
+	defer func() { config.Parameters.MinRelayFee = old }()
+	funding := newTestFunding(store, 1000, 1000)
+	between := newTestTxn([]*transaction.UTXOTxInput{spend(funding, 0)}, newTestOutput(990))
+	above := newTestTxn([]*transaction.UTXOTxInput{spend(funding, 1)}, newTestOutput(900))
+	rate := pool.newTxnEntry(between).feeRate()
+	if limits := pool.Limits(); rate < limits.MinTxFee || rate >= limits.MinRelayFee {
+		t.Fatalf("expected the fee rate %v between the min pool and relay fees", rate)
+	}
+
+	if errCode := pool.AppendTxnPool(between, true); errCode != ErrNoError {
+		t.Fatalf("expected the transaction above the min fee pooled, got %v", errCode)
+	}
+	if pool.ShouldRelay(between) {
+		t.Fatal("expected the transaction below the min relay fee not relayed")
+	}
+	if !pool.ShouldRelay(above) {
+		t.Fatal("expected the transaction above the min relay fee relayed")
+	}
+	config.Parameters.MinRelayFee = 0
+	if !pool.ShouldRelay(between) {
+		t.Fatal("expected every transaction relayed without a min relay fee")
+	}
+}
+
+func TestPoolSizeLimits(t *testing.T) {
+	pool, store := newTestPool()
+	testVerifierOf(pool).ledger = func(txn *transaction.Transaction) ErrCode { return ErrNoError }
+	defer setPoolLimits(2, 0, 0.0000001)()
+	funding := newTestFunding(store, 1000, 1000, 1000, 1000)
+	noFee := newTestTxn([]*transaction.UTXOTxInput{spend(funding, 0)}, newTestOutput(1000))
+	if errCode := pool.AppendTxnPool(noFee, true); errCode != ErrInsufficientFee {
+		t.Fatalf("expected ErrInsufficientFee, got %v", errCode)
+	}
+
+	low := newTestTxn([]*transaction.UTXOTxInput{spend(funding, 1)}, newTestOutput(990))
+	high := newTestTxn([]*transaction.UTXOTxInput{spend(funding, 2)}, newTestOutput(900))
+	for _, txn := range []*transaction.Transaction{low, high} {
+		if errCode := pool.AppendTxnPool(txn, true); errCode != ErrNoError {
+			t.Fatalf("expected the transaction admitted, got %v", errCode)
+		}
+	}
+	lower := newTestTxn([]*transaction.UTXOTxInput{spend(funding, 3)}, newTestOutput(995))
+	if errCode := pool.AppendTxnPool(lower, true); errCode != ErrPoolFull {
+		t.Fatalf("expected ErrPoolFull for the lowest fee rate, got %v", errCode)
+	}
+	if pool.getInputUTXOList(spend(funding, 3)) != nil {
+		t.Fatal("expected the input of the rejected transaction released")
+	}
+
+	higher := newTestTxn([]*transaction.UTXOTxInput{spend(funding, 3)}, newTestOutput(950))
+	if errCode := pool.AppendTxnPool(higher, true); errCode != ErrNoError {
+		t.Fatalf("expected the higher fee rate admitted, got %v", errCode)
+	}
+	if pool.GetTransaction(low.Hash()) != nil || pool.GetTransactionCount() != 2 {
+		t.Fatal("expected the lowest fee rate transaction evicted")
+	}
+	if pool.txnBytes != len(high.ToArray())+len(higher.ToArray()) {
+		t.Fatalf("expected the pooled bytes tracked, got %d", pool.txnBytes)
+	}
+}
+
+func TestDynamicMinFee(t *testing.T) {
+	clock := newTestClock()
+	pool, store := newTestPool(withClock(clock))
+	defer setPoolLimits(2, 0, 0.0000001)()
+	old := config.Parameters.MinFeeHalfLife
+	config.Parameters.MinFeeHalfLife = 60
+	defer func() { config.Parameters.MinFeeHalfLife = old }()
+	funding := newTestFunding(store, 1000, 1000, 1000, 1000)
+	low := newTestTxn([]*transaction.UTXOTxInput{spend(funding, 0)}, newTestOutput(990))
+	mid := newTestTxn([]*transaction.UTXOTxInput{spend(funding, 1)}, newTestOutput(980))
+	high := newTestTxn([]*transaction.UTXOTxInput{spend(funding, 2)}, newTestOutput(970))
+	for _, txn := range []*transaction.Transaction{low, mid} {
+		if errCode := pool.AppendTxnPool(txn, true); errCode != ErrNoError {
+			t.Fatalf("expected the transaction admitted, got %v", errCode)
+		}
+	}
+	minTxFee := pool.Limits().MinTxFee
+	if rate := pool.CurrentMinFeeRate(); rate != minTxFee {
+		t.Fatalf("expected MinTxFee before any eviction, got %v", rate)
+	}
+	lowRate := pool.RawFeeRate(low.Hash())
+
+	if errCode := pool.AppendTxnPool(high, true); errCode != ErrNoError {
+		t.Fatalf("expected the transaction admitted, got %v", errCode)
+	}
+	if rate := pool.CurrentMinFeeRate(); rate != lowRate+1 {
+		t.Fatalf("expected the min fee rate raised above the evicted %v, got %v", lowRate, rate)
+	}
+	again := newTestTxn([]*transaction.UTXOTxInput{spend(funding, 3)}, newTestOutput(990))
+	if errCode := pool.AppendTxnPool(again, true); errCode != ErrInsufficientFee {
+		t.Fatalf("expected the evicted fee rate rejected while congested, got %v", errCode)
+	}
+
+	clock.Advance(60 * time.Second)
+	if rate := pool.CurrentMinFeeRate(); rate != (lowRate+1)/2 {
+		t.Fatalf("expected the min fee rate halved after the half life, got %v", rate)
+	}
+	clock.Advance(20 * 60 * time.Second)
+	if rate := pool.CurrentMinFeeRate(); rate != minTxFee {
+		t.Fatalf("expected the min fee rate back to MinTxFee, got %v", rate)
+	}
+}
+
+func TestSimulateAdmission(t *testing.T) {
+	pool, store := newTestPool(withClock(newTestClock()))
+	defer setPoolLimits(2, 0, 0.0000001)()
+	old := config.Parameters.MinFeeHalfLife
+	config.Parameters.MinFeeHalfLife = 60
+	defer func() { config.Parameters.MinFeeHalfLife = old }()
+	funding := newTestFunding(store, 1000, 1000, 1000, 1000)
+	low := newTestTxn([]*transaction.UTXOTxInput{spend(funding, 0)}, newTestOutput(990))
+	mid := newTestTxn([]*transaction.UTXOTxInput{spend(funding, 1)}, newTestOutput(980))
+	for _, txn := range []*transaction.Transaction{low, mid} {
+		appendTestTxn(t, pool, txn)
+	}
+	lowRate := pool.RawFeeRate(low.Hash())
+	minTxFee := pool.Limits().MinTxFee
+
+	high := newTestTxn([]*transaction.UTXOTxInput{spend(funding, 2)}, newTestOutput(970))
+	evict, floor := pool.SimulateAdmission(high)
+	if !equalOrder(evict, []common.Uint256{low.Hash()}) || floor != lowRate+1 {
+		t.Fatalf("expected the low fee transaction evicted and the floor %v, got %x and %v", lowRate+1, evict, floor)
+	}
+	if pool.GetTransaction(low.Hash()) == nil || pool.CurrentMinFeeRate() != minTxFee {
+		t.Fatal("expected the pool unchanged by the simulation")
+	}
+
+	//the lowest fee rate of the full pool is not admitted
+	lowest := newTestTxn([]*transaction.UTXOTxInput{spend(funding, 3)}, newTestOutput(995))
+	if evict, floor := pool.SimulateAdmission(lowest); len(evict) != 0 || floor != minTxFee {
+		t.Fatalf("expected nothing evicted for the lowest fee rate, got %x and %v", evict, floor)
+	}
+
+	if errCode := pool.AppendTxnPool(high, true); errCode != ErrNoError {
+		t.Fatalf("expected the transaction admitted, got %v", errCode)
+	}
+	if pool.GetTransaction(low.Hash()) != nil || pool.CurrentMinFeeRate() != lowRate+1 {
+		t.Fatal("expected the admission to match the simulation")
+	}
+}
+
+func TestReloadLimits(t *testing.T) {
+	pool, store := newTestPool()
+	defer setPoolLimits(3, 0, 0)()
+	funding := newTestFunding(store, 1000, 1000, 1000)
+	low := newTestTxn([]*transaction.UTXOTxInput{spend(funding, 0)}, newTestOutput(990))
+	mid := newTestTxn([]*transaction.UTXOTxInput{spend(funding, 1)}, newTestOutput(980))
+	high := newTestTxn([]*transaction.UTXOTxInput{spend(funding, 2)}, newTestOutput(970))
+	for _, txn := range []*transaction.Transaction{low, mid, high} {
+		if errCode := pool.AppendTxnPool(txn, true); errCode != ErrNoError {
+			t.Fatalf("expected the transaction admitted, got %v", errCode)
+		}
+	}
+
+	config.Parameters.MaxPoolSize = 1
+	if pool.GetTransactionCount() != 3 {
+		t.Fatal("expected the pool not pruned before the reload")
+	}
+	if limits := pool.ReloadLimits(); limits.MaxPoolSize != 1 {
+		t.Fatalf("expected the reloaded max pool size 1, got %d", limits.MaxPoolSize)
+	}
+	if pool.GetTransactionCount() != 1 || pool.GetTransaction(high.Hash()) == nil {
+		t.Fatal("expected the pool pruned to the highest fee transaction")
+	}
+}
+
+func TestMaxPoolBlocks(t *testing.T) {
+	pool, store := newTestPool()
+	funding := newTestFunding(store, 1000, 1000, 1000)
+	low := newTestTxn([]*transaction.UTXOTxInput{spend(funding, 0)}, newTestOutput(990))
+	mid := newTestTxn([]*transaction.UTXOTxInput{spend(funding, 1)}, newTestOutput(980))
+	high := newTestTxn([]*transaction.UTXOTxInput{spend(funding, 2)}, newTestOutput(970))
+	size := len(low.ToArray())
+	defer setPoolLimits(0, 10*size, 0)()
+	old := *config.Parameters
+	defer func() {
+		config.Parameters.MaxBlockBytes = old.MaxBlockBytes
+		config.Parameters.MaxPoolBlocks = old.MaxPoolBlocks
+	}()
+	config.Parameters.MaxBlockBytes = size
+	config.Parameters.MaxPoolBlocks = 2
+	if limits := pool.Limits(); limits.MaxPoolBytes != 2*size {
+		t.Fatalf("expected the byte budget of 2 blocks, got %d", limits.MaxPoolBytes)
+	}
+
+	for _, txn := range []*transaction.Transaction{low, mid, high} {
+		if errCode := pool.AppendTxnPool(txn, true); errCode != ErrNoError {
+			t.Fatalf("expected the transaction admitted, got %v", errCode)
+		}
+	}
+	if pool.GetTransactionCount() != 2 || pool.GetTransaction(low.Hash()) != nil {
+		t.Fatal("expected the lowest fee rate transaction evicted by the derived budget")
+	}
+}
+
+func TestEvictByPackageFeeRate(t *testing.T) {
+	pool, store := newTestPool()
+	defer setPoolLimits(3, 0, 0.0000001)()
+	funding := newTestFunding(store, 1000, 1000, 1000)
+	parent := newTestTxn([]*transaction.UTXOTxInput{spend(funding, 0)}, newTestOutput(995))
+	child := newTestTxn([]*transaction.UTXOTxInput{spend(parent, 0)}, newTestOutput(800))
+	lowValue := newTestTxn([]*transaction.UTXOTxInput{spend(funding, 1)}, newTestOutput(980))
+	for _, txn := range []*transaction.Transaction{parent, child, lowValue} {
+		if errCode := pool.AppendTxnPool(txn, true); errCode != ErrNoError {
+			t.Fatalf("expected the transaction admitted, got %v", errCode)
+		}
+	}
+
+	incoming := newTestTxn([]*transaction.UTXOTxInput{spend(funding, 2)}, newTestOutput(970))
+	if errCode := pool.AppendTxnPool(incoming, true); errCode != ErrNoError {
+		t.Fatalf("expected the transaction admitted, got %v", errCode)
+	}
+	if pool.GetTransaction(lowValue.Hash()) != nil {
+		t.Fatal("expected the low value transaction evicted")
+	}
+	if pool.GetTransaction(parent.Hash()) == nil || pool.GetTransaction(child.Hash()) == nil {
+		t.Fatal("expected the low fee parent of a high fee child kept")
+	}
+}
+
+func TestEnsureRoomFor(t *testing.T) {
+	pool, store := newTestPool()
+	funding := newTestFunding(store, 1000, 1000, 1000, 1000, 1000)
+	small := []*transaction.Transaction{
+		newTestTxn([]*transaction.UTXOTxInput{spend(funding, 0)}, newTestOutput(990)),
+		newTestTxn([]*transaction.UTXOTxInput{spend(funding, 1)}, newTestOutput(980)),
+		newTestTxn([]*transaction.UTXOTxInput{spend(funding, 2)}, newTestOutput(970)),
+	}
+	limit := 0
+	for _, txn := range small {
+		limit += len(txn.ToArray())
+	}
+	defer setPoolLimits(0, limit, 0)()
+	for _, txn := range small {
+		if errCode := pool.AppendTxnPool(txn, true); errCode != ErrNoError {
+			t.Fatalf("expected the transaction admitted, got %v", errCode)
+		}
+	}
+
+	outputs := []*transaction.TxOutput{}
+	for i := 0; i < 2; i++ {
+		outputs = append(outputs, newTestOutput(400))
+	}
+	priority := newTestTxn([]*transaction.UTXOTxInput{spend(funding, 3)}, outputs...)
+	if size := len(priority.ToArray()); size <= len(small[0].ToArray()) || size > limit {
+		t.Fatalf("expected the priority transaction to need more than one eviction, size %d", size)
+	}
+	if err := pool.EnsureRoomFor(priority); err != nil {
+		t.Fatalf("expected room made, got %v", err)
+	}
+	if pool.GetTransaction(small[0].Hash()) != nil || pool.GetTransaction(small[2].Hash()) == nil {
+		t.Fatal("expected the lowest fee transactions evicted first")
+	}
+	if errCode := pool.AppendTxnPool(priority, true); errCode != ErrNoError {
+		t.Fatalf("expected the priority transaction admitted, got %v", errCode)
+	}
+
+	outputs = []*transaction.TxOutput{}
+	for i := 0; i < 50; i++ {
+		outputs = append(outputs, newTestOutput(10))
+	}
+	tooLarge := newTestTxn([]*transaction.UTXOTxInput{spend(funding, 4)}, outputs...)
+	count := pool.GetTransactionCount()
+	if err := pool.EnsureRoomFor(tooLarge); err == nil {
+		t.Fatal("expected an error when the transaction doesn't fit the empty pool")
+	}
+	if pool.GetTransactionCount() != count {
+		t.Fatal("expected nothing evicted for a transaction which can't fit")
+	}
+}
+
+func TestReinsertFromOrphanedBlock(t *testing.T) {
+	pool, store := newTestPool()
+	funding := newTestFunding(store, 1000, 1000)
+	unspent := newTestTxn([]*transaction.UTXOTxInput{spend(funding, 0)}, newTestOutput(990))
+	spent := newTestTxn([]*transaction.UTXOTxInput{spend(funding, 1)}, newTestOutput(990))
+	//the input of spent is spent again by the new chain
+	testVerifierOf(pool).ledger = func(txn *transaction.Transaction) ErrCode {
+		if txn == spent {
+			return ErrDoubleSpend
+		}
+		return ErrNoError
+	}
+
+	if errCode := pool.ReinsertFromOrphanedBlock(unspent); errCode != ErrNoError {
+		t.Fatalf("expected the transaction reinserted, got %v", errCode)
+	}
+	if errCode := pool.ReinsertFromOrphanedBlock(spent); errCode != ErrDoubleSpend {
+		t.Fatalf("expected the transaction with spent input rejected, got %v", errCode)
+	}
+	if pool.GetTransaction(unspent.Hash()) == nil || pool.GetTransactionCount() != 1 {
+		t.Fatal("expected only the transaction with unspent input pooled")
+	}
+	if counts := pool.AdmissionCounts(); counts.Reinserted != 1 || counts.Submitted != 0 {
+		t.Fatalf("expected the reinsertion counted apart from submissions, got %+v", counts)
+	}
+}
+
+func TestPeriodicTasks(t *testing.T) {
+	clock := newTestClock()
+	pool, _ := newTestPool(withClock(clock))
+	var mutex sync.Mutex
+	runs := make(map[string]int)
+	count := func(name string) int {
+		mutex.Lock()
+		defer mutex.Unlock()
+		return runs[name]
+	}
+	for name, interval := range map[string]time.Duration{"fast": 2 * time.Second, "slow": 3 * time.Second} {
+		name := name
+		interval := interval
+		pool.tasks.add(name, func() time.Duration { return interval }, func() {
+			mutex.Lock()
+			defer mutex.Unlock()
+			runs[name]++
+		})
+	}
+	pool.tasks.add("disabled", func() time.Duration { return 0 }, func() {
+		t.Error("expected the disabled task not run")
+	})
+	pool.Start()
+	waitFor(t, func() bool { return clock.tickerCount() == 1 })
+
+	for i := 0; i < 6 && (count("fast") < 2 || count("slow") < 1); i++ {
+		clock.Advance(SCHEDULERTICK)
+		time.Sleep(10 * time.Millisecond)
+	}
+	waitFor(t, func() bool { return count("fast") >= 2 && count("slow") >= 1 })
+
+	pool.Shutdown()
+	fast, slow := count("fast"), count("slow")
+	clock.Advance(10 * time.Second)
+	time.Sleep(10 * time.Millisecond)
+	if count("fast") != fast || count("slow") != slow {
+		t.Fatal("expected no task run after Shutdown")
+	}
+}
+
+func TestProducerOf(t *testing.T) {
+	pool, store := newTestPool()
+	funding := newTestFunding(store, 1000)
+	txn := newTestTxn([]*transaction.UTXOTxInput{spend(funding, 0)}, newTestOutput(500), newTestOutput(490))
+	appendTestTxn(t, pool, txn)
+
+	if pool.ProducerOf(spend(txn, 1)) != txn {
+		t.Fatal("expected the pooled transaction to produce its output")
+	}
+	if pool.ProducerOf(spend(txn, 2)) != nil {
+		t.Fatal("expected no producer of an output out of range")
+	}
+	if pool.ProducerOf(spend(funding, 0)) != nil {
+		t.Fatal("expected no producer of a confirmed output")
+	}
+}
+
+func TestStalePool(t *testing.T) {
+	oldGap, oldAction := config.Parameters.MaxBlockGap, config.Parameters.StalePoolAction
+	defer func() { config.Parameters.MaxBlockGap, config.Parameters.StalePoolAction = oldGap, oldAction }()
+	config.Parameters.MaxBlockGap = 60
+
+	//run the pool without blocks for the seconds, a block is committed after blockAt seconds
+	run := func(action string, seconds int, blockAt int) (*TXNPool, []*transaction.Transaction) {
+		config.Parameters.StalePoolAction = action
+		clock := newTestClock()
+		pool, store := newTestPool(withClock(clock))
+		txns := newTestEqualFeeTxns(newTestFunding(store, 1000, 1000))
+		for _, txn := range txns {
+			appendTestTxn(t, pool, txn)
+		}
+		testVerifierOf(pool).ledger = func(txn *transaction.Transaction) ErrCode {
+			if txn == txns[0] {
+				return ErrDoubleSpend
+			}
+			return ErrNoError
+		}
+		pool.Start()
+		defer pool.Shutdown()
+		waitFor(t, func() bool { return clock.tickerCount() == 1 })
+		for elapsed := 0; elapsed < seconds; elapsed += 10 {
+			if elapsed == blockAt {
+				pool.CleanSubmittedTransactions(&ledger.Block{})
+			}
+			clock.Advance(10 * time.Second)
+			time.Sleep(5 * time.Millisecond)
+		}
+		return pool, txns
+	}
+
+	if pool, _ := run(STALECLEAR, 80, 30); pool.GetTransactionCount() != 2 {
+		t.Fatal("expected the pool kept within the gap since the last block")
+	}
+	pool, _ := run(STALECLEAR, 100, 30)
+	waitFor(t, func() bool { return pool.GetTransactionCount() == 0 })
+	if len(pool.inputUTXOList) != 0 {
+		t.Fatal("expected the cleared transactions removed from all the pool maps")
+	}
+
+	pool, txns := run(STALEREVALIDATE, 70, -1)
+	waitFor(t, func() bool { return pool.GetTransactionCount() == 1 })
+	if pool.GetTransaction(txns[1].Hash()) == nil {
+		t.Fatal("expected the valid transaction kept by the revalidation")
+	}
+}
+
+func TestRevalidateChained(t *testing.T) {
+	pool, store := newTestPool()
+	funding := newTestFunding(store, 1000)
+	parent := newTestTxn([]*transaction.UTXOTxInput{spend(funding, 0)}, newTestOutput(990))
+	child := newTestTxn([]*transaction.UTXOTxInput{spend(parent, 0)}, newTestOutput(980))
+	//the references are resolved like the ledger verification of the validation package
+	testVerifierOf(pool).ledger = func(txn *transaction.Transaction) ErrCode {
+		if _, err := txn.GetReference(); err != nil {
+			return ErrDoubleSpend
+		}
+		return ErrNoError
+	}
+	testVerifierOf(pool).ledgerAndStore = func(txn *transaction.Transaction, resolving transaction.ILedgerStore) ErrCode {
+		if _, err := txn.GetReferenceWithStore(resolving); err != nil {
+			return ErrDoubleSpend
+		}
+		return ErrNoError
+	}
+	appendTestTxn(t, pool, parent)
+	appendTestTxn(t, pool, child)
+
+	pool.commitLock.Lock()
+	pool.revalidateTransactions()
+	pool.commitLock.Unlock()
+	if pool.GetTransaction(parent.Hash()) == nil || pool.GetTransaction(child.Hash()) == nil {
+		t.Fatal("expected the child revalidated with its pooled parent")
+	}
+}
+
+func TestSelectForBlockWithReserve(t *testing.T) {
+	pool, store := newTestPool()
+	funding := newTestFunding(store, 1000, 1000, 1000, 1000, 1000)
+	for i := 0; i < 4; i++ {
+		appendTestTxn(t, pool, newTestTxn([]*transaction.UTXOTxInput{spend(funding, uint16(i))}, newTestOutput(990)))
+	}
+	high := newTestTxn([]*transaction.UTXOTxInput{spend(funding, 4)}, newTestOutput(500))
+	size := len(high.ToArray())
+	floor := pool.newTxnEntry(high).feeRate()
+
+	//no transaction pays the floor, the reserve is left empty
+	selected := pool.SelectForBlockWithReserve(4*size, 2*size, floor)
+	if len(selected) != 2 {
+		t.Fatalf("expected 2 transactions outside the reserve, got %d", len(selected))
+	}
+	if all := pool.SelectForBlockWithReserve(4*size, 0, floor); len(all) != 4 {
+		t.Fatalf("expected the block filled without a reserve, got %d", len(all))
+	}
+
+	appendTestTxn(t, pool, high)
+	selected = pool.SelectForBlockWithReserve(4*size, 2*size, floor)
+	if len(selected) != 3 || selected[0] != high {
+		t.Fatalf("expected the high fee transaction first in the reserve, got %d transactions", len(selected))
+	}
+}
+
+func TestSelectByFeeBands(t *testing.T) {
+	pool, store := newTestPool()
+	funding := newTestFunding(store, 1000, 1000, 1000, 1000, 1000, 1000, 1000, 1000, 1000)
+	fees := []common.Fixed64{10, 10, 10, 100, 100, 100, 500, 500, 500}
+	for i, fee := range fees {
+		appendTestTxn(t, pool, newTestTxn([]*transaction.UTXOTxInput{spend(funding, uint16(i))}, newTestOutput(1000-fee)))
+	}
+	rateOf := func(fee common.Fixed64) common.Fixed64 {
+		for _, entry := range pool.txnList {
+			if entry.fee == fee {
+				return entry.feeRate()
+			}
+		}
+		t.Fatalf("no transaction with fee %v", fee)
+		return 0
+	}
+	low, high := rateOf(100), rateOf(500)
+	bands := []common.Fixed64{high, 0, low}
+	selected := pool.SelectByFeeBands(bands, 2)
+	for i, band := range selected {
+		if len(band) != 2 {
+			t.Fatalf("expected 2 transactions in band %v, got %d", bands[i], len(band))
+		}
+	}
+	inBand := map[int]common.Fixed64{0: 500, 1: 10, 2: 100}
+	for i, band := range selected {
+		for _, txn := range band {
+			if fee := pool.txnList[txn.Hash()].fee; fee != inBand[i] {
+				t.Fatalf("expected fee %v in band %v, got %v", inBand[i], bands[i], fee)
+			}
+		}
+	}
+
+	if selected := pool.SelectByFeeBands([]common.Fixed64{low}, 0); len(selected[0]) != 6 {
+		t.Fatalf("expected 6 transactions above the only band, got %d", len(selected[0]))
+	}
+}
+
+func TestDuplicateOutputs(t *testing.T) {
+	old := config.Parameters.FlagDuplicateOutputs
+	defer func() { config.Parameters.FlagDuplicateOutputs = old }()
+	config.Parameters.FlagDuplicateOutputs = true
+
+	pool, store := newTestPool()
+	txns := newTestEqualFeeTxns(newTestFunding(store, 1000, 1000, 1000))
+	appendTestTxn(t, pool, txns[0])
+	if count := pool.AdmissionCounts().DuplicateOutputs; count != 0 {
+		t.Fatalf("expected no duplicate outputs, got %d", count)
+	}
+	appendTestTxn(t, pool, txns[1])
+	if count := pool.AdmissionCounts().DuplicateOutputs; count != 1 {
+		t.Fatalf("expected the same outputs from different inputs flagged, got %d", count)
+	}
+	if pool.GetTransaction(txns[1].Hash()) == nil {
+		t.Fatal("expected the flagged transaction accepted")
+	}
+
+	pool.removeTransaction(txns[0])
+	pool.removeTransaction(txns[1])
+	config.Parameters.FlagDuplicateOutputs = false
+	appendTestTxn(t, pool, txns[0])
+	appendTestTxn(t, pool, txns[2])
+	if count := pool.AdmissionCounts().DuplicateOutputs; count != 1 {
+		t.Fatalf("expected no flag when the check is disabled, got %d", count)
+	}
+}
+
+func TestBlocksWorthPending(t *testing.T) {
+	old := config.Parameters.MaxBlockBytes
+	defer func() { config.Parameters.MaxBlockBytes = old }()
+	config.Parameters.MaxBlockBytes = 0
+	defer setMaxTxInBlock(0)()
+
+	pool, store := newTestPool()
+	if blocks := pool.BlocksWorthPending(); blocks != 0 {
+		t.Fatalf("expected an empty pool worth no block, got %v", blocks)
+	}
+	for _, txn := range newTestEqualFeeTxns(newTestFunding(store, 1000, 1000, 1000, 1000, 1000)) {
+		appendTestTxn(t, pool, txn)
+	}
+	if blocks := pool.BlocksWorthPending(); blocks != 1 {
+		t.Fatalf("expected the pool to fit one block without budget, got %v", blocks)
+	}
+	config.Parameters.MaxTxInBlock = 2
+	if blocks := pool.BlocksWorthPending(); blocks != 2.5 {
+		t.Fatalf("expected 2.5 blocks by count, got %v", blocks)
+	}
+	config.Parameters.MaxBlockBytes = pool.txnBytes / 4
+	if blocks := pool.BlocksWorthPending(); blocks != 4 {
+		t.Fatalf("expected 4 blocks by bytes, got %v", blocks)
+	}
+}
+
+func TestOutputPolicy(t *testing.T) {
+	changeAddress := common.Uint160{1}
+	requireChange := func(txn *transaction.Transaction) error {
+		for _, output := range txn.Outputs {
+			if output.ProgramHash == changeAddress {
+				return nil
+			}
+		}
+		return errors.New("no change output")
+	}
+	pool, store := newTestPool(WithOutputPolicy(requireChange))
+	testVerifierOf(pool).ledger = func(txn *transaction.Transaction) ErrCode { return ErrNoError }
+	funding := newTestFunding(store, 1000, 1000, 1000)
+
+	noChange := newTestTxn([]*transaction.UTXOTxInput{spend(funding, 0)}, newTestOutput(990))
+	if errCode := pool.AppendTxnPool(noChange, true); errCode != ErrNonStandard {
+		t.Fatalf("expected ErrNonStandard without change output, got %v", errCode)
+	}
+	change := newTestOutput(490)
+	change.ProgramHash = changeAddress
+	withChange := newTestTxn([]*transaction.UTXOTxInput{spend(funding, 1)}, newTestOutput(500), change)
+	if errCode := pool.AppendTxnPool(withChange, true); errCode != ErrNoError {
+		t.Fatalf("expected the transaction with change output accepted, got %v", errCode)
+	}
+	fromBlock := newTestTxn([]*transaction.UTXOTxInput{spend(funding, 2)}, newTestOutput(990))
+	if errCode := pool.AppendTxnPool(fromBlock, false); errCode != ErrNoError {
+		t.Fatalf("expected the policy skipped for the transactions of blocks, got %v", errCode)
+	}
+
+	pool, store = newTestPool()
+	testVerifierOf(pool).ledger = func(txn *transaction.Transaction) ErrCode { return ErrNoError }
+	store.addTxn(funding)
+	if errCode := pool.AppendTxnPool(noChange, true); errCode != ErrNoError {
+		t.Fatalf("expected all the outputs accepted by default, got %v", errCode)
+	}
+}
+
+func TestConcurrentConflict(t *testing.T) {
+	defer setRBF(true, true)()
+	old := config.Parameters.RBFConcurrentWindow
+	defer func() { config.Parameters.RBFConcurrentWindow = old }()
+	config.Parameters.RBFConcurrentWindow = 1000
+
+	//the higher fee transaction has the lower fee rate, so RBFRequireHigherFeeRate
+	//alone would keep whichever is admitted first
+	admit := func(clock *testClock, gap time.Duration, concurrent bool, first int) (*TXNPool, []*transaction.Transaction) {
+		pool, store := newTestPool(withClock(clock))
+		testVerifierOf(pool).ledger = func(txn *transaction.Transaction) ErrCode { return ErrNoError }
+		low, high := newTestLowRateReplacement(newTestFunding(store, 1000))
+		txns := []*transaction.Transaction{low, high}
+		if concurrent {
+			var wg sync.WaitGroup
+			for _, txn := range []*transaction.Transaction{txns[first], txns[1-first]} {
+				wg.Add(1)
+				go func(txn *transaction.Transaction) {
+					defer wg.Done()
+					pool.AppendTxnPool(txn, true)
+				}(txn)
+			}
+			wg.Wait()
+			return pool, txns
+		}
+		pool.AppendTxnPool(txns[first], true)
+		clock.Advance(gap)
+		pool.AppendTxnPool(txns[1-first], true)
+		return pool, txns
+	}
+
+	for i := 0; i < 20; i++ {
+		pool, txns := admit(newTestClock(), 0, true, i%2)
+		if pool.GetTransactionCount() != 1 || pool.GetTransaction(txns[1].Hash()) == nil {
+			t.Fatalf("expected the higher fee transaction to win the concurrent admission, round %d", i)
+		}
+	}
+	for first := 0; first < 2; first++ {
+		pool, txns := admit(newTestClock(), 500*time.Millisecond, false, first)
+		if pool.GetTransaction(txns[1].Hash()) == nil {
+			t.Fatalf("expected the higher fee transaction to win within the window, admitted %d first", first)
+		}
+	}
+	pool, txns := admit(newTestClock(), 2*time.Second, false, 0)
+	if pool.GetTransaction(txns[0].Hash()) == nil {
+		t.Fatal("expected the fee rate rule applied outside the window")
+	}
+}
+
+func TestPoolCommitment(t *testing.T) {
+	pool, store := newTestPool()
+	if root := pool.PoolCommitment(); root != (common.Uint256{}) {
+		t.Fatalf("expected the zero commitment for the empty pool, got %x", root)
+	}
+	txns := newTestEqualFeeTxns(newTestFunding(store, 1000, 1000, 1000, 1000, 1000))
+	for _, txn := range txns {
+		appendTestTxn(t, pool, txn)
+	}
+	root := pool.PoolCommitment()
+	for _, txn := range txns {
+		path, proofRoot, err := pool.MembershipProof(txn.Hash())
+		if err != nil {
+			t.Fatalf("membership proof failed: %v", err)
+		}
+		if proofRoot != root || !crypto.VerifyMerkleProof(txn.Hash(), path, root) {
+			t.Fatalf("expected a valid proof for transaction %x", txn.Hash())
+		}
+	}
+
+	path, _, _ := pool.MembershipProof(txns[0].Hash())
+	path[0].Hash[0] ^= 1
+	if crypto.VerifyMerkleProof(txns[0].Hash(), path, root) {
+		t.Fatal("expected the tampered proof rejected")
+	}
+	path, _, _ = pool.MembershipProof(txns[0].Hash())
+	if crypto.VerifyMerkleProof(txns[1].Hash(), path, root) {
+		t.Fatal("expected the proof rejected for another transaction")
+	}
+	if _, _, err := pool.MembershipProof(txns[0].Outputs[0].AssetID); err == nil {
+		t.Fatal("expected no proof for a transaction not in the pool")
+	}
+
+	pool.removeTransaction(txns[4])
+	if pool.PoolCommitment() == root {
+		t.Fatal("expected the commitment to change with the pool")
+	}
+}
+
+func TestSelectAndReserve(t *testing.T) {
+	pool, store := newTestPool()
+	txns := newTestEqualFeeTxns(newTestFunding(store, 1000, 1000, 1000, 1000, 1000, 1000))
+	for _, txn := range txns {
+		appendTestTxn(t, pool, txn)
+	}
+	child := newTestTxn([]*transaction.UTXOTxInput{spend(txns[0], 0)}, newTestOutput(900))
+	appendTestTxn(t, pool, child)
+
+	//two producers selecting at the same time
+	selections := make([][]*transaction.Transaction, 2)
+	tokens := make([]ReservationToken, 2)
+	var wg sync.WaitGroup
+	for i := range selections {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			selections[i], tokens[i] = pool.SelectAndReserve(4, 0)
+		}(i)
+	}
+	wg.Wait()
+	if tokens[0] == tokens[1] {
+		t.Fatal("expected distinct reservation tokens")
+	}
+	seen := make(map[common.Uint256]int)
+	for i, selection := range selections {
+		for _, txn := range selection {
+			if owner, ok := seen[txn.Hash()]; ok {
+				t.Fatalf("transaction %x reserved by producers %d and %d", txn.Hash(), owner, i)
+			}
+			seen[txn.Hash()] = i
+		}
+	}
+	//the child is only selected by the producer reserving its parent
+	if owner, ok := seen[child.Hash()]; ok && seen[txns[0].Hash()] != owner {
+		t.Fatal("expected the child selected only with its parent")
+	}
+	for _, txn := range txns {
+		if _, ok := seen[txn.Hash()]; !ok {
+			t.Fatalf("expected transaction %x reserved by a producer", txn.Hash())
+		}
+	}
+
+	pool.Release(tokens[0])
+	for _, txn := range selections[0] {
+		if pool.IsReserved(txn.Hash()) {
+			t.Fatalf("expected transaction %x released", txn.Hash())
+		}
+	}
+	again, _ := pool.SelectAndReserve(0, 0)
+	if len(again) != len(selections[0]) {
+		t.Fatalf("expected the %d released transactions selected again, got %d", len(selections[0]), len(again))
+	}
+}
+
+func TestReservationTTL(t *testing.T) {
+	old := config.Parameters.ReservationTTL
+	config.Parameters.ReservationTTL = 60
+	defer func() { config.Parameters.ReservationTTL = old }()
+	clock := newTestClock()
+	pool, store := newTestPool(withClock(clock))
+	for _, txn := range newTestEqualFeeTxns(newTestFunding(store, 1000, 1000)) {
+		appendTestTxn(t, pool, txn)
+	}
+	pool.Start()
+	defer pool.Shutdown()
+	waitFor(t, func() bool { return clock.tickerCount() == 1 })
+
+	reserved, _ := pool.SelectAndReserve(0, 0)
+	if len(reserved) != 2 {
+		t.Fatalf("expected 2 transactions reserved, got %d", len(reserved))
+	}
+	for elapsed := 0; elapsed < 50; elapsed += 10 {
+		clock.Advance(10 * time.Second)
+		time.Sleep(5 * time.Millisecond)
+	}
+	if !pool.IsReserved(reserved[0].Hash()) {
+		t.Fatal("expected the reservation kept within the TTL")
+	}
+	clock.Advance(20 * time.Second)
+	waitFor(t, func() bool { return !pool.IsReserved(reserved[0].Hash()) && !pool.IsReserved(reserved[1].Hash()) })
+	if again, _ := pool.SelectAndReserve(0, 0); len(again) != 2 {
+		t.Fatalf("expected the expired reservations selected again, got %d", len(again))
+	}
+}
+
+func TestSubmitterExpiry(t *testing.T) {
+	old := config.Parameters.TxnPoolTTL
+	config.Parameters.TxnPoolTTL = 600
+	defer func() { config.Parameters.TxnPoolTTL = old }()
+	clock := newTestClock()
+	pool, store := newTestPool(withClock(clock))
+	funding := newTestFunding(store, 1000, 1000, 1000)
+	hinted := newTestTxn([]*transaction.UTXOTxInput{spend(funding, 0)}, newTestOutput(990))
+	child := newTestTxn([]*transaction.UTXOTxInput{spend(hinted, 0)}, newTestOutput(980))
+	plain := newTestTxn([]*transaction.UTXOTxInput{spend(funding, 1)}, newTestOutput(990))
+	if errCode := pool.AppendTxnPoolWithExpiry(hinted, true, TxnExpiry{Time: clock.Now().Add(time.Minute)}); errCode != ErrNoError {
+		t.Fatalf("expected the transaction admitted, got %v", errCode)
+	}
+	appendTestTxn(t, pool, child)
+	appendTestTxn(t, pool, plain)
+
+	clock.Advance(30 * time.Second)
+	pool.sweepExpired()
+	if pool.GetTransaction(hinted.Hash()) == nil {
+		t.Fatal("expected the transaction kept before its expiry")
+	}
+	clock.Advance(30 * time.Second)
+	pool.sweepExpired()
+	if pool.GetTransaction(hinted.Hash()) != nil || pool.GetTransaction(child.Hash()) != nil {
+		t.Fatal("expected the transaction and its child removed at the submitter expiry")
+	}
+	if pool.GetTransaction(plain.Hash()) == nil {
+		t.Fatal("expected the transaction without a hint kept until TxnPoolTTL")
+	}
+	clock.Advance(10 * time.Minute)
+	pool.sweepExpired()
+	if pool.GetTransaction(plain.Hash()) != nil {
+		t.Fatal("expected the transaction removed after TxnPoolTTL")
+	}
+
+	byHeight := newTestTxn([]*transaction.UTXOTxInput{spend(funding, 2)}, newTestOutput(990))
+	if errCode := pool.AppendTxnPoolWithExpiry(byHeight, true, TxnExpiry{Height: 10}); errCode != ErrNoError {
+		t.Fatalf("expected the transaction admitted, got %v", errCode)
+	}
+	pool.CleanSubmittedTransactions(&ledger.Block{Blockdata: &ledger.Blockdata{Height: 9}})
+	if pool.GetTransaction(byHeight.Hash()) == nil {
+		t.Fatal("expected the transaction kept before its expiry height")
+	}
+	pool.CleanSubmittedTransactions(&ledger.Block{Blockdata: &ledger.Blockdata{Height: 10}})
+	if pool.GetTransaction(byHeight.Hash()) != nil {
+		t.Fatal("expected the transaction removed once its expiry height is committed")
+	}
+}
+
+func TestPassedExpiryRejected(t *testing.T) {
+	clock := newTestClock()
+	pool, store := newTestPool(withClock(clock))
+	defer setTestLedger(10, &testLedger{})()
+	funding := newTestFunding(store, 1000)
+	txn := newTestTxn([]*transaction.UTXOTxInput{spend(funding, 0)}, newTestOutput(990))
+	if errCode := pool.AppendTxnPoolWithExpiry(txn, true, TxnExpiry{Time: clock.Now()}); errCode != ErrExpired {
+		t.Fatalf("expected the passed expiry time rejected, got %v", errCode)
+	}
+	if errCode := pool.AppendTxnPoolWithExpiry(txn, true, TxnExpiry{Height: 10}); errCode != ErrExpired {
+		t.Fatalf("expected the committed expiry height rejected, got %v", errCode)
+	}
+	if pool.GetTransactionCount() != 0 {
+		t.Fatal("expected nothing pooled")
+	}
+}
+
+func TestJournaledExpiry(t *testing.T) {
+	clock := newTestClock()
+	journal := new(bytes.Buffer)
+	pool, store := newTestPool(withClock(clock), WithJournal(journal))
+	funding := newTestFunding(store, 1000, 1000)
+	expiry := TxnExpiry{Time: clock.Now().Add(time.Minute), Height: 10}
+	hinted := newTestTxn([]*transaction.UTXOTxInput{spend(funding, 0)}, newTestOutput(990))
+	plain := newTestTxn([]*transaction.UTXOTxInput{spend(funding, 1)}, newTestOutput(990))
+	if errCode := pool.AppendTxnPoolWithExpiry(hinted, true, expiry); errCode != ErrNoError {
+		t.Fatalf("expected the transaction admitted, got %v", errCode)
+	}
+	appendTestTxn(t, pool, plain)
+
+	replayed, _ := newTestPool(withClock(clock))
+	transaction.TxStore = store
+	if err := replayed.ReplayJournal(bytes.NewReader(journal.Bytes())); err != nil {
+		t.Fatalf("replay failed: %v", err)
+	}
+	if got := replayed.txnList[hinted.Hash()].expiry; !got.Time.Equal(expiry.Time) || got.Height != expiry.Height {
+		t.Fatalf("expected the expiry replayed, got %v", got)
+	}
+	if got := replayed.txnList[plain.Hash()].expiry; got != (TxnExpiry{}) {
+		t.Fatalf("expected no expiry replayed, got %v", got)
+	}
+}
+
+func TestWindowStats(t *testing.T) {
+	clock := newTestClock()
+	pool, store := newTestPool(withClock(clock))
+	defer setPoolLimits(2, 0, 0)()
+	funding := newTestFunding(store, 1000, 1000, 1000, 1000)
+	high := newTestTxn([]*transaction.UTXOTxInput{spend(funding, 0)}, newTestOutput(900))
+	low := newTestTxn([]*transaction.UTXOTxInput{spend(funding, 1)}, newTestOutput(990))
+	for _, txn := range []*transaction.Transaction{high, low} {
+		if errCode := pool.AppendTxnPool(txn, true); errCode != ErrNoError {
+			t.Fatalf("expected the transaction admitted, got %v", errCode)
+		}
+	}
+	pool.Freeze()
+	mid := newTestTxn([]*transaction.UTXOTxInput{spend(funding, 2)}, newTestOutput(950))
+	if errCode := pool.AppendTxnPool(mid, true); errCode != ErrPoolFrozen {
+		t.Fatalf("expected ErrPoolFrozen, got %v", errCode)
+	}
+	pool.Unfreeze()
+
+	clock.Advance(40 * time.Second)
+	if errCode := pool.AppendTxnPool(mid, true); errCode != ErrNoError {
+		t.Fatalf("expected the transaction admitted evicting the lowest fee rate, got %v", errCode)
+	}
+	pool.CleanSubmittedTransactions(&ledger.Block{Transactions: []*transaction.Transaction{high}})
+
+	clock.Advance(30 * time.Second)
+	recent := pool.WindowStats(time.Minute)
+	if recent.Admissions != 1 || recent.Evictions != 1 || recent.Confirmations != 1 || len(recent.Rejections) != 0 {
+		t.Fatalf("expected only the events of the last minute, got %+v", recent)
+	}
+	all := pool.WindowStats(2 * time.Minute)
+	if all.Admissions != 3 || all.Evictions != 1 || all.Confirmations != 1 || all.Rejections[ErrPoolFrozen] != 1 {
+		t.Fatalf("expected all the events within two minutes, got %+v", all)
+	}
+
+	clock.Advance(2 * time.Hour)
+	if later := pool.WindowStats(24 * time.Hour); later.Window != STATSRETENTION || later.Admissions != 0 {
+		t.Fatalf("expected the window capped and no events in it, got %+v", later)
+	}
+}
+
+func TestWallClockJump(t *testing.T) {
+	old := config.Parameters.ReservationTTL
+	config.Parameters.ReservationTTL = 60
+	defer func() { config.Parameters.ReservationTTL = old }()
+	clock := newTestClock()
+	pool, store := newTestPool(withClock(clock))
+	funding := newTestFunding(store, 1000, 1000)
+	stuck := newTestTxn([]*transaction.UTXOTxInput{spend(funding, 0)}, newTestOutput(990))
+	appendTestTxn(t, pool, stuck)
+	reserved, _ := pool.SelectAndReserve(0, 0)
+	clock.Advance(10 * time.Minute)
+	clock.JumpWall(-2 * time.Hour)
+
+	recent := newTestTxn([]*transaction.UTXOTxInput{spend(funding, 1)}, newTestOutput(990))
+	appendTestTxn(t, pool, recent)
+	if txns := pool.StuckTransactions(5 * time.Minute); len(txns) != 1 || txns[0] != stuck {
+		t.Fatal("expected the ages kept across the backward jump of the wall clock")
+	}
+	if txns := pool.StuckTransactions(0); len(txns) != 1 {
+		t.Fatal("expected the transaction added after the jump not aged")
+	}
+	pool.sweepReservations()
+	if pool.IsReserved(reserved[0].Hash()) {
+		t.Fatal("expected the reservation expired after its TTL despite the jump")
+	}
+}
+
+//verifier recording the verifications in order
+type recordingVerifier struct {
+	calls []string
+}
+
+func (v *recordingVerifier) VerifyTransaction(txn *transaction.Transaction) ErrCode {
+	v.calls = append(v.calls, "VerifyTransaction")
+	return ErrNoError
+}
+
+func (v *recordingVerifier) VerifyTransactionWithLedger(txn *transaction.Transaction) ErrCode {
+	v.calls = append(v.calls, "VerifyTransactionWithLedger")
+	if len(txn.Outputs) > 1 {
+		return ErrUnknownAsset
+	}
+	return ErrNoError
+}
+
+func (v *recordingVerifier) VerifyTransactionWithLedgerAndStore(txn *transaction.Transaction, store transaction.ILedgerStore) ErrCode {
+	v.calls = append(v.calls, "VerifyTransactionWithLedgerAndStore")
+	return ErrNoError
+}
+
+func TestVerifier(t *testing.T) {
+	defaultPool := new(TXNPool)
+	defaultPool.init()
+	if _, ok := defaultPool.verifier.(ledgerVerifier); !ok {
+		t.Fatal("expected the ledger verifier by default")
+	}
+	verifier := &recordingVerifier{}
+	pool, store := newTestPool(WithVerifier(verifier))
+	funding := newTestFunding(store, 1000, 1000)
+	txn := newTestTxn([]*transaction.UTXOTxInput{spend(funding, 0)}, newTestOutput(990))
+	if errCode := pool.AppendTxnPool(txn, true); errCode != ErrNoError {
+		t.Fatalf("append transaction failed: %v", errCode)
+	}
+	if len(verifier.calls) != 2 || verifier.calls[0] != "VerifyTransaction" || verifier.calls[1] != "VerifyTransactionWithLedger" {
+		t.Fatalf("expected the transaction verified itself then with the ledger, got %v", verifier.calls)
+	}
+	rejected := newTestTxn([]*transaction.UTXOTxInput{spend(funding, 1)}, newTestOutput(500), newTestOutput(490))
+	if errCode := pool.AppendTxnPool(rejected, true); errCode != ErrUnknownAsset {
+		t.Fatalf("expected the error of the verifier returned, got %v", errCode)
+	}
+}
+
+func TestRecentlyConfirmed(t *testing.T) {
+	pool, store := newTestPool()
+	txns := newTestEqualFeeTxns(newTestFunding(store, 1000, 1000))
+	for _, txn := range txns {
+		appendTestTxn(t, pool, txn)
+	}
+	if pool.WasRecentlyConfirmed(txns[0].Hash()) {
+		t.Fatal("expected the pooled transaction not confirmed")
+	}
+	pool.CleanSubmittedTransactions(&ledger.Block{Transactions: []*transaction.Transaction{txns[0]}})
+	if !pool.WasRecentlyConfirmed(txns[0].Hash()) || pool.WasRecentlyConfirmed(txns[1].Hash()) {
+		t.Fatal("expected only the transaction of the block reported as recently confirmed")
+	}
+
+	recent := recentHashes{}
+	recent.init(2)
+	a, b, c := common.Uint256{1}, common.Uint256{2}, common.Uint256{3}
+	recent.add(a)
+	recent.add(b)
+	recent.contains(a)
+	recent.add(c)
+	if !recent.contains(a) || recent.contains(b) || !recent.contains(c) {
+		t.Fatal("expected the least recently used hash dropped")
+	}
+}
+
+func TestSummary(t *testing.T) {
+	pool, store := newTestPool()
+	if summary := pool.Summary(); summary != "pool: 0 txns, 0 bytes, 0 total fees, top-fee-rate 0" {
+		t.Fatalf("unexpected summary of the empty pool %q", summary)
+	}
+	funding := newTestFunding(store, 1000, 1000)
+	low := newTestTxn([]*transaction.UTXOTxInput{spend(funding, 0)}, newTestOutput(990))
+	high := newTestTxn([]*transaction.UTXOTxInput{spend(funding, 1)}, newTestOutput(900))
+	appendTestTxn(t, pool, low)
+	appendTestTxn(t, pool, high)
+
+	size := len(low.ToArray()) + len(high.ToArray())
+	rate := getFeeRate(100, Weight(high))
+	expected := fmt.Sprintf("pool: 2 txns, %d bytes, %v total fees, top-fee-rate %v", size, common.Fixed64(110), rate)
+	if summary := pool.Summary(); summary != expected {
+		t.Fatalf("expected summary %q, got %q", expected, summary)
+	}
+}
+
+func TestCollectMetrics(t *testing.T) {
+	pool, store := newTestPool()
+	funding := newTestFunding(store, 1000, 1000)
+	txn := newTestTxn([]*transaction.UTXOTxInput{spend(funding, 0)}, newTestOutput(990))
+	if errCode := pool.AppendTxnPool(txn, true); errCode != ErrNoError {
+		t.Fatalf("append transaction failed: %v", errCode)
+	}
+	pool.Freeze()
+	pool.AppendTxnPool(newTestTxn([]*transaction.UTXOTxInput{spend(funding, 1)}, newTestOutput(990)), true)
+
+	values := make(map[string]float64)
+	for _, m := range pool.Collect() {
+		key := m.Name
+		for _, label := range []string{"type", "source", "reason", "le"} {
+			if v, ok := m.Labels[label]; ok {
+				key += "{" + v + "}"
+			}
+		}
+		values[key] = m.Value
+	}
+	expected := map[string]float64{
+		"txnpool_transactions":                         1,
+		"txnpool_bytes":                                float64(pool.txnBytes),
+		"txnpool_fees":                                 0.0000001,
+		"txnpool_transactions_by_type{0x80}":           1,
+		"txnpool_admissions_total{submitted}":          1,
+		"txnpool_admissions_total{reinserted}":         0,
+		"txnpool_verify_duration_seconds_bucket{+Inf}": 1,
+		"txnpool_verify_duration_seconds_count":        1,
+	}
+	expected["txnpool_rejections_total{"+ErrPoolFrozen.Error()+"}"] = 1
+	for name, value := range expected {
+		if got, ok := values[name]; !ok || got != value {
+			t.Fatalf("expected metric %s = %v, got %v (present %v)", name, value, got, ok)
+		}
+	}
+
+	buf := new(bytes.Buffer)
+	metrics.WriteText(buf, pool.Collect())
+	for _, line := range []string{"# TYPE txnpool_transactions gauge", "# TYPE txnpool_verify_duration_seconds histogram",
+		`txnpool_admissions_total{source="submitted"} 1`} {
+		if !strings.Contains(buf.String(), line) {
+			t.Fatalf("expected %q in the text format, got\n%s", line, buf.String())
+		}
+	}
+}
+
+func TestIssueSummaryMergesOutputs(t *testing.T) {
+	pool, store := newTestPool()
+	assetID, otherID := common.Uint256{7}, common.Uint256{8}
+	newTestAsset(store, assetID, 1000)
+	newTestAsset(store, otherID, 100)
+
+	issue := newTestIssue(assetID, 100, 200)
+	appendTestTxn(t, pool, issue)
+	if amount := pool.getAssetIssueAmount(assetID); amount != 300 {
+		t.Fatalf("expected the outputs of the asset summed once to 300, got %v", amount)
+	}
+
+	//rejected for the other asset, whichever asset is checked first
+	for i := 0; i < 10; i++ {
+		overIssue := newTestIssue(assetID, 100, common.Fixed64(i))
+		overIssue.Outputs = append(overIssue.Outputs, &transaction.TxOutput{AssetID: otherID, Value: 500})
+		if errCode := pool.verifyTransactionWithTxnPool(overIssue); errCode != ErrSummaryAsset {
+			t.Fatalf("expected ErrSummaryAsset, got %v", errCode)
+		}
+		if pool.getAssetIssueAmount(assetID) != 300 || pool.getAssetIssueAmount(otherID) != 0 {
+			t.Fatalf("expected the rejected issue to leave the summary unchanged, got %v and %v",
+				pool.getAssetIssueAmount(assetID), pool.getAssetIssueAmount(otherID))
+		}
+	}
+
+	pool.CleanSubmittedTransactions(&ledger.Block{Transactions: []*transaction.Transaction{issue}})
+	if amount := pool.getAssetIssueAmount(assetID); amount != 0 {
+		t.Fatalf("expected the summary cleaned to 0, got %v", amount)
+	}
+}
+
+func TestDrain(t *testing.T) {
+	pool, store := newTestPool()
+	txns := newTestEqualFeeTxns(newTestFunding(store, 1000, 1000, 1000, 1000, 1000))
+	for _, txn := range txns {
+		appendTestTxn(t, pool, txn)
+	}
+	snapshot := pool.copytxnList()
+
+	streamed := make(map[common.Uint256]struct{})
+	removed := make(map[common.Uint256]struct{})
+	stream := pool.Drain(context.Background())
+	first := <-stream
+	streamed[first.Hash()] = struct{}{}
+	//remove the transactions concurrently with the stream
+	for _, txn := range txns[:2] {
+		if txn != first {
+			pool.removeTransaction(txn)
+			removed[txn.Hash()] = struct{}{}
+		}
+	}
+	for txn := range stream {
+		streamed[txn.Hash()] = struct{}{}
+	}
+	for hash := range snapshot {
+		_, ok := streamed[hash]
+		if _, gone := removed[hash]; !ok && !gone {
+			t.Fatalf("expected transaction %x streamed", hash)
+		}
+	}
+	for hash := range streamed {
+		if _, ok := snapshot[hash]; !ok {
+			t.Fatalf("expected only the transactions of the snapshot streamed, got %x", hash)
+		}
+	}
+	if pool.GetTransactionCount() != len(txns)-len(removed) {
+		t.Fatal("expected the pool unchanged by the stream")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stream = pool.Drain(ctx)
+	<-stream
+	cancel()
+	count := 0
+	for range stream {
+		count++
+	}
+	if count > 1 {
+		t.Fatalf("expected the stream closed after the cancellation, got %d more", count)
+	}
+}
+
+func TestTypeLimits(t *testing.T) {
+	pool, store := newTestPool()
+	old := config.Parameters.MaxPoolSizeByType
+	defer func() { config.Parameters.MaxPoolSizeByType = old }()
+	config.Parameters.MaxPoolSizeByType = map[string]int{"TransferAsset": 2, "IssueAsset": 0}
+	assetID := common.Uint256{9}
+	newTestAsset(store, assetID, 1000)
+
+	txns := newTestEqualFeeTxns(newTestFunding(store, 1000, 1000, 1000))
+	for _, txn := range txns[:2] {
+		if errCode := pool.AppendTxnPool(txn, true); errCode != ErrNoError {
+			t.Fatalf("expected the transfer admitted, got %v", errCode)
+		}
+	}
+	if errCode := pool.AppendTxnPool(txns[2], true); errCode != ErrTypeLimitReached {
+		t.Fatalf("expected ErrTypeLimitReached for the transfers, got %v", errCode)
+	}
+	for i := 1; i <= 3; i++ {
+		if errCode := pool.AppendTxnPool(newTestIssue(assetID, common.Fixed64(i)), true); errCode != ErrNoError {
+			t.Fatalf("expected the issue admitted while the transfers are full, got %v", errCode)
+		}
+	}
+
+	pool.CleanSubmittedTransactions(&ledger.Block{Transactions: txns[:1]})
+	if errCode := pool.AppendTxnPool(txns[2], true); errCode != ErrNoError {
+		t.Fatalf("expected the transfer admitted after one left the pool, got %v", errCode)
+	}
+}
+
+//a confirmed transaction paying 1000 to each program hash, and the address of each
+func newTestOwnedFunding(t *testing.T, store *testLedgerStore, owners ...common.Uint160) (*transaction.Transaction, []string) {
+	outputs := []*transaction.TxOutput{}
+	addresses := []string{}
+	for _, owner := range owners {
+		output := newTestOutput(1000)
+		output.ProgramHash = owner
+		outputs = append(outputs, output)
+		address, err := owner.ToAddress()
+		if err != nil {
+			t.Fatal(err)
+		}
+		addresses = append(addresses, address)
+	}
+	funding := newTestTxn(nil, outputs...)
+	store.addTxn(funding)
+	return funding, addresses
+}
+
+func setAdmissionLists(allowlist, denylist []string) func() {
+	old := *config.Parameters
+	config.Parameters.AdmissionAllowlist = allowlist
+	config.Parameters.AdmissionDenylist = denylist
+	return func() {
+		config.Parameters.AdmissionAllowlist = old.AdmissionAllowlist
+		config.Parameters.AdmissionDenylist = old.AdmissionDenylist
+	}
+}
+
+func TestAdmissionDenylist(t *testing.T) {
+	pool, store := newTestPool()
+	funding, addresses := newTestOwnedFunding(t, store, common.Uint160{1}, common.Uint160{2})
+	defer setAdmissionLists(nil, addresses[1:])()
+
+	denied := newTestTxn([]*transaction.UTXOTxInput{spend(funding, 0), spend(funding, 1)}, newTestOutput(1990))
+	if errCode := pool.AppendTxnPool(denied, true); errCode != ErrDenied {
+		t.Fatalf("expected spending an output of a denied address rejected, got %v", errCode)
+	}
+	if pool.getInputUTXOList(spend(funding, 0)) != nil {
+		t.Fatal("expected the input of the denied transaction unclaimed")
+	}
+	if errCode := pool.AppendTxnPool(newTestTxn([]*transaction.UTXOTxInput{spend(funding, 0)}, newTestOutput(990)), true); errCode != ErrNoError {
+		t.Fatalf("expected spending an output of another address admitted, got %v", errCode)
+	}
+}
+
+func TestAdmissionAllowlist(t *testing.T) {
+	pool, store := newTestPool()
+	funding, addresses := newTestOwnedFunding(t, store, common.Uint160{1}, common.Uint160{2})
+	defer setAdmissionLists(addresses[:1], nil)()
+
+	if errCode := pool.AppendTxnPool(newTestTxn([]*transaction.UTXOTxInput{spend(funding, 1)}, newTestOutput(990)), true); errCode != ErrDenied {
+		t.Fatalf("expected spending an output of an address not allowed rejected, got %v", errCode)
+	}
+	if errCode := pool.AppendTxnPool(newTestTxn([]*transaction.UTXOTxInput{spend(funding, 0)}, newTestOutput(990)), true); errCode != ErrNoError {
+		t.Fatalf("expected spending an output of an allowed address admitted, got %v", errCode)
+	}
+}
+
+func TestOldestPerSender(t *testing.T) {
+	clock := newTestClock()
+	pool, store := newTestPool(withClock(clock))
+	alice, bob, carol := common.Uint160{1}, common.Uint160{2}, common.Uint160{3}
+	funding, _ := newTestOwnedFunding(t, store, alice, alice, bob, carol)
+	appendTestTxn(t, pool, newTestTxn([]*transaction.UTXOTxInput{spend(funding, 0)}, newTestOutput(990)))
+	clock.Advance(30 * time.Second)
+	appendTestTxn(t, pool, newTestTxn([]*transaction.UTXOTxInput{spend(funding, 1)}, newTestOutput(990)))
+	appendTestTxn(t, pool, newTestTxn([]*transaction.UTXOTxInput{spend(funding, 2), spend(funding, 3)}, newTestOutput(1990)))
+	clock.Advance(10 * time.Second)
+
+	oldest := pool.OldestPerSender()
+	expected := map[common.Uint160]time.Duration{alice: 40 * time.Second, bob: 10 * time.Second, carol: 10 * time.Second}
+	if len(oldest) != len(expected) {
+		t.Fatalf("expected %d senders, got %d", len(expected), len(oldest))
+	}
+	for sender, age := range expected {
+		if oldest[sender] != age {
+			t.Fatalf("expected the oldest transaction of %x aged %v, got %v", sender, age, oldest[sender])
+		}
+	}
+}
+
+func TestFindSponsorshipTargets(t *testing.T) {
+	clock := newTestClock()
+	pool, store := newTestPool(withClock(clock))
+	defer setMaxTxInBlock(2)()
+	funding := newTestFunding(store, 1000, 1000, 1000, 1000, 1000)
+	oldLow := newTestTxn([]*transaction.UTXOTxInput{spend(funding, 0)}, newTestOutput(995))
+	oldSpent := newTestTxn([]*transaction.UTXOTxInput{spend(funding, 1)}, newTestOutput(996))
+	oldHigh := newTestTxn([]*transaction.UTXOTxInput{spend(funding, 2)}, newTestOutput(900))
+	for _, txn := range []*transaction.Transaction{oldLow, oldSpent, oldHigh} {
+		appendTestTxn(t, pool, txn)
+	}
+	clock.Advance(time.Hour)
+	newLow := newTestTxn([]*transaction.UTXOTxInput{spend(funding, 3)}, newTestOutput(994))
+	newHigh := newTestTxn([]*transaction.UTXOTxInput{spend(funding, 4)}, newTestOutput(800))
+	//the child spending the only output of oldSpent pays a fee ranking below the block
+	child := newTestTxn([]*transaction.UTXOTxInput{spend(oldSpent, 0)}, newTestOutput(995))
+	for _, txn := range []*transaction.Transaction{newLow, newHigh, child} {
+		appendTestTxn(t, pool, txn)
+	}
+
+	targets := pool.FindSponsorshipTargets(600)
+	if len(targets) != 1 || targets[0] != oldLow {
+		t.Fatalf("expected only the old low fee transaction with an unspent output, got %d targets", len(targets))
+	}
+	targets = pool.FindSponsorshipTargets(0)
+	if len(targets) != 3 || targets[0] != child {
+		t.Fatalf("expected the low fee transactions lowest fee rate first, got %d targets", len(targets))
+	}
+}
+
+func TestStuckTransactions(t *testing.T) {
+	clock := newTestClock()
+	pool, store := newTestPool(withClock(clock))
+	funding := newTestFunding(store, 1000, 1000, 1000)
+	oldest := newTestTxn([]*transaction.UTXOTxInput{spend(funding, 0)}, newTestOutput(990))
+	appendTestTxn(t, pool, oldest)
+	clock.Advance(10 * time.Minute)
+	older := newTestTxn([]*transaction.UTXOTxInput{spend(funding, 1)}, newTestOutput(990))
+	appendTestTxn(t, pool, older)
+	clock.Advance(20 * time.Minute)
+	recent := newTestTxn([]*transaction.UTXOTxInput{spend(funding, 2)}, newTestOutput(990))
+	appendTestTxn(t, pool, recent)
+	clock.Advance(time.Minute)
+
+	stuck := pool.StuckTransactions(15 * time.Minute)
+	if len(stuck) != 2 || stuck[0] != oldest || stuck[1] != older {
+		t.Fatalf("expected the transactions pooled over the threshold oldest first, got %d", len(stuck))
+	}
+	if stuck := pool.StuckTransactions(time.Hour); len(stuck) != 0 {
+		t.Fatalf("expected no transaction pooled for an hour, got %d", len(stuck))
+	}
+}
+
+//fee calculator ignoring the balance of an asset
+type feeFreeAsset struct {
+	assetID common.Uint256
+}
+
+func (c feeFreeAsset) Fee(txn *transaction.Transaction, reference map[*transaction.UTXOTxInput]*transaction.TxOutput) common.Fixed64 {
+	var fee common.Fixed64
+	for _, output := range reference {
+		if output.AssetID != c.assetID {
+			fee += output.Value
+		}
+	}
+	for _, output := range txn.Outputs {
+		if output.AssetID != c.assetID {
+			fee -= output.Value
+		}
+	}
+	return fee
+}
+
+func TestFeeCalculator(t *testing.T) {
+	freeID := common.Uint256{10}
+	pool, store := newTestPool(WithFeeCalculator(feeFreeAsset{assetID: freeID}))
+	testVerifierOf(pool).ledger = func(txn *transaction.Transaction) ErrCode { return ErrNoError }
+	funding := newTestFunding(store, 1000, 1000)
+	free := newTestTxn(nil, &transaction.TxOutput{AssetID: freeID, Value: 1000})
+	store.addTxn(free)
+
+	freeSpend := newTestTxn([]*transaction.UTXOTxInput{spend(free, 0)}, &transaction.TxOutput{AssetID: freeID, Value: 900})
+	if errCode := pool.AppendTxnPool(freeSpend, true); errCode != ErrNoError {
+		t.Fatalf("append transaction failed: %v", errCode)
+	}
+	if fee := pool.txnList[freeSpend.Hash()].fee; fee != 0 {
+		t.Fatalf("expected the fee-free asset to pay no fee, got %v", fee)
+	}
+	paid := newTestTxn([]*transaction.UTXOTxInput{spend(funding, 0)}, newTestOutput(900))
+	if errCode := pool.AppendTxnPool(paid, true); errCode != ErrNoError {
+		t.Fatalf("append transaction failed: %v", errCode)
+	}
+	if fee := pool.txnList[paid.Hash()].fee; fee != 100 {
+		t.Fatalf("expected the fee 100 by the calculator, got %v", fee)
+	}
+
+	defaultPool, defaultStore := newTestPool()
+	defaultStore.addTxn(free)
+	if fee := defaultPool.getTxnFee(freeSpend); fee != 100 {
+		t.Fatalf("expected the value difference by default, got %v", fee)
+	}
+}
+
+func TestLockedAssets(t *testing.T) {
+	pool, store := newTestPool()
+	assetID, otherID := common.Uint256{11}, common.Uint256{12}
+	newTestAsset(store, assetID, 1000)
+	newTestAsset(store, otherID, 1000)
+	lock, other := newTestLock(assetID), newTestLock(otherID)
+	appendTestTxn(t, pool, lock)
+	appendTestTxn(t, pool, other)
+
+	locked := pool.LockedAssets()
+	expected := []string{lock.Payload.(*payload.LockAsset).ToString(), other.Payload.(*payload.LockAsset).ToString()}
+	if len(locked) != 2 || locked[0] != expected[0] || locked[1] != expected[1] {
+		t.Fatalf("expected the locked assets %v, got %v", expected, locked)
+	}
+	pool.CleanSubmittedTransactions(&ledger.Block{Transactions: []*transaction.Transaction{lock}})
+	if locked := pool.LockedAssets(); len(locked) != 1 || locked[0] != expected[1] {
+		t.Fatalf("expected the lock of the block removed, got %v", locked)
+	}
+}
+
+func TestRejectConfirmed(t *testing.T) {
+	pool, store := newTestPool()
+	verified := 0
+	testVerifierOf(pool).structure = func(txn *transaction.Transaction) ErrCode {
+		verified++
+		return ErrNoError
+	}
+	old := config.Parameters.RejectConfirmedTransactions
+	defer func() { config.Parameters.RejectConfirmedTransactions = old }()
+	config.Parameters.RejectConfirmedTransactions = true
+
+	funding := newTestFunding(store, 1000, 1000)
+	confirmed := newTestTxn([]*transaction.UTXOTxInput{spend(funding, 0)}, newTestOutput(990))
+	store.addTxn(confirmed)
+	if errCode := pool.AppendTxnPool(confirmed, true); errCode != ErrAlreadyConfirmed {
+		t.Fatalf("expected ErrAlreadyConfirmed, got %v", errCode)
+	}
+	if verified != 0 {
+		t.Fatal("expected the confirmed transaction rejected before the verification")
+	}
+
+	txn := newTestTxn([]*transaction.UTXOTxInput{spend(funding, 1)}, newTestOutput(990))
+	if errCode := pool.AppendTxnPool(txn, true); errCode != ErrNoError {
+		t.Fatalf("append transaction failed: %v", errCode)
+	}
+	pool.CleanSubmittedTransactions(&ledger.Block{Transactions: []*transaction.Transaction{txn}})
+	if errCode := pool.AppendTxnPool(txn, true); errCode != ErrAlreadyConfirmed {
+		t.Fatalf("expected the recently confirmed transaction rejected, got %v", errCode)
+	}
+}
+
+func TestFeeRateCDF(t *testing.T) {
+	pool, store := newTestPool()
+	if points := pool.FeeRateCDF(); len(points) != 0 {
+		t.Fatalf("expected no point for the empty pool, got %v", points)
+	}
+	funding := newTestFunding(store, 1000, 1000, 1000, 1000)
+	fees := []common.Fixed64{10, 50, 50, 100}
+	for i, fee := range fees {
+		appendTestTxn(t, pool, newTestTxn([]*transaction.UTXOTxInput{spend(funding, uint16(i))}, newTestOutput(1000-fee)))
+	}
+	//the transactions have the same size
+	size := 0
+	for _, entry := range pool.txnList {
+		size = entry.size
+	}
+	expected := []FeeRatePoint{
+		{FeeRate: getFeeRate(100, size), CumulativeBytes: size},
+		{FeeRate: getFeeRate(50, size), CumulativeBytes: 3 * size},
+		{FeeRate: getFeeRate(10, size), CumulativeBytes: 4 * size},
+	}
+	points := pool.FeeRateCDF()
+	if len(points) != len(expected) {
+		t.Fatalf("expected %d points, got %v", len(expected), points)
+	}
+	for i := range expected {
+		if points[i] != expected[i] {
+			t.Fatalf("expected point %d %+v, got %+v", i, expected[i], points[i])
+		}
+	}
+}
+
+func TestMaintenanceWindow(t *testing.T) {
+	clock := newTestClock()
+	pool, store := newTestPool(withClock(clock))
+	old := *config.Parameters
+	defer func() {
+		config.Parameters.MaintenanceWindowStart = old.MaintenanceWindowStart
+		config.Parameters.MaintenanceWindowEnd = old.MaintenanceWindowEnd
+		config.Parameters.MaintenanceWindowRecurrence = old.MaintenanceWindowRecurrence
+	}()
+	start := clock.Now().Add(time.Hour)
+	config.Parameters.MaintenanceWindowStart = start.Format(time.RFC3339)
+	config.Parameters.MaintenanceWindowEnd = start.Add(10 * time.Minute).Format(time.RFC3339)
+	pool.ReloadLimits()
+	funding := newTestFunding(store, 1000, 1000, 1000)
+	admit := func(i int) ErrCode {
+		return pool.AppendTxnPool(newTestTxn([]*transaction.UTXOTxInput{spend(funding, uint16(i))}, newTestOutput(990)), true)
+	}
+
+	if errCode := admit(0); errCode != ErrNoError {
+		t.Fatalf("expected the admission before the window, got %v", errCode)
+	}
+	clock.Advance(time.Hour + 5*time.Minute)
+	if errCode := admit(1); errCode != ErrPoolFrozen {
+		t.Fatalf("expected ErrPoolFrozen inside the window, got %v", errCode)
+	}
+	clock.Advance(10 * time.Minute)
+	if errCode := admit(1); errCode != ErrNoError {
+		t.Fatalf("expected the admission after the window, got %v", errCode)
+	}
+
+	config.Parameters.MaintenanceWindowRecurrence = 24 * 3600
+	pool.ReloadLimits()
+	clock.Advance(24*time.Hour - 10*time.Minute)
+	if errCode := admit(2); errCode != ErrPoolFrozen {
+		t.Fatalf("expected ErrPoolFrozen inside the recurring window, got %v", errCode)
+	}
+}
+
+func TestIssueRegistrationMissing(t *testing.T) {
+	pool, store := newTestPool()
+	if errCode := pool.verifyTransactionWithTxnPool(newTestIssue(common.Uint256{13}, 100)); errCode != ErrUnknownAsset {
+		t.Fatalf("expected ErrUnknownAsset for an unregistered asset, got %v", errCode)
+	}
+
+	//a record which is not a registration under the asset ID
+	record := newTestTxn(nil, newTestOutput(100))
+	record.SetHash(common.Uint256{14})
+	store.addTxn(record)
+	if errCode := pool.verifyTransactionWithTxnPool(newTestIssue(common.Uint256{14}, 100)); errCode != ErrDataIntegrity {
+		t.Fatalf("expected ErrDataIntegrity for a record of another type, got %v", errCode)
+	}
+	if pool.getAssetIssueAmount(common.Uint256{13}) != 0 || pool.getAssetIssueAmount(common.Uint256{14}) != 0 {
+		t.Fatal("expected the rejected issues to leave no summary")
+	}
+}
+
+func TestReconcileInventory(t *testing.T) {
+	pool, store := newTestPool()
+	txns := newTestEqualFeeTxns(newTestFunding(store, 1000, 1000, 1000))
+	for _, txn := range txns {
+		appendTestTxn(t, pool, txn)
+	}
+	peerOnly := []common.Uint256{{15}, {16}}
+	peerHashes := []common.Uint256{txns[0].Hash(), peerOnly[0], txns[1].Hash(), peerOnly[1], peerOnly[0]}
+	missing, extra := pool.ReconcileInventory(peerHashes)
+	if len(missing) != 2 || missing[0] != peerOnly[0] || missing[1] != peerOnly[1] {
+		t.Fatalf("expected the peer-only hashes missing locally, got %x", missing)
+	}
+	if len(extra) != 1 || extra[0] != txns[2].Hash() {
+		t.Fatalf("expected the local-only hash extra, got %x", extra)
+	}
+}
+
+//fee calculator giving the same fee for every transaction
+type fixedFee common.Fixed64
+
+func (f fixedFee) Fee(txn *transaction.Transaction, reference map[*transaction.UTXOTxInput]*transaction.TxOutput) common.Fixed64 {
+	return common.Fixed64(f)
+}
+
+func TestNegativeFee(t *testing.T) {
+	pool, store := newTestPool(WithFeeCalculator(fixedFee(-1)))
+	funding := newTestFunding(store, 1000)
+	txn := newTestTxn([]*transaction.UTXOTxInput{spend(funding, 0)}, newTestOutput(990))
+	for _, poolVerify := range []bool{true, false} {
+		if errCode := pool.AppendTxnPool(txn, poolVerify); errCode != ErrNegativeFee {
+			t.Fatalf("expected ErrNegativeFee, got %v", errCode)
+		}
+	}
+	if pool.GetTransactionCount() != 0 || pool.getInputUTXOList(spend(funding, 0)) != nil {
+		t.Fatal("expected the negative fee transaction kept out of the pool")
+	}
+}
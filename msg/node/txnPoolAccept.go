@@ -0,0 +1,48 @@
+package node
+
+import (
+	"IPT/common"
+	. "IPT/common/errors"
+	"IPT/core/transaction"
+)
+
+//tell weather the transaction would be admitted by AppendTxnPool right now
+//and the reason when it wouldn't, e.g. for a wallet to check a fee bump
+//before sending it. the transaction is verified and checked against the
+//admission policies, the fee floor, the replacement rules and the size
+//limits, but the pool is not changed. a transaction with missing parents
+//would be buffered as an orphan and is not accepted.
+func (this *TXNPool) WouldAccept(txn *transaction.Transaction) (accepted bool, reason ErrCode) {
+	if errCode := this.appendTxnPool(txn, true, true, TxnExpiry{}); errCode != ErrNoError {
+		return false, errCode
+	}
+	return true, ErrNoError
+}
+
+//check the transaction wouldn't be evicted right away as the lowest fee rate
+//of a full pool, once the transactions it replaces are removed.
+func (this *TXNPool) checkRoom(txn *transaction.Transaction, limits PoolLimits, replaced []*transaction.Transaction) ErrCode {
+	entry := this.newTxnEntry(txn)
+	this.RLock()
+	defer this.RUnlock()
+	excluded := make(map[common.Uint256]struct{}, len(replaced))
+	count, bytes := len(this.txnList)+1, this.txnBytes+entry.size
+	for _, r := range replaced {
+		if pooled, ok := this.txnList[r.Hash()]; ok {
+			excluded[r.Hash()] = struct{}{}
+			count--
+			bytes -= pooled.size
+		}
+	}
+	full := limits.MaxPoolSize > 0 && count > limits.MaxPoolSize
+	if limits.MaxPoolBytes > 0 && bytes > limits.MaxPoolBytes {
+		full = true
+	}
+	if !full {
+		return ErrNoError
+	}
+	if victim, lowest := this.evictionVictimExcept(excluded); victim == nil || entry.feeRate() <= lowest {
+		return ErrPoolFull
+	}
+	return ErrNoError
+}
@@ -0,0 +1,68 @@
+package node
+
+import (
+	"IPT/common"
+	"IPT/common/config"
+	. "IPT/common/errors"
+	"IPT/common/log"
+	"IPT/core/transaction"
+	"fmt"
+)
+
+//parse the addresses of the config to program hashes, the invalid ones are
+//logged and skipped.
+func programHashSet(addresses []string) map[common.Uint160]struct{} {
+	set := make(map[common.Uint160]struct{}, len(addresses))
+	for _, address := range addresses {
+		programHash, err := common.ToScriptHash(address)
+		if err != nil {
+			log.Info(fmt.Sprintf("Invalid address %s in the admission list, %v", address, err))
+			continue
+		}
+		set[programHash] = struct{}{}
+	}
+	return set
+}
+
+//check the owners of the outputs the transaction spends against
+//AdmissionDenylist and AdmissionAllowlist. a transaction spending an output
+//of a denied address is rejected, and so is one spending an output of an
+//address not allowed when the allowlist is set. the transactions without
+//inputs have no owner and are not checked.
+func (this *TXNPool) checkAdmissionLists(txn *transaction.Transaction) ErrCode {
+	if len(config.Parameters.AdmissionDenylist) == 0 && len(config.Parameters.AdmissionAllowlist) == 0 {
+		return ErrNoError
+	}
+	owners, err := this.senders(txn)
+	if err != nil {
+		//left to the verification with the pool
+		return ErrNoError
+	}
+	denied := programHashSet(config.Parameters.AdmissionDenylist)
+	allowed := programHashSet(config.Parameters.AdmissionAllowlist)
+	for owner := range owners {
+		if _, ok := denied[owner]; ok {
+			log.Info(fmt.Sprintf("Transaction =%x rejected, spends an output of the denied program hash %x", txn.Hash(), owner))
+			return ErrDenied
+		}
+		if _, ok := allowed[owner]; len(allowed) > 0 && !ok {
+			log.Info(fmt.Sprintf("Transaction =%x rejected, spends an output of the program hash %x not allowed", txn.Hash(), owner))
+			return ErrDenied
+		}
+	}
+	return ErrNoError
+}
+
+//the program hashes owning the outputs the transaction spends, resolved
+//through the pool then the ledger.
+func (this *TXNPool) senders(txn *transaction.Transaction) (map[common.Uint160]struct{}, error) {
+	reference, err := this.getReference(txn)
+	if err != nil {
+		return nil, err
+	}
+	owners := make(map[common.Uint160]struct{}, len(reference))
+	for _, output := range reference {
+		owners[output.ProgramHash] = struct{}{}
+	}
+	return owners, nil
+}
@@ -0,0 +1,46 @@
+package node
+
+import (
+	"IPT/common"
+	"IPT/core/transaction"
+	"sort"
+)
+
+//index the pooled transaction by the program hashes its outputs pay, the
+//caller must hold the lock.
+func (this *TXNPool) indexAddresses(txnHash common.Uint256, txn *transaction.Transaction) {
+	for _, output := range txn.Outputs {
+		if _, ok := this.addressIndex[output.ProgramHash]; !ok {
+			this.addressIndex[output.ProgramHash] = make(map[common.Uint256]struct{})
+		}
+		this.addressIndex[output.ProgramHash][txnHash] = struct{}{}
+	}
+}
+
+//the caller must hold the lock
+func (this *TXNPool) unindexAddresses(txnHash common.Uint256, txn *transaction.Transaction) {
+	for _, output := range txn.Outputs {
+		delete(this.addressIndex[output.ProgramHash], txnHash)
+		if len(this.addressIndex[output.ProgramHash]) == 0 {
+			delete(this.addressIndex, output.ProgramHash)
+		}
+	}
+}
+
+//get the pooled transactions paying the program hash in any output, e.g. for
+//a recipient to see the incoming pending payments, in the order they were
+//admitted.
+func (this *TXNPool) GetTransactionsByOutputAddress(hash common.Uint160) []*transaction.Transaction {
+	this.RLock()
+	defer this.RUnlock()
+	entries := make([]*txnEntry, 0, len(this.addressIndex[hash]))
+	for txnHash := range this.addressIndex[hash] {
+		entries = append(entries, this.txnList[txnHash])
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].seq < entries[j].seq })
+	txns := make([]*transaction.Transaction, 0, len(entries))
+	for _, entry := range entries {
+		txns = append(txns, entry.txn)
+	}
+	return txns
+}
@@ -0,0 +1,67 @@
+package node
+
+import (
+	"time"
+)
+
+//time source of the pool, replaced by a fake clock in tests
+type poolClock interface {
+	//the wall clock, only compared with configured dates
+	Now() time.Time
+	//the monotonic time since the clock started, the ages and TTLs of the
+	//pool are measured with it so a jump of the system time doesn't change them
+	Elapsed() time.Duration
+	NewTicker(d time.Duration) poolTicker
+}
+
+type poolTicker interface {
+	C() <-chan time.Time
+	Stop()
+}
+
+type systemClock struct {
+	start time.Time // holds the monotonic clock reading
+}
+
+func newSystemClock() systemClock {
+	return systemClock{start: time.Now()}
+}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+func (c systemClock) Elapsed() time.Duration {
+	return time.Since(c.start)
+}
+
+func (systemClock) NewTicker(d time.Duration) poolTicker {
+	return systemTicker{time.NewTicker(d)}
+}
+
+type systemTicker struct {
+	ticker *time.Ticker
+}
+
+func (t systemTicker) C() <-chan time.Time {
+	return t.ticker.C
+}
+
+func (t systemTicker) Stop() {
+	t.ticker.Stop()
+}
+
+//the time elapsed since the Elapsed reading, never negative
+func (this *TXNPool) age(since time.Duration) time.Duration {
+	if age := this.clock.Elapsed() - since; age > 0 {
+		return age
+	}
+	return 0
+}
+
+//use the clock instead of the system time
+func withClock(clock poolClock) TXNPoolOption {
+	return func(pool *TXNPool) {
+		pool.clock = clock
+	}
+}
@@ -0,0 +1,50 @@
+package node
+
+import (
+	"IPT/common"
+	"IPT/crypto"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+//the pooled transaction hashes in ascending order, the caller must hold the lock.
+func (this *TXNPool) sortedHashes() []common.Uint256 {
+	hashes := make([]common.Uint256, 0, len(this.txnList))
+	for hash := range this.txnList {
+		hashes = append(hashes, hash)
+	}
+	sort.Slice(hashes, func(i, j int) bool { return hashes[i].CompareTo(hashes[j]) < 0 })
+	return hashes
+}
+
+//get the Merkle root of the sorted pooled transaction hashes, the zero hash
+//when the pool is empty. peers holding the same transactions get the same root.
+func (this *TXNPool) PoolCommitment() common.Uint256 {
+	this.RLock()
+	defer this.RUnlock()
+	if len(this.txnList) == 0 {
+		return common.Uint256{}
+	}
+	root, _ := crypto.ComputeRoot(this.sortedHashes())
+	return root
+}
+
+//get the Merkle path proving the transaction is in the pool together with
+//the PoolCommitment of the snapshot it was taken from, the proof is checked
+//with crypto.VerifyMerkleProof.
+func (this *TXNPool) MembershipProof(hash common.Uint256) ([]crypto.MerklePathNode, common.Uint256, error) {
+	this.RLock()
+	defer this.RUnlock()
+	if _, ok := this.txnList[hash]; !ok {
+		return nil, common.Uint256{}, errors.New(fmt.Sprintf("transaction %x not in the pool", hash))
+	}
+	hashes := this.sortedHashes()
+	index := sort.Search(len(hashes), func(i int) bool { return hashes[i].CompareTo(hash) >= 0 })
+	path, err := crypto.MerkleProof(hashes, index)
+	if err != nil {
+		return nil, common.Uint256{}, err
+	}
+	root, _ := crypto.ComputeRoot(hashes)
+	return path, root, nil
+}
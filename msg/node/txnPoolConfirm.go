@@ -0,0 +1,171 @@
+package node
+
+import (
+	"IPT/common"
+	"IPT/common/config"
+	. "IPT/common/errors"
+	"IPT/common/log"
+	"IPT/core/ledger"
+	"IPT/core/transaction"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+//how long the block height of a referenced transaction is cached
+const INPUTHEIGHTCACHETIME = 10 * time.Second
+
+type cachedHeight struct {
+	height  uint32
+	expires time.Duration // clock.Elapsed when the value expires
+}
+
+//block heights of the transactions referenced by the pooled inputs
+type inputHeightCache struct {
+	sync.Mutex
+	heights map[common.Uint256]cachedHeight
+}
+
+func (c *inputHeightCache) init() {
+	c.Lock()
+	defer c.Unlock()
+	c.heights = make(map[common.Uint256]cachedHeight)
+}
+
+func (c *inputHeightCache) get(hash common.Uint256, now time.Duration) (uint32, bool) {
+	c.Lock()
+	defer c.Unlock()
+	cached, ok := c.heights[hash]
+	if !ok || now > cached.expires {
+		delete(c.heights, hash)
+		return 0, false
+	}
+	return cached.height, true
+}
+
+func (c *inputHeightCache) set(hash common.Uint256, height uint32, expires time.Duration) {
+	c.Lock()
+	defer c.Unlock()
+	c.heights[hash] = cachedHeight{height: height, expires: expires}
+}
+
+//get the transaction in txnpool like GetTxnPool, leaving out the transactions
+//spending an output with less than minInputConfirmations confirmations. the
+//block candidates picked by count leave out the transactions spending pooled
+//transactions, they wait for the block of their parents since a block isn't
+//kept in order and its transactions are verified with the ledger alone.
+func (this *TXNPool) GetTxnPoolWithMinInputConfirmations(byCount bool, minInputConfirmations uint32) map[common.Uint256]*transaction.Transaction {
+	var heights map[common.Uint256]uint32
+	if minInputConfirmations > 0 {
+		heights = this.resolveInputHeights()
+	}
+	this.RLock()
+	defer this.RUnlock()
+	count := config.Parameters.MaxTxInBlock
+	if count <= 0 {
+		byCount = false
+	}
+	txnMap := make(map[common.Uint256]*transaction.Transaction)
+	for _, entry := range this.selectionOrder() {
+		if byCount && len(txnMap) >= count {
+			break
+		}
+		if byCount && this.spendsPooledLocked(entry.txn) {
+			continue
+		}
+		if minInputConfirmations > 0 && this.inputConfirmations(entry.txn, heights) < minInputConfirmations {
+			continue
+		}
+		txnMap[entry.txn.Hash()] = entry.txn
+	}
+	return txnMap
+}
+
+//look up the block heights of the transactions referenced by the pooled
+//inputs without holding the lock, the ledger is not read under the lock.
+func (this *TXNPool) resolveInputHeights() map[common.Uint256]uint32 {
+	this.RLock()
+	referenced := make(map[common.Uint256]struct{})
+	for _, entry := range this.txnList {
+		for _, input := range entry.txn.UTXOInputs {
+			if _, ok := this.txnList[input.ReferTxID]; !ok {
+				referenced[input.ReferTxID] = struct{}{}
+			}
+		}
+	}
+	this.RUnlock()
+	heights := make(map[common.Uint256]uint32, len(referenced))
+	for hash := range referenced {
+		if height, ok := this.inputHeight(hash); ok {
+			heights[hash] = height
+		}
+	}
+	return heights
+}
+
+//the least confirmations of the outputs spent by the transaction, the outputs
+//of pooled transactions and the ones missing from heights have none. the
+//caller must hold the lock.
+func (this *TXNPool) inputConfirmations(txn *transaction.Transaction, heights map[common.Uint256]uint32) uint32 {
+	confirmations := uint32(math.MaxUint32)
+	for _, input := range txn.UTXOInputs {
+		if _, ok := this.txnList[input.ReferTxID]; ok {
+			return 0
+		}
+		height, ok := heights[input.ReferTxID]
+		if !ok {
+			return 0
+		}
+		current := ledger.DefaultLedger.Blockchain.BlockHeight
+		if current < height {
+			return 0
+		}
+		if depth := current - height + 1; depth < confirmations {
+			confirmations = depth
+		}
+	}
+	return confirmations
+}
+
+func (this *TXNPool) inputHeight(hash common.Uint256) (uint32, bool) {
+	if ledger.DefaultLedger == nil {
+		return 0, false
+	}
+	now := this.clock.Elapsed()
+	if height, ok := this.inputHeights.get(hash, now); ok {
+		return height, true
+	}
+	height, err := ledger.DefaultLedger.Store.GetTransactionHeight(hash)
+	if err != nil {
+		return 0, false
+	}
+	this.inputHeights.set(hash, height, now+INPUTHEIGHTCACHETIME)
+	return height, true
+}
+
+//check the transaction spends no BookKeeping output with less than
+//MaturityDepth confirmations. the outputs whose height is unknown are left to
+//the verification with the ledger.
+func (this *TXNPool) checkMaturity(txn *transaction.Transaction) ErrCode {
+	depth := config.Parameters.MaturityDepth
+	if depth == 0 {
+		return ErrNoError
+	}
+	for _, input := range txn.UTXOInputs {
+		source, err := transaction.TxStore.GetTransaction(input.ReferTxID)
+		if err != nil || source.TxType != transaction.BookKeeping {
+			continue
+		}
+		height, ok := this.inputHeight(input.ReferTxID)
+		if !ok {
+			continue
+		}
+		current := ledger.DefaultLedger.Blockchain.BlockHeight
+		if current < height || current-height+1 < depth {
+			log.Info(fmt.Sprintf("Transaction =%x rejected, spends the reward of block %d before maturity", txn.Hash(), height))
+			return ErrImmatureSpend
+		}
+	}
+	return ErrNoError
+}
@@ -0,0 +1,78 @@
+package node
+
+import (
+	"IPT/common/config"
+	"sync"
+	"time"
+)
+
+//the number of depth samples kept, older samples are overwritten
+const MAXDEPTHSAMPLES = 1440
+
+//size of the pool at a point of time
+type DepthSample struct {
+	Time  time.Time
+	Count int // pooled transactions
+	Bytes int // serialized size of the pooled transactions
+}
+
+//ring buffer of the recent depth samples
+type depthHistory struct {
+	sync.Mutex
+	samples []DepthSample
+	next    int
+	full    bool
+}
+
+func (h *depthHistory) init(length int) {
+	h.Lock()
+	defer h.Unlock()
+	h.samples = make([]DepthSample, length)
+	h.next = 0
+	h.full = false
+}
+
+func (h *depthHistory) record(sample DepthSample) {
+	h.Lock()
+	defer h.Unlock()
+	if len(h.samples) == 0 {
+		return
+	}
+	h.samples[h.next] = sample
+	h.next = (h.next + 1) % len(h.samples)
+	if h.next == 0 {
+		h.full = true
+	}
+}
+
+//the samples from the oldest to the newest
+func (h *depthHistory) snapshot() []DepthSample {
+	h.Lock()
+	defer h.Unlock()
+	if !h.full {
+		return append([]DepthSample(nil), h.samples[:h.next]...)
+	}
+	samples := make([]DepthSample, 0, len(h.samples))
+	samples = append(samples, h.samples[h.next:]...)
+	return append(samples, h.samples[:h.next]...)
+}
+
+//get the recent samples of the pool size, oldest first. the pool is sampled
+//every PoolDepthSampleInterval seconds after Start.
+func (this *TXNPool) DepthHistory() []DepthSample {
+	return this.depthSamples.snapshot()
+}
+
+func (this *TXNPool) sampleDepth() {
+	this.RLock()
+	sample := DepthSample{Time: this.clock.Now(), Count: len(this.txnList)}
+	for _, entry := range this.txnList {
+		sample.Bytes += entry.size
+	}
+	this.RUnlock()
+	this.depthSamples.record(sample)
+}
+
+func depthSampleInterval() time.Duration {
+	return time.Duration(config.Parameters.PoolDepthSampleInterval) * time.Second
+}
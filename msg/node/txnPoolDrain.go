@@ -0,0 +1,38 @@
+package node
+
+import (
+	"IPT/common"
+	"IPT/core/transaction"
+	"context"
+)
+
+//stream the pooled transactions without holding the lock while they are
+//consumed, e.g. for a backup tool. the hashes are taken under a brief lock and
+//each transaction is looked up again when it is sent, the ones removed in the
+//meantime are skipped. the pool is not changed. the channel is closed when
+//all are sent or the context is cancelled.
+func (this *TXNPool) Drain(ctx context.Context) <-chan *transaction.Transaction {
+	this.RLock()
+	hashes := make([]common.Uint256, 0, len(this.txnList))
+	for hash := range this.txnList {
+		hashes = append(hashes, hash)
+	}
+	this.RUnlock()
+
+	txns := make(chan *transaction.Transaction)
+	go func() {
+		defer close(txns)
+		for _, hash := range hashes {
+			txn := this.GetTransaction(hash)
+			if txn == nil {
+				continue
+			}
+			select {
+			case txns <- txn:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return txns
+}
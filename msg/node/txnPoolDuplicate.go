@@ -0,0 +1,49 @@
+package node
+
+import (
+	"IPT/common"
+	"IPT/common/config"
+	"IPT/common/log"
+	"IPT/core/transaction"
+	"crypto/sha256"
+	"fmt"
+	"sync/atomic"
+)
+
+//the digest of the outputs in order, the zero hash when there is none
+func outputsDigest(txn *transaction.Transaction) common.Uint256 {
+	if len(txn.Outputs) == 0 {
+		return common.Uint256{}
+	}
+	data := []byte{}
+	for _, output := range txn.Outputs {
+		data = append(data, output.ToArray()...)
+	}
+	return common.Uint256(sha256.Sum256(data))
+}
+
+//index the outputs of the transaction added to the pool. a transaction
+//producing exactly the outputs of a pooled transaction from different inputs
+//may be a replay attempt, it is flagged when FlagDuplicateOutputs is set but
+//still accepted. the caller must hold the lock.
+func (this *TXNPool) indexOutputs(txnHash common.Uint256, digest common.Uint256) {
+	if digest == (common.Uint256{}) {
+		return
+	}
+	pooled, ok := this.outputSets[digest]
+	if !ok {
+		this.outputSets[digest] = txnHash
+		return
+	}
+	if config.Parameters.FlagDuplicateOutputs {
+		log.Warn(fmt.Sprintf("Transaction =%x produces the same outputs as the pooled transaction =%x", txnHash, pooled))
+		atomic.AddUint64(&this.admissions.DuplicateOutputs, 1)
+	}
+}
+
+//the caller must hold the lock.
+func (this *TXNPool) unindexOutputs(txnHash common.Uint256, digest common.Uint256) {
+	if pooled, ok := this.outputSets[digest]; ok && pooled == txnHash {
+		delete(this.outputSets, digest)
+	}
+}
@@ -0,0 +1,187 @@
+package node
+
+import (
+	"IPT/common"
+	"IPT/common/config"
+	"IPT/common/log"
+	"IPT/core/transaction"
+	"fmt"
+)
+
+//estimate the chance the pooled transaction is packed within the next blocks.
+//the capacity of the blocks is shared by the transactions ranked ahead of it,
+//the fewer of them the more room is left against later higher fee arrivals.
+func (this *TXNPool) ConfirmationProbability(hash common.Uint256, withinBlocks int) float64 {
+	this.RLock()
+	defer this.RUnlock()
+	rank, ok := this.selectionRank(hash)
+	if !ok || withinBlocks <= 0 {
+		return 0
+	}
+	if config.Parameters.MaxTxInBlock <= 0 {
+		return 1
+	}
+	capacity := config.Parameters.MaxTxInBlock * withinBlocks
+	if rank >= capacity {
+		return 0
+	}
+	return float64(capacity-rank) / float64(capacity)
+}
+
+//returned by SelectionRank for a transaction not in the pool
+const NOTRANKED = -1
+
+//get the 0-based position of the transaction in the block selection order
+//and the number of pooled transactions, rank 0 is included next. the rank is
+//NOTRANKED when the transaction is not pooled.
+func (this *TXNPool) SelectionRank(hash common.Uint256) (rank int, total int) {
+	this.RLock()
+	defer this.RUnlock()
+	rank, ok := this.selectionRank(hash)
+	if !ok {
+		return NOTRANKED, len(this.txnList)
+	}
+	return rank, len(this.txnList)
+}
+
+//get the effective fee rate a new transaction without pooled parents needs to
+//rank ahead of the pooled transaction in the block selection, just above its
+//effective fee rate since an equal one is ranked by hash. 0 is returned when
+//it's not in the pool.
+func (this *TXNPool) FeeRateToOutrank(hash common.Uint256) common.Fixed64 {
+	this.RLock()
+	defer this.RUnlock()
+	entry, ok := this.txnList[hash]
+	if !ok {
+		return 0
+	}
+	return entry.feeRate() + 1
+}
+
+//get the transaction with the lowest effective fee rate still in the next
+//block when the pooled transactions fill it, and that fee rate, the one a
+//transaction has to beat to be included. the block is filled in selection
+//order up to MaxTxInBlock and MaxBlockBytes. nil is returned when the pool
+//under-fills the block.
+func (this *TXNPool) BlockSelectionFloor() (*transaction.Transaction, common.Fixed64) {
+	limits := this.Limits()
+	this.RLock()
+	defer this.RUnlock()
+	var floor *txnEntry
+	count, bytes := 0, 0
+	for _, entry := range this.selectionOrder() {
+		if limits.MaxTxInBlock > 0 && count >= limits.MaxTxInBlock {
+			return floor.txn, floor.feeRate()
+		}
+		if limits.MaxBlockBytes > 0 && bytes+entry.size > limits.MaxBlockBytes {
+			if floor == nil {
+				return nil, 0
+			}
+			return floor.txn, floor.feeRate()
+		}
+		count++
+		bytes += entry.size
+		if floor == nil || entry.feeRate() < floor.feeRate() {
+			floor = entry
+		}
+	}
+	return nil, 0
+}
+
+//0-based position of the transaction in the block selection order,
+//the caller must hold the lock.
+func (this *TXNPool) selectionRank(hash common.Uint256) (int, bool) {
+	if _, ok := this.txnList[hash]; !ok {
+		return 0, false
+	}
+	for i, entry := range this.selectionOrder() {
+		if entry.txn.Hash() == hash {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+//the pooled transactions by fee rate, each one after the pooled transactions
+//it spends from. the caller must hold the lock.
+func (this *TXNPool) selectionOrder() []*txnEntry {
+	order := make([]*txnEntry, 0, len(this.txnList))
+	placed := make(map[common.Uint256]struct{}, len(this.txnList))
+	pending := this.sortedTxnList()
+	//a transaction waiting for its parent is tried again once the parent is placed
+	for len(pending) > 0 {
+		skipped := []*txnEntry{}
+		for _, entry := range pending {
+			if !this.parentsPlaced(entry.txn, placed) {
+				skipped = append(skipped, entry)
+				continue
+			}
+			placed[entry.txn.Hash()] = struct{}{}
+			order = append(order, entry)
+		}
+		//only a dependency cycle leaves nothing to place, which honest hashes can't form
+		if len(skipped) == len(pending) {
+			log.Warn(fmt.Sprintf("%d pooled transactions depend on each other in a cycle", len(skipped)))
+			return append(order, skipped...)
+		}
+		pending = skipped
+	}
+	return order
+}
+
+//the caller must hold the lock
+func (this *TXNPool) parentsPlaced(txn *transaction.Transaction, placed map[common.Uint256]struct{}) bool {
+	for _, input := range txn.UTXOInputs {
+		if _, ok := this.txnList[input.ReferTxID]; !ok {
+			continue
+		}
+		if _, ok := placed[input.ReferTxID]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+//how many full blocks the pooled transactions could fill. the pending bytes
+//are divided by MaxBlockBytes, or the pending count by MaxTxInBlock when no
+//byte budget is set. without any budget the pool fits in one block.
+func (this *TXNPool) BlocksWorthPending() float64 {
+	limits := this.Limits()
+	this.RLock()
+	defer this.RUnlock()
+	switch {
+	case len(this.txnList) == 0:
+		return 0
+	case limits.MaxBlockBytes > 0:
+		return float64(this.txnBytes) / float64(limits.MaxBlockBytes)
+	case limits.MaxTxInBlock > 0:
+		return float64(len(this.txnList)) / float64(limits.MaxTxInBlock)
+	}
+	return 1
+}
+
+//the serialized size of the pooled transactions paying at least the fee rate
+type FeeRatePoint struct {
+	FeeRate         common.Fixed64
+	CumulativeBytes int
+}
+
+//get the cumulative distribution of the pooled bytes by fee rate, one point
+//per distinct fee rate from the highest. a transaction within N bytes of
+//block space needs the lowest fee rate whose CumulativeBytes is below N.
+func (this *TXNPool) FeeRateCDF() []FeeRatePoint {
+	this.RLock()
+	defer this.RUnlock()
+	points := []FeeRatePoint{}
+	cumulative := 0
+	for _, entry := range this.sortedTxnList() {
+		rate := entry.feeRate()
+		cumulative += entry.size
+		if n := len(points); n > 0 && points[n-1].FeeRate == rate {
+			points[n-1].CumulativeBytes = cumulative
+			continue
+		}
+		points = append(points, FeeRatePoint{FeeRate: rate, CumulativeBytes: cumulative})
+	}
+	return points
+}
@@ -0,0 +1,121 @@
+package node
+
+import (
+	"IPT/common"
+	"IPT/core/transaction"
+	"container/heap"
+)
+
+//pooled transaction ranked for eviction by the fee rate of the package made
+//of it and its descendants
+type evictionCandidate struct {
+	txn    *transaction.Transaction
+	fee    common.Fixed64 // fee with the prioritise deltas of the package
+	weight int            // weight of the package
+	rank   int            // position in sortedTxnList, breaking equal package rates
+	index  int            // position in the heap
+}
+
+func (c *evictionCandidate) feeRate() common.Fixed64 {
+	return getFeeRate(c.fee, c.weight)
+}
+
+//min heap of the candidates, the lowest package rate first
+type evictionHeap []*evictionCandidate
+
+func (h evictionHeap) Len() int { return len(h) }
+
+//equal package rates are broken by the lowest fee rate of the transaction
+//itself, i.e. the later in sortedTxnList
+func (h evictionHeap) Less(i, j int) bool {
+	ri, rj := h[i].feeRate(), h[j].feeRate()
+	if ri != rj {
+		return ri < rj
+	}
+	return h[i].rank > h[j].rank
+}
+
+func (h evictionHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *evictionHeap) Push(x interface{}) {
+	c := x.(*evictionCandidate)
+	c.index = len(*h)
+	*h = append(*h, c)
+}
+
+func (h *evictionHeap) Pop() interface{} {
+	old := *h
+	c := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return c
+}
+
+//the eviction order of the pool built once for a series of evictions, the
+//packages of the ancestors are updated as their descendants are evicted.
+type evictionQueue struct {
+	heap       evictionHeap
+	candidates map[common.Uint256]*evictionCandidate
+}
+
+//rank the pooled transactions without the excluded ones, the caller must
+//hold the lock.
+func (this *TXNPool) newEvictionQueue(excluded map[common.Uint256]struct{}) *evictionQueue {
+	entries := this.sortedTxnList()
+	q := &evictionQueue{
+		heap:       make(evictionHeap, 0, len(entries)),
+		candidates: make(map[common.Uint256]*evictionCandidate, len(entries)),
+	}
+	for i, entry := range entries {
+		if _, ok := excluded[entry.txn.Hash()]; ok {
+			continue
+		}
+		c := &evictionCandidate{txn: entry.txn, fee: entry.fee + entry.feeDelta, weight: entry.weight, rank: i}
+		for _, descendant := range this.descendants(entry.txn.Hash()) {
+			if _, ok := excluded[descendant.Hash()]; ok {
+				continue
+			}
+			d := this.txnList[descendant.Hash()]
+			c.fee += d.fee + d.feeDelta
+			c.weight += d.weight
+		}
+		q.candidates[entry.txn.Hash()] = c
+		heap.Push(&q.heap, c)
+	}
+	return q
+}
+
+//the candidate with the lowest package rate and the rate, nil when none is left
+func (q *evictionQueue) peek() (*transaction.Transaction, common.Fixed64) {
+	if len(q.heap) == 0 {
+		return nil, 0
+	}
+	return q.heap[0].txn, q.heap[0].feeRate()
+}
+
+//take the evicted transactions out of the queue and out of the packages of
+//their ancestors, the caller must hold the lock.
+func (this *TXNPool) dropEvicted(q *evictionQueue, evicted []*transaction.Transaction) {
+	for _, txn := range evicted {
+		if c, ok := q.candidates[txn.Hash()]; ok {
+			heap.Remove(&q.heap, c.index)
+			delete(q.candidates, txn.Hash())
+		}
+	}
+	for _, txn := range evicted {
+		entry, ok := this.txnList[txn.Hash()]
+		if !ok {
+			continue
+		}
+		for _, ancestor := range this.ancestors(txn.Hash()) {
+			if c, ok := q.candidates[ancestor.Hash()]; ok {
+				c.fee -= entry.fee + entry.feeDelta
+				c.weight -= entry.weight
+				heap.Fix(&q.heap, c.index)
+			}
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package node
+
+import (
+	"IPT/common/config"
+	. "IPT/common/errors"
+	"IPT/common/log"
+	"IPT/core/ledger"
+	"IPT/core/transaction"
+	"fmt"
+	"time"
+)
+
+//the time or block height the submitter wants the transaction dropped by
+//when it's not mined, the zero fields are not checked.
+type TxnExpiry struct {
+	Time   time.Time // dropped once the time has passed
+	Height uint32    // dropped once the block of the height is committed without it
+}
+
+func txnPoolTTL() time.Duration {
+	return time.Duration(config.Parameters.TxnPoolTTL) * time.Second
+}
+
+//the hints need sweeping without TxnPoolTTL too
+func expirySweepInterval() time.Duration {
+	return SCHEDULERTICK
+}
+
+//add the transaction like AppendTxnPool with the submitter's expiry, it's
+//dropped with its descendants at the earlier of the expiry and TxnPoolTTL.
+func (this *TXNPool) AppendTxnPoolWithExpiry(txn *transaction.Transaction, poolVerify bool, expiry TxnExpiry) ErrCode {
+	errCode := this.appendTxnPool(txn, poolVerify, false, expiry)
+	if errCode != ErrNoError {
+		this.rejections.add(errCode)
+		this.events.rejected(this.clock.Elapsed(), errCode)
+	}
+	return errCode
+}
+
+//reject the expiry which has already passed, the transaction would only be
+//dropped by the next sweep.
+func (this *TXNPool) checkExpiry(txn *transaction.Transaction, expiry TxnExpiry) ErrCode {
+	if !expiry.Time.IsZero() && !this.clock.Now().Before(expiry.Time) {
+		log.Info(fmt.Sprintf("Transaction =%x rejected, expiry time %v has passed", txn.Hash(), expiry.Time))
+		return ErrExpired
+	}
+	if expiry.Height != 0 && ledger.DefaultLedger != nil && expiry.Height <= ledger.DefaultLedger.Blockchain.BlockHeight {
+		log.Info(fmt.Sprintf("Transaction =%x rejected, expiry height %d is committed", txn.Hash(), expiry.Height))
+		return ErrExpired
+	}
+	return ErrNoError
+}
+
+//check weather the entry outlived TxnPoolTTL or its expiry time, the caller
+//must hold the lock.
+func (this *TXNPool) expired(entry *txnEntry, now time.Time) bool {
+	if ttl := txnPoolTTL(); ttl > 0 && this.age(entry.added) >= ttl {
+		return true
+	}
+	return !entry.expiry.Time.IsZero() && !now.Before(entry.expiry.Time)
+}
+
+//remove the pooled transactions past their expiry time or TxnPoolTTL with
+//their descendants.
+func (this *TXNPool) sweepExpired() {
+	this.commitLock.Lock()
+	defer this.commitLock.Unlock()
+	now := this.clock.Now()
+	this.RLock()
+	expired := []*transaction.Transaction{}
+	for _, entry := range this.txnList {
+		if this.expired(entry, now) {
+			expired = append(expired, entry.txn)
+		}
+	}
+	this.RUnlock()
+	this.removeExpired(expired)
+}
+
+//remove the pooled transactions whose expiry height is reached by the
+//committed block with their descendants, the caller must hold the commit lock.
+func (this *TXNPool) expireByHeight(height uint32) {
+	this.RLock()
+	expired := []*transaction.Transaction{}
+	for _, entry := range this.txnList {
+		if entry.expiry.Height != 0 && entry.expiry.Height <= height {
+			expired = append(expired, entry.txn)
+		}
+	}
+	this.RUnlock()
+	this.removeExpired(expired)
+}
+
+//the caller must hold the commit lock
+func (this *TXNPool) removeExpired(expired []*transaction.Transaction) {
+	for _, txn := range expired {
+		if this.GetTransaction(txn.Hash()) == nil {
+			continue
+		}
+		for _, removed := range this.removeWithDescendants(txn) {
+			log.Info(fmt.Sprintf("Transaction =%x expired, removed from the transaction pool", removed.Hash()))
+		}
+	}
+}
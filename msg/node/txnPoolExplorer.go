@@ -0,0 +1,82 @@
+package node
+
+import (
+	"IPT/common"
+	"sort"
+	"time"
+)
+
+const (
+	EXPLORERPENDING  = "pending"  // waiting to be selected for a block
+	EXPLORERRESERVED = "reserved" // reserved by SelectAndReserve for a block being built
+)
+
+//an input of an exported transaction
+type ExplorerInput struct {
+	ReferTxID          common.Uint256
+	ReferTxOutputIndex uint16
+}
+
+//an output of an exported transaction
+type ExplorerOutput struct {
+	Address string // empty when the program hash can't be encoded
+	AssetID common.Uint256
+	Value   common.Fixed64
+}
+
+//a pooled transaction with what a block explorer shows of it
+type ExplorerTransaction struct {
+	Hash             common.Uint256
+	Size             int            // serialized size in bytes
+	Fee              common.Fixed64 // input value not spent by the outputs
+	FeeRate          common.Fixed64 // fee per thousand weight units
+	EffectiveFeeRate common.Fixed64 // fee rate with the prioritise delta, used for the selection
+	Inputs           []ExplorerInput
+	Outputs          []ExplorerOutput
+	Age              time.Duration
+	Ancestors        int    // pooled transactions it spends from, directly or not
+	Descendants      int    // pooled transactions spending from it, directly or not
+	Status           string // EXPLORERPENDING or EXPLORERRESERVED
+}
+
+//get the pooled transactions with what a block explorer shows of them, in the
+//order they were admitted. they are read in one pass with the pool locked so
+//the counts and statuses agree with each other.
+func (this *TXNPool) DumpForExplorer() []ExplorerTransaction {
+	this.RLock()
+	defer this.RUnlock()
+	entries := make([]*txnEntry, 0, len(this.txnList))
+	for _, entry := range this.txnList {
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].seq < entries[j].seq })
+	dump := make([]ExplorerTransaction, 0, len(entries))
+	for _, entry := range entries {
+		hash := entry.txn.Hash()
+		exported := ExplorerTransaction{
+			Hash:             hash,
+			Size:             entry.size,
+			Fee:              entry.fee,
+			FeeRate:          entry.rawFeeRate(),
+			EffectiveFeeRate: entry.feeRate(),
+			Inputs:           make([]ExplorerInput, 0, len(entry.txn.UTXOInputs)),
+			Outputs:          make([]ExplorerOutput, 0, len(entry.txn.Outputs)),
+			Age:              this.age(entry.added),
+			Ancestors:        len(this.ancestors(hash)),
+			Descendants:      len(this.descendants(hash)),
+			Status:           EXPLORERPENDING,
+		}
+		for _, input := range entry.txn.UTXOInputs {
+			exported.Inputs = append(exported.Inputs, ExplorerInput{ReferTxID: input.ReferTxID, ReferTxOutputIndex: input.ReferTxOutputIndex})
+		}
+		for _, output := range entry.txn.Outputs {
+			address, _ := output.ProgramHash.ToAddress()
+			exported.Outputs = append(exported.Outputs, ExplorerOutput{Address: address, AssetID: output.AssetID, Value: output.Value})
+		}
+		if _, ok := this.reserved[hash]; ok {
+			exported.Status = EXPLORERRESERVED
+		}
+		dump = append(dump, exported)
+	}
+	return dump
+}
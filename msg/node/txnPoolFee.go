@@ -0,0 +1,348 @@
+package node
+
+import (
+	"IPT/common"
+	"IPT/common/config"
+	"IPT/common/log"
+	"IPT/core/ledger"
+	"IPT/core/transaction"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+//the pooled transaction with the data used to rank it for block selection
+type txnEntry struct {
+	txn           *transaction.Transaction
+	fee           common.Fixed64 // input value not spent by the outputs
+	size          int            // serialized size in bytes
+	weight        int            // the size used for the fee rate
+	feeDelta      common.Fixed64 // set by PrioritiseTransaction, only used for ranking
+	outputs       common.Uint256 // digest of the outputs
+	added         time.Duration  // clock.Elapsed when the transaction entered the pool
+	seq           uint64         // admission sequence number, set when the entry is pooled
+	packageFee    common.Fixed64 // fee with the prioritise delta of the transaction and its pooled ancestors
+	packageWeight int            // weight of the transaction and its pooled ancestors
+	expiry        TxnExpiry      // set by AppendTxnPoolWithExpiry, zero means none
+}
+
+func (this *TXNPool) newTxnEntry(txn *transaction.Transaction) *txnEntry {
+	return &txnEntry{
+		txn:     txn,
+		fee:     this.getTxnFee(txn),
+		size:    len(txn.ToArray()),
+		weight:  Weight(txn),
+		outputs: outputsDigest(txn),
+		added:   this.clock.Elapsed(),
+	}
+}
+
+//the default transaction weight, the serialized size in bytes
+const DEFAULTTXNWEIGHT = "bytes"
+
+var txnWeights = map[string]func(*transaction.Transaction) int{
+	DEFAULTTXNWEIGHT: func(txn *transaction.Transaction) int { return len(txn.ToArray()) },
+}
+
+//guard txnWeights, the metrics may be registered while the pools weigh
+var txnWeightsLock sync.RWMutex
+
+//register a metric which can be selected by TransactionWeight in config
+func RegisterTxnWeight(name string, weight func(*transaction.Transaction) int) {
+	txnWeightsLock.Lock()
+	defer txnWeightsLock.Unlock()
+	txnWeights[name] = weight
+}
+
+//the size of the transaction in the metric selected by TransactionWeight,
+//the serialized size when not configured.
+func Weight(txn *transaction.Transaction) int {
+	txnWeightsLock.RLock()
+	weight, ok := txnWeights[config.Parameters.TransactionWeight]
+	if !ok {
+		weight = txnWeights[DEFAULTTXNWEIGHT]
+	}
+	txnWeightsLock.RUnlock()
+	return weight(txn)
+}
+
+//compute the fee of a transaction from the outputs its inputs reference,
+//all the fee based ranking and policy of the pool use it.
+type FeeCalculator interface {
+	Fee(txn *transaction.Transaction, reference map[*transaction.UTXOTxInput]*transaction.TxOutput) common.Fixed64
+}
+
+//replace the fee calculator, e.g. to make some assets fee-free
+func WithFeeCalculator(calculator FeeCalculator) TXNPoolOption {
+	return func(pool *TXNPool) {
+		pool.feeCalculator = calculator
+	}
+}
+
+//the fee of the transaction by the fee calculator, the references are
+//resolved from the pool before the ledger.
+func (this *TXNPool) getTxnFee(txn *transaction.Transaction) common.Fixed64 {
+	reference, err := this.getReference(txn)
+	if err != nil {
+		log.Info(fmt.Sprintf("Get reference failed with txn=%x when calc fee.", txn.Hash()))
+		return common.Fixed64(0)
+	}
+	return this.feeCalculator.Fee(txn, reference)
+}
+
+//a fee calculator which also tells the assets the fee is paid in
+type FeeAssetCalculator interface {
+	FeeCalculator
+	FeeByAsset(txn *transaction.Transaction, reference map[*transaction.UTXOTxInput]*transaction.TxOutput) map[common.Uint256]common.Fixed64
+}
+
+//the default fee calculator. the fee is the sum of the positive input/output
+//balance of every asset, which is what the book keeper collects when packing
+//the transaction.
+type valueDifferenceFee struct{}
+
+func (c valueDifferenceFee) Fee(txn *transaction.Transaction, reference map[*transaction.UTXOTxInput]*transaction.TxOutput) common.Fixed64 {
+	var fee common.Fixed64
+	for _, v := range c.FeeByAsset(txn, reference) {
+		fee += v
+	}
+	return fee
+}
+
+func (valueDifferenceFee) FeeByAsset(txn *transaction.Transaction, reference map[*transaction.UTXOTxInput]*transaction.TxOutput) map[common.Uint256]common.Fixed64 {
+	results := txn.GetMergedAssetIDValueFromOutputs()
+	for k, v := range results {
+		results[k] = -v
+	}
+	for _, output := range reference {
+		results[output.AssetID] += output.Value
+	}
+	fees := make(map[common.Uint256]common.Fixed64)
+	for k, v := range results {
+		if v > 0 {
+			fees[k] = v
+		}
+	}
+	return fees
+}
+
+//the pending fees of the pooled transactions summed by the asset they are
+//paid in, recomputed by the fee calculator. the fees of a calculator not
+//implementing FeeAssetCalculator are summed under the zero asset ID.
+func (this *TXNPool) PendingFeesByAsset() map[common.Uint256]common.Fixed64 {
+	//the references are resolved without the lock held
+	txns := this.copytxnList()
+	pending := make(map[common.Uint256]common.Fixed64)
+	calculator, byAsset := this.feeCalculator.(FeeAssetCalculator)
+	for _, txn := range txns {
+		if !byAsset {
+			pending[common.Uint256{}] += this.getTxnFee(txn)
+			continue
+		}
+		reference, err := this.getReference(txn)
+		if err != nil {
+			log.Info(fmt.Sprintf("Get reference failed with txn=%x when calc fee.", txn.Hash()))
+			continue
+		}
+		for assetID, fee := range calculator.FeeByAsset(txn, reference) {
+			pending[assetID] += fee
+		}
+	}
+	return pending
+}
+
+//fee per thousand weight units including the prioritise delta
+func (entry *txnEntry) feeRate() common.Fixed64 {
+	return getFeeRate(entry.fee+entry.feeDelta, entry.weight)
+}
+
+//fee per thousand weight units the transaction actually pays
+func (entry *txnEntry) rawFeeRate() common.Fixed64 {
+	return getFeeRate(entry.fee, entry.weight)
+}
+
+func getFeeRate(fee common.Fixed64, weight int) common.Fixed64 {
+	if weight <= 0 {
+		return fee
+	}
+	return fee * 1000 / common.Fixed64(weight)
+}
+
+//change the fee used to rank the transaction without changing the fee it pays.
+//deltas accumulate and are dropped when the transaction leaves the pool.
+func (this *TXNPool) PrioritiseTransaction(hash common.Uint256, feeDelta common.Fixed64) {
+	this.Lock()
+	defer this.Unlock()
+	entry, ok := this.txnList[hash]
+	if !ok {
+		log.Info(fmt.Sprintf("Transaction =%x not Exist in Pool when prioritise.", hash))
+		return
+	}
+	entry.feeDelta += feeDelta
+	entry.packageFee += feeDelta
+	for _, descendant := range this.descendants(hash) {
+		this.txnList[descendant.Hash()].packageFee += feeDelta
+	}
+}
+
+//get the fee rate the pooled transaction is ranked by in the selection and
+//eviction, with the prioritise delta applied. 0 is returned when it's not in
+//the pool.
+func (this *TXNPool) EffectiveFeeRate(hash common.Uint256) common.Fixed64 {
+	this.RLock()
+	defer this.RUnlock()
+	entry, ok := this.txnList[hash]
+	if !ok {
+		return 0
+	}
+	return entry.feeRate()
+}
+
+//get the fee rate the pooled transaction actually pays, without the
+//prioritise delta. 0 is returned when it's not in the pool.
+func (this *TXNPool) RawFeeRate(hash common.Uint256) common.Fixed64 {
+	this.RLock()
+	defer this.RUnlock()
+	entry, ok := this.txnList[hash]
+	if !ok {
+		return 0
+	}
+	return entry.rawFeeRate()
+}
+
+//pooled transactions ordered by fee rate, the caller must hold the lock.
+func (this *TXNPool) sortedTxnList() []*txnEntry {
+	entries := make([]*txnEntry, 0, len(this.txnList))
+	keys := make(map[*txnEntry]common.Uint256, len(this.txnList))
+	height := selectionHeight()
+	for _, entry := range this.txnList {
+		entries = append(entries, entry)
+		keys[entry] = this.tiebreakKey(entry.txn.Hash(), height)
+	}
+	sort.Sort(byFeeRate{entries: entries, keys: keys})
+	return entries
+}
+
+//the height of the block the selection is for, the tiebreak keys are fixed
+//for a height so all the selections of a round agree.
+func selectionHeight() uint32 {
+	if ledger.DefaultLedger == nil {
+		return 0
+	}
+	return ledger.DefaultLedger.Blockchain.BlockHeight + 1
+}
+
+//derive the key ordering the transactions with equal fee rate from the
+//transaction hash and the height of the block being selected for.
+type TiebreakKey func(hash common.Uint256, height uint32) common.Uint256
+
+//order by the transaction hash
+func RawHashTiebreak(hash common.Uint256, height uint32) common.Uint256 {
+	return hash
+}
+
+//order by the hash of seed and transaction hash, the same at every height.
+func SeededTiebreak(seed int64) TiebreakKey {
+	prefix := make([]byte, 8)
+	binary.LittleEndian.PutUint64(prefix, uint64(seed))
+	return func(hash common.Uint256, height uint32) common.Uint256 {
+		data := make([]byte, 0, len(prefix)+len(hash))
+		data = append(data, prefix...)
+		data = append(data, hash[:]...)
+		return common.Uint256(sha256.Sum256(data))
+	}
+}
+
+//order by the transaction hash XOR a per block seed derived from seed and
+//height, so a different transaction of equal fee rate leads at each height.
+func RotatingTiebreak(seed int64) TiebreakKey {
+	return func(hash common.Uint256, height uint32) common.Uint256 {
+		data := make([]byte, 12)
+		binary.LittleEndian.PutUint64(data, uint64(seed))
+		binary.LittleEndian.PutUint32(data[8:], height)
+		blockSeed := sha256.Sum256(data)
+		for i := range hash {
+			hash[i] ^= blockSeed[i]
+		}
+		return hash
+	}
+}
+
+//highest fee rate first, equal fee rates ordered by the tiebreak key
+type byFeeRate struct {
+	entries []*txnEntry
+	keys    map[*txnEntry]common.Uint256
+}
+
+func (a byFeeRate) Len() int      { return len(a.entries) }
+func (a byFeeRate) Swap(i, j int) { a.entries[i], a.entries[j] = a.entries[j], a.entries[i] }
+func (a byFeeRate) Less(i, j int) bool {
+	ri, rj := a.entries[i].feeRate(), a.entries[j].feeRate()
+	if ri != rj {
+		return ri > rj
+	}
+	ki := a.keys[a.entries[i]]
+	return ki.CompareTo(a.keys[a.entries[j]]) < 0
+}
+
+//get the transaction in txnpool grouped into fee bands for tiered block
+//building. bands are the lower bounds of the fee rates, the transactions go to
+//the band with the highest bound not above their rate and the ones below all
+//the bounds are left out. the result is indexed like bands, each band holds
+//at most perBandCount transactions with the highest fee rate, 0 means no cap.
+func (this *TXNPool) SelectByFeeBands(bands []common.Fixed64, perBandCount int) [][]*transaction.Transaction {
+	this.RLock()
+	defer this.RUnlock()
+	selected := make([][]*transaction.Transaction, len(bands))
+	for _, entry := range this.sortedTxnList() {
+		rate := entry.feeRate()
+		band := -1
+		for i, bound := range bands {
+			if bound <= rate && (band < 0 || bound > bands[band]) {
+				band = i
+			}
+		}
+		if band < 0 || (perBandCount > 0 && len(selected[band]) >= perBandCount) {
+			continue
+		}
+		selected[band] = append(selected[band], entry.txn)
+	}
+	return selected
+}
+
+//select the block transactions in selection order up to maxBytes serialized
+//size, leaving the last reserveBytes to the transactions paying at least the
+//floor fee rate, e.g. for the ones arriving late in the round. the reserve is
+//left empty when the pool has none of them. a transaction is only selected
+//after the pooled transactions it spends from. maxBytes 0 means no limit.
+func (this *TXNPool) SelectForBlockWithReserve(maxBytes, reserveBytes int, floor common.Fixed64) []*transaction.Transaction {
+	this.RLock()
+	defer this.RUnlock()
+	unreserved := maxBytes - reserveBytes
+	if reserveBytes <= 0 || unreserved < 0 {
+		unreserved = maxBytes
+	}
+	txns := []*transaction.Transaction{}
+	selected := make(map[common.Uint256]struct{})
+	//the bytes of all the selected transactions and of the ones below the floor
+	bytes, belowFloor := 0, 0
+	for _, entry := range this.selectionOrder() {
+		below := entry.feeRate() < floor
+		if maxBytes > 0 && (bytes+entry.size > maxBytes || (below && belowFloor+entry.size > unreserved)) {
+			continue
+		}
+		if !this.parentsPlaced(entry.txn, selected) {
+			continue
+		}
+		selected[entry.txn.Hash()] = struct{}{}
+		txns = append(txns, entry.txn)
+		bytes += entry.size
+		if below {
+			belowFloor += entry.size
+		}
+	}
+	return txns
+}
@@ -0,0 +1,217 @@
+package node
+
+import (
+	"IPT/common"
+	"IPT/common/log"
+	"IPT/core/transaction"
+	"fmt"
+)
+
+//count the pooled transactions spending the outputs of the transaction,
+//directly or through other pooled transactions.
+func (this *TXNPool) DescendantCount(hash common.Uint256) int {
+	this.RLock()
+	defer this.RUnlock()
+	return len(this.descendants(hash))
+}
+
+//get the pooled transactions which would have to be removed along with the
+//transaction, each one listed before the transactions it spends from. the pool
+//is not changed.
+func (this *TXNPool) RemovalImpact(hash common.Uint256) []*transaction.Transaction {
+	this.RLock()
+	defer this.RUnlock()
+	return this.descendants(hash)
+}
+
+//get the pooled transaction spending the outpoint with its descendants, which
+//must be removed once the outpoint is known to be spent elsewhere, e.g. by a
+//block or a peer. descendants are listed before the transactions they spend
+//from. the pool is not changed.
+func (this *TXNPool) InvalidatedBySpend(outpoint *transaction.UTXOTxInput) []*transaction.Transaction {
+	this.RLock()
+	defer this.RUnlock()
+	spender, ok := this.inputUTXOList[*outpoint]
+	if !ok {
+		return nil
+	}
+	return append(this.descendants(spender.Hash()), spender)
+}
+
+//a pooled transaction spending an output of another pooled transaction
+type DependencyEdge struct {
+	Parent common.Uint256
+	Child  common.Uint256
+}
+
+//the pooled transactions and the spends between them
+type DependencyGraph struct {
+	Nodes []common.Uint256
+	Edges []DependencyEdge
+}
+
+//get the dependency graph of the pooled transactions used by the package
+//fee rates and the selection order, e.g. to draw it in a tool. the nodes are
+//listed by fee rate from the highest and the edges by child in the same
+//order, one edge per parent however many of its outputs the child spends.
+func (this *TXNPool) DependencyGraph() DependencyGraph {
+	this.RLock()
+	defer this.RUnlock()
+	graph := DependencyGraph{
+		Nodes: make([]common.Uint256, 0, len(this.txnList)),
+		Edges: []DependencyEdge{},
+	}
+	for _, entry := range this.sortedTxnList() {
+		hash := entry.txn.Hash()
+		graph.Nodes = append(graph.Nodes, hash)
+		for _, parent := range this.parents(hash) {
+			graph.Edges = append(graph.Edges, DependencyEdge{Parent: parent.Hash(), Child: hash})
+		}
+	}
+	return graph
+}
+
+//pooled transactions spending an output of the transaction, the caller must hold the lock.
+func (this *TXNPool) children(hash common.Uint256) []*transaction.Transaction {
+	entry, ok := this.txnList[hash]
+	if !ok {
+		return nil
+	}
+	children := []*transaction.Transaction{}
+	seen := make(map[common.Uint256]struct{})
+	for i := range entry.txn.Outputs {
+		input := &transaction.UTXOTxInput{ReferTxID: hash, ReferTxOutputIndex: uint16(i)}
+		child, ok := this.inputUTXOList[*input]
+		if !ok {
+			continue
+		}
+		if _, ok := seen[child.Hash()]; ok {
+			continue
+		}
+		seen[child.Hash()] = struct{}{}
+		children = append(children, child)
+	}
+	return children
+}
+
+//all pooled transactions depending on the transaction, each one listed before
+//the transactions it spends from. the caller must hold the lock.
+func (this *TXNPool) descendants(hash common.Uint256) []*transaction.Transaction {
+	result := []*transaction.Transaction{}
+	visited := make(map[common.Uint256]struct{})
+	var visit func(hash common.Uint256)
+	visit = func(hash common.Uint256) {
+		for _, child := range this.children(hash) {
+			childHash := child.Hash()
+			if _, ok := visited[childHash]; ok {
+				continue
+			}
+			visited[childHash] = struct{}{}
+			visit(childHash)
+			result = append(result, child)
+		}
+	}
+	visit(hash)
+	return result
+}
+
+//get the pooled transactions the transaction depends on, the parents first
+//then the grandparents and so on, e.g. to show a wallet the low fee ancestor
+//its transaction waits for.
+func (this *TXNPool) AncestorChain(hash common.Uint256) []*transaction.Transaction {
+	this.RLock()
+	defer this.RUnlock()
+	return this.ancestors(hash)
+}
+
+//all pooled transactions the transaction depends on by generation, the
+//caller must hold the lock.
+func (this *TXNPool) ancestors(hash common.Uint256) []*transaction.Transaction {
+	chain := []*transaction.Transaction{}
+	visited := map[common.Uint256]struct{}{hash: {}}
+	generation := []common.Uint256{hash}
+	for len(generation) > 0 {
+		next := []common.Uint256{}
+		for _, h := range generation {
+			for _, parent := range this.parents(h) {
+				parentHash := parent.Hash()
+				if _, ok := visited[parentHash]; ok {
+					continue
+				}
+				visited[parentHash] = struct{}{}
+				chain = append(chain, parent)
+				next = append(next, parentHash)
+			}
+		}
+		generation = next
+	}
+	return chain
+}
+
+//pooled transactions the transaction spends an output of, the caller must hold the lock.
+func (this *TXNPool) parents(hash common.Uint256) []*transaction.Transaction {
+	entry, ok := this.txnList[hash]
+	if !ok {
+		return nil
+	}
+	parents := []*transaction.Transaction{}
+	seen := make(map[common.Uint256]struct{})
+	for _, input := range entry.txn.UTXOInputs {
+		parent, ok := this.txnList[input.ReferTxID]
+		if !ok {
+			continue
+		}
+		if _, ok := seen[input.ReferTxID]; ok {
+			continue
+		}
+		seen[input.ReferTxID] = struct{}{}
+		parents = append(parents, parent.txn)
+	}
+	return parents
+}
+
+//remove the transaction and the pooled transactions spending its outputs. the
+//descendants are removed first so their references still resolve.
+func (this *TXNPool) removeWithDescendants(txn *transaction.Transaction) []*transaction.Transaction {
+	this.RLock()
+	txns := append(this.descendants(txn.Hash()), txn)
+	this.RUnlock()
+	for _, t := range txns {
+		this.removeTransaction(t)
+	}
+	return txns
+}
+
+//remove the pooled transactions with the hashes, e.g. by an admin or for a
+//reorg, serialized against the admissions so the pool maps stay consistent.
+//the transactions spending their outputs are removed too, since they can't be
+//valid without them. the hashes not pooled are skipped. the number of the
+//transactions removed, including the descendants, is returned.
+func (this *TXNPool) RemoveTransactions(hashes []common.Uint256) (removed int) {
+	this.commitLock.Lock()
+	defer this.commitLock.Unlock()
+	for _, hash := range hashes {
+		txn := this.GetTransaction(hash)
+		if txn == nil {
+			continue
+		}
+		for _, r := range this.removeWithDescendants(txn) {
+			log.Info(fmt.Sprintf("Transaction =%x removed from the transaction pool", r.Hash()))
+			removed++
+		}
+	}
+	return removed
+}
+
+//get the pooled transaction creating the output, nil when the output is not
+//created by a pooled transaction. the pool is indexed by transaction hash so
+//the outputs need no index of their own.
+func (this *TXNPool) ProducerOf(outpoint *transaction.UTXOTxInput) *transaction.Transaction {
+	this.RLock()
+	defer this.RUnlock()
+	entry, ok := this.txnList[outpoint.ReferTxID]
+	if !ok || int(outpoint.ReferTxOutputIndex) >= len(entry.txn.Outputs) {
+		return nil
+	}
+	return entry.txn
+}
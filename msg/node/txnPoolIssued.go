@@ -0,0 +1,120 @@
+package node
+
+import (
+	"IPT/common"
+	"IPT/core/transaction"
+	"sync"
+	"time"
+)
+
+//how long the quantity issued of an asset is cached, a block issuing the
+//asset invalidates it earlier
+const QUANTITYISSUEDCACHETIME = 5 * time.Second
+
+type cachedQuantity struct {
+	quantity common.Fixed64
+	expires  time.Duration // clock.Elapsed when the value expires
+}
+
+//quantity issued of the assets by the committed blocks
+type quantityIssuedCache struct {
+	sync.Mutex
+	quantities map[common.Uint256]cachedQuantity
+}
+
+func (c *quantityIssuedCache) init() {
+	c.Lock()
+	defer c.Unlock()
+	c.quantities = make(map[common.Uint256]cachedQuantity)
+}
+
+func (c *quantityIssuedCache) get(assetID common.Uint256, now time.Duration) (common.Fixed64, bool) {
+	c.Lock()
+	defer c.Unlock()
+	cached, ok := c.quantities[assetID]
+	if !ok || now > cached.expires {
+		delete(c.quantities, assetID)
+		return 0, false
+	}
+	return cached.quantity, true
+}
+
+func (c *quantityIssuedCache) set(assetID common.Uint256, quantity common.Fixed64, expires time.Duration) {
+	c.Lock()
+	defer c.Unlock()
+	c.quantities[assetID] = cachedQuantity{quantity: quantity, expires: expires}
+}
+
+func (c *quantityIssuedCache) invalidate(assetID common.Uint256) {
+	c.Lock()
+	defer c.Unlock()
+	delete(c.quantities, assetID)
+}
+
+//get the quantity issued of the asset from the ledger, cached so a burst of
+//issuance doesn't query the ledger for each transaction.
+func (this *TXNPool) quantityIssued(assetID common.Uint256) (common.Fixed64, error) {
+	now := this.clock.Elapsed()
+	if quantity, ok := this.issuedCache.get(assetID, now); ok {
+		return quantity, nil
+	}
+	quantity, err := transaction.TxStore.GetQuantityIssued(assetID)
+	if err != nil {
+		return 0, err
+	}
+	this.issuedCache.set(assetID, quantity, now+QUANTITYISSUEDCACHETIME)
+	return quantity, nil
+}
+
+//drop the cached quantity issued of the assets issued by the committed transaction
+func (this *TXNPool) invalidateQuantityIssued(txn *transaction.Transaction) {
+	if txn.TxType != transaction.IssueAsset {
+		return
+	}
+	for assetID := range issuedAmounts(txn) {
+		this.issuedCache.invalidate(assetID)
+	}
+}
+
+type issuance struct {
+	at     time.Duration // clock.Elapsed when the issuance was admitted
+	amount common.Fixed64
+}
+
+//the amounts admitted for issuance within IssuanceRateWindow by asset
+type issuanceWindow struct {
+	sync.Mutex
+	admitted map[common.Uint256][]issuance
+}
+
+func (w *issuanceWindow) init() {
+	w.Lock()
+	defer w.Unlock()
+	w.admitted = make(map[common.Uint256][]issuance)
+}
+
+//the amount of the asset admitted after the time
+func (w *issuanceWindow) amount(assetID common.Uint256, after time.Duration) common.Fixed64 {
+	w.Lock()
+	defer w.Unlock()
+	var total common.Fixed64
+	for _, i := range w.admitted[assetID] {
+		if i.at > after {
+			total += i.amount
+		}
+	}
+	return total
+}
+
+//record the amount admitted at the time, dropping the issuance before the window
+func (w *issuanceWindow) record(assetID common.Uint256, amount common.Fixed64, at time.Duration, windowStart time.Duration) {
+	w.Lock()
+	defer w.Unlock()
+	recent := []issuance{}
+	for _, i := range w.admitted[assetID] {
+		if i.at > windowStart {
+			recent = append(recent, i)
+		}
+	}
+	w.admitted[assetID] = append(recent, issuance{at: at, amount: amount})
+}
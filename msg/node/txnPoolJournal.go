@@ -0,0 +1,178 @@
+package node
+
+import (
+	"IPT/common"
+	. "IPT/common/errors"
+	"IPT/common/log"
+	"IPT/common/serialization"
+	"IPT/core/transaction"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+const (
+	JOURNALADD    = 0x01 // followed by the serialized transaction added to the pool
+	JOURNALREMOVE = 0x02 // followed by the hash of the transaction removed from the pool
+	JOURNALEXPIRY = 0x03 // followed by the submitter's expiry and the serialized transaction added with it
+)
+
+//record the transactions added to and removed from the pool to the writer
+//in the order they happen, so the pool can be rebuilt by ReplayJournal e.g.
+//to debug an incident in a test environment. the records are queued with
+//the pool locked and written after it is unlocked.
+func WithJournal(w io.Writer) TXNPoolOption {
+	return func(pool *TXNPool) {
+		pool.journal.writer = w
+	}
+}
+
+//record of the journal waiting to be written
+type journalRecord struct {
+	op     uint8
+	txn    *transaction.Transaction // the added transaction of JOURNALADD and JOURNALEXPIRY
+	hash   common.Uint256
+	expiry TxnExpiry
+}
+
+//the records are queued in the order of the changes of txnList and written
+//in that order by whichever flush takes them first.
+type txnJournal struct {
+	writer  io.Writer
+	writeMu sync.Mutex // serializes the writes to writer
+	queueMu sync.Mutex
+	queue   []journalRecord
+}
+
+//the caller must hold the lock
+func (this *TXNPool) journalAdd(txn *transaction.Transaction, expiry TxnExpiry) {
+	op := uint8(JOURNALADD)
+	if expiry != (TxnExpiry{}) {
+		op = JOURNALEXPIRY
+	}
+	this.journal.push(journalRecord{op: op, txn: txn, hash: txn.Hash(), expiry: expiry})
+}
+
+//the caller must hold the lock
+func (this *TXNPool) journalRemove(hash common.Uint256) {
+	this.journal.push(journalRecord{op: JOURNALREMOVE, hash: hash})
+}
+
+func (j *txnJournal) push(record journalRecord) {
+	if j.writer == nil {
+		return
+	}
+	j.queueMu.Lock()
+	j.queue = append(j.queue, record)
+	j.queueMu.Unlock()
+}
+
+//write the queued records, the caller must not hold the lock of the pool.
+func (this *TXNPool) flushJournal() {
+	j := &this.journal
+	if j.writer == nil {
+		return
+	}
+	j.writeMu.Lock()
+	defer j.writeMu.Unlock()
+	j.queueMu.Lock()
+	records := j.queue
+	j.queue = nil
+	j.queueMu.Unlock()
+	for _, record := range records {
+		if err := record.write(j.writer); err != nil {
+			log.Info(fmt.Sprintf("Journal record %d of transaction =%x not written, %v", record.op, record.hash, err))
+		}
+	}
+}
+
+func (record journalRecord) write(w io.Writer) error {
+	if err := serialization.WriteUint8(w, record.op); err != nil {
+		return err
+	}
+	switch record.op {
+	case JOURNALEXPIRY:
+		if err := writeExpiry(w, record.expiry); err != nil {
+			return err
+		}
+		return record.txn.Serialize(w)
+	case JOURNALADD:
+		return record.txn.Serialize(w)
+	}
+	_, err := record.hash.Serialize(w)
+	return err
+}
+
+//the time in unix nanoseconds, 0 for none, and the height
+func writeExpiry(w io.Writer, expiry TxnExpiry) error {
+	var nanos uint64
+	if !expiry.Time.IsZero() {
+		nanos = uint64(expiry.Time.UnixNano())
+	}
+	if err := serialization.WriteUint64(w, nanos); err != nil {
+		return err
+	}
+	return serialization.WriteUint32(w, expiry.Height)
+}
+
+func readExpiry(r io.Reader) (TxnExpiry, error) {
+	var expiry TxnExpiry
+	nanos, err := serialization.ReadUint64(r)
+	if err != nil {
+		return expiry, err
+	}
+	if nanos != 0 {
+		expiry.Time = time.Unix(0, int64(nanos))
+	}
+	expiry.Height, err = serialization.ReadUint32(r)
+	return expiry, err
+}
+
+//apply the additions and removals recorded by WithJournal to the pool in
+//their order. the added transactions are verified as any other admission,
+//the ones not passing against the current ledger are skipped. an error is
+//only returned when the journal can't be read.
+func (this *TXNPool) ReplayJournal(r io.Reader) error {
+	for {
+		var op [1]byte
+		if _, err := io.ReadFull(r, op[:]); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return NewDetailErr(err, ErrNoCode, "[TXNPool], ReplayJournal failed.")
+		}
+		switch op[0] {
+		case JOURNALADD, JOURNALEXPIRY:
+			var expiry TxnExpiry
+			if op[0] == JOURNALEXPIRY {
+				var err error
+				if expiry, err = readExpiry(r); err != nil {
+					return NewDetailErr(err, ErrNoCode, "[TXNPool], ReplayJournal failed.")
+				}
+			}
+			txn := new(transaction.Transaction)
+			if err := txn.Deserialize(r); err != nil {
+				return NewDetailErr(err, ErrNoCode, "[TXNPool], ReplayJournal failed.")
+			}
+			if errCode := this.AppendTxnPoolWithExpiry(txn, true, expiry); errCode != ErrNoError {
+				log.Info(fmt.Sprintf("Journaled transaction =%x skipped, %v", txn.Hash(), errCode))
+			}
+		case JOURNALREMOVE:
+			var hash common.Uint256
+			if err := hash.Deserialize(r); err != nil {
+				return NewDetailErr(err, ErrNoCode, "[TXNPool], ReplayJournal failed.")
+			}
+			txn := this.GetTransaction(hash)
+			if txn == nil {
+				continue
+			}
+			this.commitLock.Lock()
+			this.removeWithDescendants(txn)
+			this.commitLock.Unlock()
+		default:
+			return errors.New(fmt.Sprintf("unknown journal record %d", op[0]))
+		}
+	}
+}
@@ -0,0 +1,273 @@
+package node
+
+import (
+	"IPT/common"
+	"IPT/common/config"
+	. "IPT/common/errors"
+	"IPT/common/log"
+	"IPT/core/transaction"
+	"fmt"
+	"time"
+)
+
+//the limits the pool is operating under, 0 means no limit
+type PoolLimits struct {
+	MaxPoolSize                int            // max number of pooled transactions
+	MaxPoolBytes               int            // max serialized size of the pooled transactions, lowered by MaxPoolBlocks
+	MaxPoolBlocks              int            // max serialized size of the pooled transactions in blocks of MaxBlockBytes
+	MinTxFee                   common.Fixed64 // min fee per thousand weight units of the transactions spending inputs
+	MinRelayFee                common.Fixed64 // min fee per thousand weight units of the relayed transactions
+	MaxTxInBlock               int            // max number of transactions selected for a block
+	MaxBlockBytes              int            // serialized size of the transactions a block holds
+	MaxOrphanTransactions      int            // max number of orphan transactions, 0 means orphans are rejected
+	MaxPendingIssuancePerAsset common.Fixed64 // max amount of one asset pending issuance
+	IssuanceRateLimit          common.Fixed64 // max amount of one asset admitted for issuance within IssuanceRateWindow
+	IssuanceRateWindow         time.Duration  // sliding window of IssuanceRateLimit
+	VerifyTimeout              time.Duration  // max time to verify one transaction
+}
+
+//get the limits resolved from the config
+func (this *TXNPool) Limits() PoolLimits {
+	return PoolLimits{
+		MaxPoolSize:                nonNegative(config.Parameters.MaxPoolSize),
+		MaxPoolBytes:               maxPoolBytes(),
+		MaxPoolBlocks:              nonNegative(config.Parameters.MaxPoolBlocks),
+		MinTxFee:                   configFixed64(config.Parameters.MinTxFee),
+		MinRelayFee:                configFixed64(config.Parameters.MinRelayFee),
+		MaxTxInBlock:               nonNegative(config.Parameters.MaxTxInBlock),
+		MaxBlockBytes:              nonNegative(config.Parameters.MaxBlockBytes),
+		MaxOrphanTransactions:      nonNegative(config.Parameters.MaxOrphanTransactions),
+		MaxPendingIssuancePerAsset: configFixed64(config.Parameters.MaxPendingIssuancePerAsset),
+		IssuanceRateLimit:          configFixed64(config.Parameters.IssuanceRateLimit),
+		IssuanceRateWindow:         time.Duration(config.Parameters.IssuanceRateWindow) * time.Second,
+		VerifyTimeout:              time.Duration(config.Parameters.VerifyTimeout) * time.Millisecond,
+	}
+}
+
+//apply the limits after config.Parameters is reloaded. the limits are read
+//from the config whenever they are used, so only a reduced capacity needs to
+//be enforced: the pool is pruned by fee rate and the orphan buffer is
+//shrunk to fit. the maintenance window is parsed again. the limits applied
+//are returned.
+func (this *TXNPool) ReloadLimits() PoolLimits {
+	limits := this.Limits()
+	this.commitLock.Lock()
+	evicted := this.trimToLimits(limits)
+	this.commitLock.Unlock()
+	if len(evicted) > 0 {
+		log.Info(fmt.Sprintf("%d transactions evicted by the reloaded limits", len(evicted)))
+	}
+	this.Lock()
+	defer this.Unlock()
+	this.maintenance = loadMaintenanceWindow()
+	for len(this.orphanList) > limits.MaxOrphanTransactions {
+		for hash, entry := range this.orphanList {
+			log.Info(fmt.Sprintf("Orphan transaction =%x dropped by the reloaded limits", hash))
+			this.delOrphan(entry)
+			break
+		}
+	}
+	return limits
+}
+
+//the byte budget of the pool, MaxPoolBytes or MaxPoolBlocks blocks of
+//MaxBlockBytes whichever is smaller. the block count is ignored without a
+//block byte capacity.
+func maxPoolBytes() int {
+	limit := nonNegative(config.Parameters.MaxPoolBytes)
+	blocks := nonNegative(config.Parameters.MaxPoolBlocks)
+	blockBytes := nonNegative(config.Parameters.MaxBlockBytes)
+	if blocks == 0 || blockBytes == 0 {
+		return limit
+	}
+	if derived := blocks * blockBytes; limit == 0 || derived < limit {
+		return derived
+	}
+	return limit
+}
+
+func nonNegative(limit int) int {
+	if limit < 0 {
+		return 0
+	}
+	return limit
+}
+
+//check the fee is not negative before it is used by the selection and the
+//eviction. the outputs exceeding the inputs should fail the verification with
+//the ledger, a custom FeeCalculator may still give a negative fee.
+func (this *TXNPool) checkNegativeFee(txn *transaction.Transaction) ErrCode {
+	if fee := this.getTxnFee(txn); fee < 0 {
+		log.Info(fmt.Sprintf("Transaction =%x rejected, negative fee %v", txn.Hash(), fee))
+		return ErrNegativeFee
+	}
+	return ErrNoError
+}
+
+//check the transaction pays the min fee rate. the transactions without
+//inputs, e.g. IssueAsset, pay no fee and are not checked.
+func (this *TXNPool) checkMinFee(txn *transaction.Transaction, limits PoolLimits) ErrCode {
+	minFee := this.currentMinFeeRate(limits)
+	if minFee <= 0 || len(txn.UTXOInputs) == 0 {
+		return ErrNoError
+	}
+	entry := this.newTxnEntry(txn)
+	if rate := entry.feeRate(); rate < minFee {
+		log.Info(fmt.Sprintf("Transaction =%x fee rate %v lower than the min fee %v", txn.Hash(), rate, minFee))
+		return ErrInsufficientFee
+	}
+	return ErrNoError
+}
+
+//check weather the transaction pays the min relay fee rate, a pooled
+//transaction below it is kept locally but not relayed to the peers. the
+//prioritise delta is not counted, nor are the transactions without inputs.
+func (this *TXNPool) ShouldRelay(txn *transaction.Transaction) bool {
+	minRelayFee := this.Limits().MinRelayFee
+	if minRelayFee <= 0 || len(txn.UTXOInputs) == 0 {
+		return true
+	}
+	this.RLock()
+	entry, ok := this.txnList[txn.Hash()]
+	this.RUnlock()
+	if !ok {
+		entry = this.newTxnEntry(txn)
+	}
+	return entry.rawFeeRate() >= minRelayFee
+}
+
+//check weather the pool exceeds the size limits, the caller must hold the lock.
+func (this *TXNPool) overLimits(limits PoolLimits) bool {
+	if limits.MaxPoolSize > 0 && len(this.txnList) > limits.MaxPoolSize {
+		return true
+	}
+	return limits.MaxPoolBytes > 0 && this.txnBytes > limits.MaxPoolBytes
+}
+
+//evict the transactions with the lowest package fee rate, together with the
+//pooled transactions spending their outputs, until the pool fits the size limits.
+func (this *TXNPool) trimToLimits(limits PoolLimits) map[common.Uint256]struct{} {
+	evicted := make(map[common.Uint256]struct{})
+	var queue *evictionQueue
+	for {
+		this.RLock()
+		if !this.overLimits(limits) {
+			this.RUnlock()
+			return evicted
+		}
+		if queue == nil {
+			queue = this.newEvictionQueue(nil)
+		}
+		victim, rate := queue.peek()
+		if victim == nil {
+			this.RUnlock()
+			return evicted
+		}
+		this.dropEvicted(queue, append(this.descendants(victim.Hash()), victim))
+		this.RUnlock()
+		removed := this.removeWithDescendants(victim)
+		for _, txn := range removed {
+			log.Info(fmt.Sprintf("Transaction =%x evicted, transaction pool is full", txn.Hash()))
+			evicted[txn.Hash()] = struct{}{}
+		}
+		this.events.evicted(this.clock.Elapsed(), len(removed))
+		this.raiseMinFee(rate)
+	}
+}
+
+//evict the pooled transactions with the lowest package fee rate until the
+//transaction fits the size limits, used before admitting a high priority
+//transaction e.g. of governance. nothing is evicted when the transaction
+//doesn't fit even the empty pool.
+func (this *TXNPool) EnsureRoomFor(txn *transaction.Transaction) error {
+	limits := this.Limits()
+	size := len(txn.ToArray())
+	if limits.MaxPoolBytes > 0 && size > limits.MaxPoolBytes {
+		log.Info(fmt.Sprintf("Transaction =%x of %d bytes doesn't fit the transaction pool", txn.Hash(), size))
+		return ErrPoolFull
+	}
+	this.commitLock.Lock()
+	defer this.commitLock.Unlock()
+	var queue *evictionQueue
+	for {
+		this.RLock()
+		if _, ok := this.txnList[txn.Hash()]; ok {
+			this.RUnlock()
+			return nil
+		}
+		full := limits.MaxPoolSize > 0 && len(this.txnList)+1 > limits.MaxPoolSize
+		if limits.MaxPoolBytes > 0 && this.txnBytes+size > limits.MaxPoolBytes {
+			full = true
+		}
+		if !full {
+			this.RUnlock()
+			return nil
+		}
+		if queue == nil {
+			queue = this.newEvictionQueue(nil)
+		}
+		victim, rate := queue.peek()
+		if victim == nil {
+			this.RUnlock()
+			return nil
+		}
+		this.dropEvicted(queue, append(this.descendants(victim.Hash()), victim))
+		this.RUnlock()
+		removed := this.removeWithDescendants(victim)
+		for _, evicted := range removed {
+			log.Info(fmt.Sprintf("Transaction =%x evicted to make room for transaction =%x", evicted.Hash(), txn.Hash()))
+		}
+		this.events.evicted(this.clock.Elapsed(), len(removed))
+		this.raiseMinFee(rate)
+	}
+}
+
+//the pooled transaction with the lowest fee rate of the package made of it
+//and its descendants, which are evicted with it, and that package fee rate.
+//a low fee parent of high fee children is kept. equal package rates are
+//broken by the lowest fee rate of the transaction itself. the excluded
+//transactions are left out as if evicted. the caller must hold the lock.
+func (this *TXNPool) evictionVictimExcept(excluded map[common.Uint256]struct{}) (*transaction.Transaction, common.Fixed64) {
+	return this.newEvictionQueue(excluded).peek()
+}
+
+//the names of the transaction types used in config
+var txnTypeNames = map[string]transaction.TransactionType{
+	"BookKeeping":    transaction.BookKeeping,
+	"IssueAsset":     transaction.IssueAsset,
+	"BookKeeper":     transaction.BookKeeper,
+	"LockAsset":      transaction.LockAsset,
+	"PrivacyPayload": transaction.PrivacyPayload,
+	"RegisterAsset":  transaction.RegisterAsset,
+	"TransferAsset":  transaction.TransferAsset,
+	"Record":         transaction.Record,
+	"DeployCode":     transaction.DeployCode,
+	"InvokeCode":     transaction.InvokeCode,
+	"DataFile":       transaction.DataFile,
+}
+
+//the max number of pooled transactions of the type, 0 means no limit
+func typeLimit(txType transaction.TransactionType) int {
+	for name, limit := range config.Parameters.MaxPoolSizeByType {
+		if t, ok := txnTypeNames[name]; ok && t == txType {
+			return nonNegative(limit)
+		}
+	}
+	return 0
+}
+
+//check the pool has room for another transaction of the type, so one type
+//can't crowd out the others even if the pool has room.
+func (this *TXNPool) checkTypeLimit(txn *transaction.Transaction) ErrCode {
+	limit := typeLimit(txn.TxType)
+	if limit == 0 {
+		return ErrNoError
+	}
+	this.RLock()
+	defer this.RUnlock()
+	if this.typeCounts[txn.TxType] >= limit {
+		log.Info(fmt.Sprintf("Transaction =%x rejected, %d transactions of type %x pooled", txn.Hash(), limit, byte(txn.TxType)))
+		return ErrTypeLimitReached
+	}
+	return ErrNoError
+}
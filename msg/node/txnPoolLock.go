@@ -0,0 +1,136 @@
+package node
+
+import (
+	"IPT/common"
+	"IPT/common/log"
+	"IPT/core/transaction"
+	"IPT/core/transaction/payload"
+	"errors"
+	"fmt"
+)
+
+//key of the program hash and asset ID pair in lockAssetList
+func lockAssetKey(programHash common.Uint160, assetID common.Uint256) string {
+	lock := payload.LockAsset{ProgramHash: programHash, AssetID: assetID}
+	return lock.ToString()
+}
+
+//check the transaction doesn't spend an output of a program hash and asset ID
+//pair with a pending LockAsset. the lock wins: a spend admitted after the
+//lock is rejected, and a lock admitted after a spend evicts it, see
+//evictLockedSpends.
+func (this *TXNPool) checkAssetLocked(txn *transaction.Transaction) error {
+	if txn.TxType == transaction.LockAsset {
+		return nil
+	}
+	reference, err := this.getReference(txn)
+	if err != nil {
+		return err
+	}
+	this.RLock()
+	defer this.RUnlock()
+	for input, output := range reference {
+		if _, ok := this.lockAssetList[lockAssetKey(output.ProgramHash, output.AssetID)]; ok {
+			return errors.New(fmt.Sprintf("input %s spends asset %x locked by a pending LockAsset",
+				input.ToString(), output.AssetID))
+		}
+	}
+	return nil
+}
+
+//check the transaction doesn't issue an asset with a pending LockAsset, the
+//lock is verified against the quantity the ledger holds which the issuance
+//would change. as with the spends the lock wins: an issuance admitted after
+//the lock is rejected and a lock admitted after an issuance evicts it.
+func (this *TXNPool) checkIssuanceLocked(txn *transaction.Transaction) error {
+	if txn.TxType != transaction.IssueAsset {
+		return nil
+	}
+	this.RLock()
+	defer this.RUnlock()
+	for assetID := range issuedAmounts(txn) {
+		if this.assetLocks[assetID] > 0 {
+			return errors.New(fmt.Sprintf("issuance of asset %x locked by a pending LockAsset", assetID))
+		}
+	}
+	return nil
+}
+
+//index the pooled transaction by the program hash and asset ID pairs of the
+//outputs it spends and by the assets it issues, so a LockAsset finds the
+//transactions it evicts. the caller must hold the lock.
+func (this *TXNPool) indexAssetSpends(txn *transaction.Transaction, reference map[*transaction.UTXOTxInput]*transaction.TxOutput) {
+	txnHash := txn.Hash()
+	for _, output := range reference {
+		key := lockAssetKey(output.ProgramHash, output.AssetID)
+		if _, ok := this.assetSpends[key][txnHash]; ok {
+			continue
+		}
+		if _, ok := this.assetSpends[key]; !ok {
+			this.assetSpends[key] = make(map[common.Uint256]struct{})
+		}
+		this.assetSpends[key][txnHash] = struct{}{}
+		this.spendKeys[txnHash] = append(this.spendKeys[txnHash], key)
+	}
+	if txn.TxType != transaction.IssueAsset {
+		return
+	}
+	for assetID := range issuedAmounts(txn) {
+		if _, ok := this.assetIssues[assetID]; !ok {
+			this.assetIssues[assetID] = make(map[common.Uint256]struct{})
+		}
+		this.assetIssues[assetID][txnHash] = struct{}{}
+	}
+}
+
+//the caller must hold the lock.
+func (this *TXNPool) unindexAssetSpends(txn *transaction.Transaction) {
+	txnHash := txn.Hash()
+	for _, key := range this.spendKeys[txnHash] {
+		delete(this.assetSpends[key], txnHash)
+		if len(this.assetSpends[key]) == 0 {
+			delete(this.assetSpends, key)
+		}
+	}
+	delete(this.spendKeys, txnHash)
+	if txn.TxType != transaction.IssueAsset {
+		return
+	}
+	for assetID := range issuedAmounts(txn) {
+		delete(this.assetIssues[assetID], txnHash)
+		if len(this.assetIssues[assetID]) == 0 {
+			delete(this.assetIssues, assetID)
+		}
+	}
+}
+
+//remove the pooled transactions spending an output locked by the LockAsset
+//transaction or issuing the locked asset, with their descendants. the caller
+//must hold the commit lock.
+func (this *TXNPool) evictLockedSpends(txn *transaction.Transaction) {
+	if txn.TxType != transaction.LockAsset {
+		return
+	}
+	lock := txn.Payload.(*payload.LockAsset)
+	this.RLock()
+	evicted := []*transaction.Transaction{}
+	for _, hashes := range []map[common.Uint256]struct{}{
+		this.assetIssues[lock.AssetID],
+		this.assetSpends[lockAssetKey(lock.ProgramHash, lock.AssetID)],
+	} {
+		for hash := range hashes {
+			if entry, ok := this.txnList[hash]; ok && entry.txn.TxType != transaction.LockAsset {
+				evicted = append(evicted, entry.txn)
+			}
+		}
+	}
+	this.RUnlock()
+	for _, pooled := range evicted {
+		if this.GetTransaction(pooled.Hash()) == nil {
+			continue
+		}
+		for _, removed := range this.removeWithDescendants(pooled) {
+			log.Info(fmt.Sprintf("Transaction =%x removed by the LockAsset %x", removed.Hash(), txn.Hash()))
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package node
+
+import (
+	"IPT/common/config"
+	"IPT/common/log"
+	"time"
+)
+
+//the maintenance window parsed from the config, the pool pauses admissions
+//in it like Freeze.
+type maintenanceWindow struct {
+	start      time.Time
+	length     time.Duration // 0 when no valid window is configured
+	recurrence time.Duration // 0 when the window doesn't recur
+}
+
+//parse the window configured, it's read when the pool is initialized and
+//when the limits are reloaded.
+func loadMaintenanceWindow() maintenanceWindow {
+	if config.Parameters.MaintenanceWindowStart == "" {
+		return maintenanceWindow{}
+	}
+	start, err := time.Parse(time.RFC3339, config.Parameters.MaintenanceWindowStart)
+	if err != nil {
+		log.Warn("Invalid MaintenanceWindowStart in config:", config.Parameters.MaintenanceWindowStart)
+		return maintenanceWindow{}
+	}
+	end, err := time.Parse(time.RFC3339, config.Parameters.MaintenanceWindowEnd)
+	if err != nil || !end.After(start) {
+		log.Warn("Invalid MaintenanceWindowEnd in config:", config.Parameters.MaintenanceWindowEnd)
+		return maintenanceWindow{}
+	}
+	return maintenanceWindow{
+		start:      start,
+		length:     end.Sub(start),
+		recurrence: time.Duration(config.Parameters.MaintenanceWindowRecurrence) * time.Second,
+	}
+}
+
+//check weather the time is in the window
+func (w maintenanceWindow) contains(now time.Time) bool {
+	if w.length == 0 || now.Before(w.start) {
+		return false
+	}
+	elapsed := now.Sub(w.start)
+	if w.recurrence > 0 {
+		elapsed %= w.recurrence
+	}
+	return elapsed < w.length
+}
+
+//check weather the time is in the maintenance window loaded
+func (this *TXNPool) inMaintenanceWindow(now time.Time) bool {
+	this.RLock()
+	defer this.RUnlock()
+	return this.maintenance.contains(now)
+}
@@ -0,0 +1,179 @@
+package node
+
+import (
+	"IPT/common"
+	. "IPT/common/errors"
+	"IPT/common/metrics"
+	"IPT/core/transaction"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+//upper bounds of the verify duration buckets, the last bucket is unbounded
+var verifyDurationBounds = []time.Duration{
+	time.Millisecond,
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+}
+
+//count of observed durations per bucket
+type DurationHistogram struct {
+	Bounds []time.Duration // upper bound of each bucket but the last one
+	Counts []uint64        // len(Bounds)+1 buckets
+	Sum    time.Duration
+	Count  uint64
+}
+
+type durationHistogram struct {
+	sync.Mutex
+	data DurationHistogram
+}
+
+func (h *durationHistogram) init(bounds []time.Duration) {
+	h.Lock()
+	defer h.Unlock()
+	h.data = DurationHistogram{
+		Bounds: bounds,
+		Counts: make([]uint64, len(bounds)+1),
+	}
+}
+
+func (h *durationHistogram) observe(d time.Duration) {
+	h.Lock()
+	defer h.Unlock()
+	i := 0
+	for i < len(h.data.Bounds) && d > h.data.Bounds[i] {
+		i++
+	}
+	h.data.Counts[i]++
+	h.data.Sum += d
+	h.data.Count++
+}
+
+func (h *durationHistogram) snapshot() DurationHistogram {
+	h.Lock()
+	defer h.Unlock()
+	data := h.data
+	data.Counts = append([]uint64(nil), h.data.Counts...)
+	return data
+}
+
+//get the histogram of the time spent verifying transactions
+func (this *TXNPool) VerifyDurations() DurationHistogram {
+	return this.verifyDurations.snapshot()
+}
+
+//count of the transactions admitted to the pool by source
+type AdmissionCounts struct {
+	Submitted        uint64 // submitted to AppendTxnPool, including the promoted orphans
+	Reinserted       uint64 // returned from blocks orphaned by a reorg
+	DuplicateOutputs uint64 // producing the outputs of a pooled transaction, counted when FlagDuplicateOutputs is set
+}
+
+//get the count of the transactions admitted to the pool
+func (this *TXNPool) AdmissionCounts() AdmissionCounts {
+	return AdmissionCounts{
+		Submitted:        atomic.LoadUint64(&this.admissions.Submitted),
+		Reinserted:       atomic.LoadUint64(&this.admissions.Reinserted),
+		DuplicateOutputs: atomic.LoadUint64(&this.admissions.DuplicateOutputs),
+	}
+}
+
+type rejectionCounts struct {
+	sync.Mutex
+	counts map[ErrCode]uint64
+}
+
+func (r *rejectionCounts) init() {
+	r.Lock()
+	defer r.Unlock()
+	r.counts = make(map[ErrCode]uint64)
+}
+
+func (r *rejectionCounts) add(errCode ErrCode) {
+	r.Lock()
+	defer r.Unlock()
+	r.counts[errCode]++
+}
+
+func (r *rejectionCounts) snapshot() map[ErrCode]uint64 {
+	r.Lock()
+	defer r.Unlock()
+	counts := make(map[ErrCode]uint64, len(r.counts))
+	for errCode, count := range r.counts {
+		counts[errCode] = count
+	}
+	return counts
+}
+
+//collect the counters and gauges of the pool, the pool is registered to the
+//node metrics by metrics.Register.
+func (this *TXNPool) Collect() []metrics.Metric {
+	collected := []metrics.Metric{}
+	add := func(name, help, kind string, labels map[string]string, value float64) {
+		collected = append(collected, metrics.Metric{Name: name, Help: help, Type: kind, Labels: labels, Value: value})
+	}
+
+	this.RLock()
+	var fees common.Fixed64
+	byType := make(map[transaction.TransactionType]int)
+	for _, entry := range this.txnList {
+		fees += entry.fee
+		byType[entry.txn.TxType]++
+	}
+	count, bytes := len(this.txnList), this.txnBytes
+	this.RUnlock()
+	add("txnpool_transactions", "Number of pooled transactions.", metrics.Gauge, nil, float64(count))
+	add("txnpool_bytes", "Serialized size of the pooled transactions.", metrics.Gauge, nil, float64(bytes))
+	add("txnpool_fees", "Fee paid by the pooled transactions.", metrics.Gauge, nil, float64(fees)/100000000)
+	for txType, n := range byType {
+		add("txnpool_transactions_by_type", "Number of pooled transactions by type.", metrics.Gauge,
+			map[string]string{"type": fmt.Sprintf("0x%02x", byte(txType))}, float64(n))
+	}
+
+	admissions := this.AdmissionCounts()
+	add("txnpool_admissions_total", "Transactions admitted to the pool by source.", metrics.Counter,
+		map[string]string{"source": "submitted"}, float64(admissions.Submitted))
+	add("txnpool_admissions_total", "Transactions admitted to the pool by source.", metrics.Counter,
+		map[string]string{"source": "reinserted"}, float64(admissions.Reinserted))
+	for errCode, n := range this.rejections.snapshot() {
+		add("txnpool_rejections_total", "Transactions rejected by AppendTxnPool by reason.", metrics.Counter,
+			map[string]string{"reason": errCode.Error()}, float64(n))
+	}
+
+	durations := this.VerifyDurations()
+	help := "Time spent verifying transactions."
+	var cumulative uint64
+	for i, n := range durations.Counts {
+		cumulative += n
+		le := "+Inf"
+		if i < len(durations.Bounds) {
+			le = fmt.Sprintf("%v", durations.Bounds[i].Seconds())
+		}
+		add("txnpool_verify_duration_seconds_bucket", help, metrics.Histogram, map[string]string{"le": le}, float64(cumulative))
+	}
+	add("txnpool_verify_duration_seconds_sum", help, metrics.Histogram, nil, durations.Sum.Seconds())
+	add("txnpool_verify_duration_seconds_count", help, metrics.Histogram, nil, float64(durations.Count))
+	return collected
+}
+
+//one line description of the pool for the status logs, e.g.
+//"pool: 3 txns, 1024 bytes, 0.003 total fees, top-fee-rate 0.0015"
+func (this *TXNPool) Summary() string {
+	this.RLock()
+	defer this.RUnlock()
+	var fees, topRate common.Fixed64
+	for _, entry := range this.txnList {
+		fees += entry.fee
+		if rate := entry.feeRate(); rate > topRate {
+			topRate = rate
+		}
+	}
+	return fmt.Sprintf("pool: %d txns, %d bytes, %v total fees, top-fee-rate %v", len(this.txnList), this.txnBytes, fees, topRate)
+}
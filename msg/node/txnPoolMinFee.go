@@ -0,0 +1,109 @@
+package node
+
+import (
+	"IPT/common"
+	"IPT/common/config"
+	"IPT/common/log"
+	"IPT/core/transaction"
+	"fmt"
+	"math"
+	"time"
+)
+
+//the min fee rate raised by the evictions of the full pool
+type dynamicMinFee struct {
+	rate   common.Fixed64 // fee rate just above the last evicted package
+	raised time.Duration  // clock.Elapsed when the rate was raised
+}
+
+func minFeeHalfLife() time.Duration {
+	return time.Duration(config.Parameters.MinFeeHalfLife) * time.Second
+}
+
+//raise the min fee rate above the package fee rate evicted to make room, so
+//the transactions which would be evicted right away are not admitted. it's
+//only raised with MinFeeHalfLife set.
+func (this *TXNPool) raiseMinFee(evicted common.Fixed64) {
+	if minFeeHalfLife() == 0 {
+		return
+	}
+	this.Lock()
+	defer this.Unlock()
+	rate := evicted + 1
+	if current := this.decayedMinFee(); rate <= current {
+		return
+	}
+	this.minFeeBump = dynamicMinFee{rate: rate, raised: this.clock.Elapsed()}
+	log.Info(fmt.Sprintf("Min fee rate raised to %v, transaction pool is full", rate))
+}
+
+//the raised min fee rate halved every MinFeeHalfLife since it was raised,
+//the caller must hold the lock.
+func (this *TXNPool) decayedMinFee() common.Fixed64 {
+	halfLife := minFeeHalfLife()
+	if halfLife == 0 || this.minFeeBump.rate == 0 {
+		return 0
+	}
+	halvings := float64(this.age(this.minFeeBump.raised)) / float64(halfLife)
+	return common.Fixed64(float64(this.minFeeBump.rate) * math.Pow(0.5, halvings))
+}
+
+//get the min fee per thousand weight units a transaction spending inputs has
+//to pay to be admitted. it's MinTxFee unless the pool was full recently,
+//then it's above the fee rate of the last evicted package and decays back to
+//MinTxFee with MinFeeHalfLife.
+func (this *TXNPool) CurrentMinFeeRate() common.Fixed64 {
+	return this.currentMinFeeRate(this.Limits())
+}
+
+func (this *TXNPool) currentMinFeeRate(limits PoolLimits) common.Fixed64 {
+	this.RLock()
+	defer this.RUnlock()
+	if raised := this.decayedMinFee(); raised > limits.MinTxFee {
+		return raised
+	}
+	return limits.MinTxFee
+}
+
+//tell which pooled transactions would be evicted to make room for the
+//transaction and the min fee rate CurrentMinFeeRate would return right after
+//its admission, so a wallet can bid just above the floor. the pool is not
+//changed and the transaction is not verified, see WouldAccept. nothing is
+//evicted when the pool has room or the transaction would be the lowest fee
+//rate of the full pool, which is not admitted.
+func (this *TXNPool) SimulateAdmission(txn *transaction.Transaction) (wouldEvict []common.Uint256, newMinFeeFloor common.Fixed64) {
+	limits := this.Limits()
+	entry := this.newTxnEntry(txn)
+	this.RLock()
+	defer this.RUnlock()
+	floor := this.decayedMinFee()
+	wouldEvict = []common.Uint256{}
+	queue := this.newEvictionQueue(nil)
+	count, bytes := len(this.txnList)+1, this.txnBytes+entry.size
+	for (limits.MaxPoolSize > 0 && count > limits.MaxPoolSize) || (limits.MaxPoolBytes > 0 && bytes > limits.MaxPoolBytes) {
+		victim, rate := queue.peek()
+		if victim == nil || entry.feeRate() <= rate {
+			wouldEvict = []common.Uint256{}
+			floor = this.decayedMinFee()
+			break
+		}
+		removed := []*transaction.Transaction{}
+		for _, txn := range append(this.descendants(victim.Hash()), victim) {
+			if _, ok := queue.candidates[txn.Hash()]; !ok {
+				continue
+			}
+			removed = append(removed, txn)
+			wouldEvict = append(wouldEvict, txn.Hash())
+			count--
+			bytes -= this.txnList[txn.Hash()].size
+		}
+		this.dropEvicted(queue, removed)
+		if minFeeHalfLife() > 0 && rate+1 > floor {
+			floor = rate + 1
+		}
+	}
+	if floor < limits.MinTxFee {
+		floor = limits.MinTxFee
+	}
+	return wouldEvict, floor
+}
@@ -0,0 +1,182 @@
+package node
+
+import (
+	"IPT/common"
+	"IPT/common/config"
+	. "IPT/common/errors"
+	"IPT/common/log"
+	"IPT/core/transaction"
+	"fmt"
+	"sort"
+	"sync/atomic"
+	"time"
+)
+
+const ORPHANTTL = 20 * time.Minute // how long an orphan waits for the transactions it spends
+
+//transaction spending the outputs of transactions not known yet. only the
+//checks not needing the outputs it spends passed before it was buffered.
+type orphanEntry struct {
+	txn      *transaction.Transaction
+	missing  map[common.Uint256]struct{} // the transactions it still waits for
+	added    time.Duration               // clock.Elapsed when it was buffered
+	expiry   TxnExpiry                   // the submitter's expiry the transaction is promoted with
+	verified bool                        // the transaction itself is verified, it's not verified again on promotion
+}
+
+//the transactions referenced by the inputs which are neither in the pool nor
+//in the ledger. an error is returned when the store fails to read one, the
+//transaction isn't known to be an orphan then.
+func (this *TXNPool) missingParents(txn *transaction.Transaction) ([]common.Uint256, error) {
+	missing := []common.Uint256{}
+	seen := make(map[common.Uint256]struct{})
+	for _, input := range txn.UTXOInputs {
+		if _, ok := seen[input.ReferTxID]; ok {
+			continue
+		}
+		seen[input.ReferTxID] = struct{}{}
+		if this.GetTransaction(input.ReferTxID) != nil {
+			continue
+		}
+		if _, err := transaction.TxStore.GetTransaction(input.ReferTxID); err == nil {
+			continue
+		} else if !transaction.IsTxNotFound(err) {
+			return nil, err
+		}
+		missing = append(missing, input.ReferTxID)
+	}
+	return missing, nil
+}
+
+//buffer the orphan after verifying the transaction itself, the verification
+//with the ledger and the outputs it spends runs when it's promoted. when the
+//buffer is full an arbitrary orphan is dropped for it.
+func (this *TXNPool) addOrphan(txn *transaction.Transaction, missing []common.Uint256, expiry TxnExpiry) ErrCode {
+	if errCode := this.verifyWithTimeout(txn, true, this.verifier.VerifyTransaction); errCode != ErrNoError {
+		log.Info(fmt.Sprintf("Orphan transaction =%x rejected, %v", txn.Hash(), errCode))
+		return errCode
+	}
+	this.Lock()
+	defer this.Unlock()
+	txnHash := txn.Hash()
+	if _, ok := this.orphanList[txnHash]; ok {
+		return ErrOrphanTransaction
+	}
+	for len(this.orphanList) >= config.Parameters.MaxOrphanTransactions {
+		for hash, entry := range this.orphanList {
+			log.Info(fmt.Sprintf("Orphan transaction =%x dropped, orphan buffer is full", hash))
+			this.delOrphan(entry)
+			break
+		}
+	}
+	entry := &orphanEntry{txn: txn, missing: make(map[common.Uint256]struct{}), added: this.clock.Elapsed(), expiry: expiry, verified: true}
+	for _, parent := range missing {
+		entry.missing[parent] = struct{}{}
+		if _, ok := this.orphanParents[parent]; !ok {
+			this.orphanParents[parent] = make(map[common.Uint256]struct{})
+		}
+		this.orphanParents[parent][txnHash] = struct{}{}
+	}
+	this.orphanList[txnHash] = entry
+	log.Info(fmt.Sprintf("Transaction =%x kept as orphan, waiting for %d transactions", txnHash, len(missing)))
+	return ErrOrphanTransaction
+}
+
+//remove the orphan from the buffer, the caller must hold the lock.
+func (this *TXNPool) delOrphan(entry *orphanEntry) {
+	txnHash := entry.txn.Hash()
+	delete(this.orphanList, txnHash)
+	for parent := range entry.missing {
+		delete(this.orphanParents[parent], txnHash)
+		if len(this.orphanParents[parent]) == 0 {
+			delete(this.orphanParents, parent)
+		}
+	}
+}
+
+//the orphans with no missing transaction left after the transaction arrived,
+//they are taken out of the buffer.
+func (this *TXNPool) resolveOrphans(parent common.Uint256) []*orphanEntry {
+	this.Lock()
+	defer this.Unlock()
+	resolved := []*orphanEntry{}
+	for hash := range this.orphanParents[parent] {
+		entry := this.orphanList[hash]
+		delete(entry.missing, parent)
+		if len(entry.missing) == 0 {
+			delete(this.orphanList, hash)
+			resolved = append(resolved, entry)
+		}
+	}
+	delete(this.orphanParents, parent)
+	return resolved
+}
+
+//promote the orphans waiting for the transaction
+func (this *TXNPool) promoteOrphans(parent common.Uint256) {
+	for _, entry := range this.resolveOrphans(parent) {
+		if errCode := this.promoteOrphan(entry); errCode != ErrNoError {
+			log.Info(fmt.Sprintf("Orphan transaction =%x dropped, %v", entry.txn.Hash(), errCode))
+		}
+	}
+}
+
+//move the orphan taken out of the buffer to the pool. the transaction itself
+//was verified when it was buffered, now the transactions it spends exist it's
+//only verified with the ledger and committed.
+func (this *TXNPool) promoteOrphan(entry *orphanEntry) ErrCode {
+	txn := entry.txn
+	verifiers := []func(*transaction.Transaction) ErrCode{this.ledgerVerifierOf(txn, true)}
+	if !entry.verified {
+		verifiers = append([]func(*transaction.Transaction) ErrCode{this.verifier.VerifyTransaction}, verifiers...)
+	}
+	if errCode := this.verifyWithTimeout(txn, true, verifiers...); errCode != ErrNoError {
+		return errCode
+	}
+	added, errCode := this.commitTransaction(txn, true, false, entry.expiry)
+	if errCode != ErrNoError || !added {
+		return errCode
+	}
+	atomic.AddUint64(&this.admissions.Submitted, 1)
+	this.events.admitted(this.clock.Elapsed())
+	log.Info(fmt.Sprintf("Orphan transaction =%x promoted to the pool", txn.Hash()))
+	this.promoteOrphans(txn.Hash())
+	return ErrNoError
+}
+
+//drop the orphans which waited longer than ORPHANTTL for the transactions
+//they spend.
+func (this *TXNPool) sweepOrphans() {
+	this.Lock()
+	defer this.Unlock()
+	for hash, entry := range this.orphanList {
+		if this.age(entry.added) >= ORPHANTTL {
+			log.Info(fmt.Sprintf("Orphan transaction =%x expired, dropped from the orphan buffer", hash))
+			this.delOrphan(entry)
+		}
+	}
+}
+
+func orphanSweepInterval() time.Duration {
+	if config.Parameters.MaxOrphanTransactions <= 0 {
+		return 0
+	}
+	return SCHEDULERTICK
+}
+
+//get the buffered orphans, each with the transactions it still waits for in
+//ascending order.
+func (this *TXNPool) OrphanReport() map[common.Uint256][]common.Uint256 {
+	this.RLock()
+	defer this.RUnlock()
+	report := make(map[common.Uint256][]common.Uint256, len(this.orphanList))
+	for hash, entry := range this.orphanList {
+		parents := make([]common.Uint256, 0, len(entry.missing))
+		for parent := range entry.missing {
+			parents = append(parents, parent)
+		}
+		sort.Slice(parents, func(i, j int) bool { return parents[i].CompareTo(parents[j]) < 0 })
+		report[hash] = parents
+	}
+	return report
+}
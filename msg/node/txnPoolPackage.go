@@ -0,0 +1,43 @@
+package node
+
+import (
+	"IPT/common"
+	"IPT/core/transaction"
+)
+
+//get the fee rate of the package made of the pooled transaction and its
+//pooled ancestors, the rate a block including the transaction gets since the
+//ancestors have to be included first. prioritise deltas are counted. the
+//package of each transaction is kept up to date as the transactions are
+//added and removed, 0 is returned when it's not pooled.
+func (this *TXNPool) PackageFeeRate(hash common.Uint256) common.Fixed64 {
+	this.RLock()
+	defer this.RUnlock()
+	entry, ok := this.txnList[hash]
+	if !ok {
+		return 0
+	}
+	return getFeeRate(entry.packageFee, entry.packageWeight)
+}
+
+//sum the package of the pooled transaction from its ancestors, the caller
+//must hold the lock.
+func (this *TXNPool) updatePackage(entry *txnEntry) {
+	entry.packageFee = entry.fee + entry.feeDelta
+	entry.packageWeight = entry.weight
+	for _, ancestor := range this.ancestors(entry.txn.Hash()) {
+		a := this.txnList[ancestor.Hash()]
+		entry.packageFee += a.fee + a.feeDelta
+		entry.packageWeight += a.weight
+	}
+}
+
+//update the packages of the pooled transactions whose ancestors changed, the
+//caller must hold the lock.
+func (this *TXNPool) updatePackages(txns []*transaction.Transaction) {
+	for _, txn := range txns {
+		if entry, ok := this.txnList[txn.Hash()]; ok {
+			this.updatePackage(entry)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package node
+
+import (
+	"IPT/common"
+	"IPT/common/config"
+	"IPT/core/transaction"
+	"container/list"
+	"sync"
+)
+
+//the number of recently confirmed transaction hashes kept
+const RECENTCONFIRMEDHASHES = 10000
+
+//bounded set of hashes, the least recently used hash is dropped when full
+type recentHashes struct {
+	sync.Mutex
+	capacity int
+	order    *list.List // most recently used first
+	index    map[common.Uint256]*list.Element
+}
+
+func (r *recentHashes) init(capacity int) {
+	r.Lock()
+	defer r.Unlock()
+	r.capacity = capacity
+	r.order = list.New()
+	r.index = make(map[common.Uint256]*list.Element)
+}
+
+func (r *recentHashes) add(hash common.Uint256) {
+	r.Lock()
+	defer r.Unlock()
+	if elem, ok := r.index[hash]; ok {
+		r.order.MoveToFront(elem)
+		return
+	}
+	r.index[hash] = r.order.PushFront(hash)
+	for r.order.Len() > r.capacity {
+		oldest := r.order.Back()
+		r.order.Remove(oldest)
+		delete(r.index, oldest.Value.(common.Uint256))
+	}
+}
+
+func (r *recentHashes) contains(hash common.Uint256) bool {
+	r.Lock()
+	defer r.Unlock()
+	elem, ok := r.index[hash]
+	if ok {
+		r.order.MoveToFront(elem)
+	}
+	return ok
+}
+
+//check weather the transaction was confirmed by one of the recent blocks,
+//so the relay layer can skip the re-announcements of it from slow peers.
+func (this *TXNPool) WasRecentlyConfirmed(hash common.Uint256) bool {
+	return this.recentConfirmed.contains(hash)
+}
+
+//check weather the transaction is in the ledger when RejectConfirmedTransactions
+//is set, the recently confirmed ones are known without the lookup.
+func (this *TXNPool) isConfirmed(hash common.Uint256) bool {
+	if !config.Parameters.RejectConfirmedTransactions {
+		return false
+	}
+	if this.WasRecentlyConfirmed(hash) {
+		return true
+	}
+	_, err := transaction.TxStore.GetTransaction(hash)
+	return err == nil
+}
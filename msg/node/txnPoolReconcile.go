@@ -0,0 +1,70 @@
+package node
+
+import (
+	"IPT/common"
+	"IPT/core/ledger"
+	"IPT/core/transaction"
+)
+
+//get the hashes of the pooled transactions not included in the block, used to
+//decide what to announce again after a block from a peer.
+func (this *TXNPool) TransactionsNotIn(block *ledger.Block) []common.Uint256 {
+	inBlock := make(map[common.Uint256]struct{}, len(block.Transactions))
+	for _, txn := range block.Transactions {
+		inBlock[txn.Hash()] = struct{}{}
+	}
+	this.RLock()
+	defer this.RUnlock()
+	hashes := []common.Uint256{}
+	for hash := range this.txnList {
+		if _, ok := inBlock[hash]; !ok {
+			hashes = append(hashes, hash)
+		}
+	}
+	return hashes
+}
+
+//get the transactions of the block the pool doesn't hold, in block order, to
+//be called before CleanSubmittedTransactions. it's normal for a block to have
+//transactions the node never saw, the share of them tells how much of the
+//blocks the network mines from transactions the pool knows. the BookKeeping
+//transactions are created by the block proposer and never pooled, so they
+//are not listed.
+func (this *TXNPool) ValidateBlockAgainstPool(block *ledger.Block) (unseen []common.Uint256) {
+	this.RLock()
+	defer this.RUnlock()
+	for _, txn := range block.Transactions {
+		if txn.TxType == transaction.BookKeeping {
+			continue
+		}
+		if _, ok := this.txnList[txn.Hash()]; !ok {
+			unseen = append(unseen, txn.Hash())
+		}
+	}
+	return unseen
+}
+
+//compare the pool with the hashes a peer advertised, giving the ones the node
+//lacks to request and the ones the peer lacks to offer.
+func (this *TXNPool) ReconcileInventory(peerHashes []common.Uint256) ([]common.Uint256, []common.Uint256) {
+	peer := make(map[common.Uint256]struct{}, len(peerHashes))
+	missingLocally := []common.Uint256{}
+	this.RLock()
+	defer this.RUnlock()
+	for _, hash := range peerHashes {
+		if _, ok := peer[hash]; ok {
+			continue
+		}
+		peer[hash] = struct{}{}
+		if _, ok := this.txnList[hash]; !ok {
+			missingLocally = append(missingLocally, hash)
+		}
+	}
+	extraLocally := []common.Uint256{}
+	for hash := range this.txnList {
+		if _, ok := peer[hash]; !ok {
+			extraLocally = append(extraLocally, hash)
+		}
+	}
+	return missingLocally, extraLocally
+}
@@ -0,0 +1,144 @@
+package node
+
+import (
+	"IPT/common"
+	"IPT/common/config"
+	. "IPT/common/errors"
+	"IPT/core/transaction"
+	"errors"
+	"fmt"
+	"time"
+)
+
+const (
+	CONFLICTFIRSTSEEN  = "FirstSeen"  // the pooled transaction is never replaced
+	CONFLICTHIGHESTFEE = "HighestFee" // the transaction paying more fee than the conflicting ones wins
+	CONFLICTRBFONLY    = "RBFOnly"    // replace by the RBF rules, paying for the descendants too
+)
+
+//the ConflictPolicy, or the one given by EnableRBF when it's not set
+func conflictPolicy() string {
+	switch config.Parameters.ConflictPolicy {
+	case CONFLICTFIRSTSEEN, CONFLICTHIGHESTFEE, CONFLICTRBFONLY:
+		return config.Parameters.ConflictPolicy
+	}
+	if config.Parameters.EnableRBF {
+		return CONFLICTRBFONLY
+	}
+	return CONFLICTFIRSTSEEN
+}
+
+//the pooled transactions spending the same inputs as the replacement and
+//the transactions spending their outputs, which become invalid once they are
+//replaced. descendants are listed before the transactions they spend from.
+func (this *TXNPool) replacedTransactions(conflicts map[common.Uint256]*transaction.Transaction) []*transaction.Transaction {
+	this.RLock()
+	defer this.RUnlock()
+	replaced := []*transaction.Transaction{}
+	seen := make(map[common.Uint256]struct{})
+	for hash, conflict := range conflicts {
+		for _, txn := range append(this.descendants(hash), conflict) {
+			if _, ok := seen[txn.Hash()]; ok {
+				continue
+			}
+			seen[txn.Hash()] = struct{}{}
+			replaced = append(replaced, txn)
+		}
+	}
+	return replaced
+}
+
+//check weather the transaction can replace the pooled transactions, it must
+//pay more fee than all the replaced transactions together. the replacement
+//may conflict with only some inputs of them, e.g. to cancel a transaction.
+//with RBFRequireHigherFeeRate it must also pay a higher fee rate than each of
+//the conflicting transactions, unless they were admitted concurrently.
+func (this *TXNPool) checkReplacement(txn *transaction.Transaction, conflicts map[common.Uint256]*transaction.Transaction,
+	replaced []*transaction.Transaction) error {
+	replacement := this.newTxnEntry(txn)
+	this.RLock()
+	defer this.RUnlock()
+	required := this.requiredReplacementFee(replacement.weight, conflicts, replaced)
+	if replacement.fee < required {
+		return NewDetailErr(errors.New(fmt.Sprintf("replacement transaction %x fee %v lower than required %v",
+			txn.Hash(), replacement.fee, required)), ErrInsufficientReplacementFee, "[TXNPool], replacement rejected")
+	}
+	return nil
+}
+
+//get the least fee the transaction must pay to replace the pooled transactions
+//spending the same inputs and their descendants, 0 when nothing conflicts.
+func (this *TXNPool) RequiredReplacementFee(txn *transaction.Transaction) common.Fixed64 {
+	conflicts := make(map[common.Uint256]*transaction.Transaction)
+	for _, input := range txn.UTXOInputs {
+		if spender := this.getInputUTXOList(input); spender != nil {
+			conflicts[spender.Hash()] = spender
+		}
+	}
+	if len(conflicts) == 0 {
+		return common.Fixed64(0)
+	}
+	replaced := this.replacedTransactions(conflicts)
+	weight := Weight(txn)
+	this.RLock()
+	defer this.RUnlock()
+	return this.requiredReplacementFee(weight, conflicts, replaced)
+}
+
+//the least fee of a replacement with the weight, the caller must hold the lock.
+//with the HighestFee policy it only has to pay more than the conflicting
+//transactions, their descendants are evicted regardless of their fee.
+func (this *TXNPool) requiredReplacementFee(weight int, conflicts map[common.Uint256]*transaction.Transaction,
+	replaced []*transaction.Transaction) common.Fixed64 {
+	var replacedFee common.Fixed64
+	if conflictPolicy() == CONFLICTHIGHESTFEE {
+		for hash := range conflicts {
+			if entry, ok := this.txnList[hash]; ok {
+				replacedFee += entry.fee
+			}
+		}
+		return replacedFee + 1
+	}
+	for _, r := range replaced {
+		if entry, ok := this.txnList[r.Hash()]; ok {
+			replacedFee += entry.fee
+		}
+	}
+	required := replacedFee + 1
+	if !config.Parameters.RBFRequireHigherFeeRate || this.admittedConcurrently(conflicts) {
+		return required
+	}
+	for hash := range conflicts {
+		entry, ok := this.txnList[hash]
+		if !ok {
+			continue
+		}
+		//the fee giving a fee rate above the one of the conflict
+		fee := entry.rawFeeRate() + 1
+		if weight > 0 {
+			fee = (fee*common.Fixed64(weight) + 999) / 1000
+		}
+		if fee > required {
+			required = fee
+		}
+	}
+	return required
+}
+
+//check weather all the conflicting transactions were admitted within
+//RBFConcurrentWindow. conflicting transactions arriving nearly together are
+//resolved by fee only, so the higher fee one is kept whichever was admitted
+//first. the caller must hold the lock.
+func (this *TXNPool) admittedConcurrently(conflicts map[common.Uint256]*transaction.Transaction) bool {
+	window := time.Duration(config.Parameters.RBFConcurrentWindow) * time.Millisecond
+	if window == 0 {
+		return false
+	}
+	for hash := range conflicts {
+		entry, ok := this.txnList[hash]
+		if ok && this.age(entry.added) > window {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,122 @@
+package node
+
+import (
+	"IPT/common"
+	"IPT/common/config"
+	"IPT/common/log"
+	"IPT/core/transaction"
+	"fmt"
+	"time"
+)
+
+//identify the transactions reserved by one SelectAndReserve
+type ReservationToken uint64
+
+//select the block candidates like GetTxnPool and reserve them in one step,
+//so a concurrent producer selects other transactions. at most maxCount
+//transactions of at most maxBytes serialized size are selected, 0 means no
+//limit. a transaction spending a pooled transaction is only selected after
+//it. the reservation is kept until Release, until the transactions leave the
+//pool, or until it expires after ReservationTTL.
+func (this *TXNPool) SelectAndReserve(maxCount, maxBytes int) ([]*transaction.Transaction, ReservationToken) {
+	now := this.clock.Elapsed()
+	this.Lock()
+	defer this.Unlock()
+	this.lastReservation++
+	token := this.lastReservation
+	if ttl := reservationTTL(); ttl > 0 {
+		this.reservedUntil[token] = now + ttl
+	}
+	txns := []*transaction.Transaction{}
+	bytes := 0
+	pending := this.sortedTxnList()
+	//a transaction waiting for its parent is tried again once the parent is selected
+	for selected := true; selected; {
+		selected = false
+		skipped := []*txnEntry{}
+		for _, entry := range pending {
+			if maxCount > 0 && len(txns) >= maxCount {
+				return txns, token
+			}
+			if _, ok := this.reserved[entry.txn.Hash()]; ok || (maxBytes > 0 && bytes+entry.size > maxBytes) {
+				continue
+			}
+			if !this.parentsReserved(entry.txn, token) {
+				skipped = append(skipped, entry)
+				continue
+			}
+			this.reserved[entry.txn.Hash()] = token
+			txns = append(txns, entry.txn)
+			bytes += entry.size
+			selected = true
+		}
+		pending = skipped
+	}
+	return txns, token
+}
+
+//check weather the pooled transactions the transaction spends from are
+//reserved by the token, the caller must hold the lock.
+func (this *TXNPool) parentsReserved(txn *transaction.Transaction, token ReservationToken) bool {
+	for _, input := range txn.UTXOInputs {
+		if _, ok := this.txnList[input.ReferTxID]; !ok {
+			continue
+		}
+		if owner, ok := this.reserved[input.ReferTxID]; !ok || owner != token {
+			return false
+		}
+	}
+	return true
+}
+
+//release the transactions reserved by SelectAndReserve, e.g. when the block
+//failed, so they can be selected again.
+func (this *TXNPool) Release(token ReservationToken) {
+	this.Lock()
+	defer this.Unlock()
+	this.release(token)
+}
+
+//the caller must hold the lock
+func (this *TXNPool) release(token ReservationToken) {
+	delete(this.reservedUntil, token)
+	for hash, owner := range this.reserved {
+		if owner == token {
+			delete(this.reserved, hash)
+		}
+	}
+}
+
+//check weather the transaction is reserved
+func (this *TXNPool) IsReserved(hash common.Uint256) bool {
+	this.RLock()
+	defer this.RUnlock()
+	_, ok := this.reserved[hash]
+	return ok
+}
+
+func reservationTTL() time.Duration {
+	return time.Duration(config.Parameters.ReservationTTL) * time.Second
+}
+
+func reservationSweepInterval() time.Duration {
+	if reservationTTL() == 0 {
+		return 0
+	}
+	return SCHEDULERTICK
+}
+
+//release the reservations kept longer than ReservationTTL, so the
+//transactions of a producer which never released them can be selected again.
+func (this *TXNPool) sweepReservations() {
+	now := this.clock.Elapsed()
+	this.Lock()
+	defer this.Unlock()
+	for token, until := range this.reservedUntil {
+		if now < until {
+			continue
+		}
+		log.Info(fmt.Sprintf("Reservation %d expired without release", token))
+		this.release(token)
+	}
+}
@@ -0,0 +1,55 @@
+package node
+
+import (
+	"IPT/common"
+	. "IPT/common/errors"
+	"IPT/core/transaction"
+)
+
+//store resolving the pooled transactions before the wrapped store, so the
+//verification of a transaction spending a pooled transaction, e.g. its
+//GetReference and GetTransactionResults, finds the outputs it spends. it's
+//only handed to the verifier by the pool, never set to TxStore.
+type poolTxStore struct {
+	transaction.ILedgerStore
+	pool *TXNPool
+}
+
+func (s poolTxStore) GetTransaction(hash common.Uint256) (*transaction.Transaction, error) {
+	if txn := s.pool.GetTransaction(hash); txn != nil {
+		return txn, nil
+	}
+	return s.ILedgerStore.GetTransaction(hash)
+}
+
+//check weather the transaction spends an output of a pooled transaction
+func (this *TXNPool) spendsPooled(txn *transaction.Transaction) bool {
+	this.RLock()
+	defer this.RUnlock()
+	return this.spendsPooledLocked(txn)
+}
+
+//the caller must hold the lock
+func (this *TXNPool) spendsPooledLocked(txn *transaction.Transaction) bool {
+	for _, input := range txn.UTXOInputs {
+		if _, ok := this.txnList[input.ReferTxID]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+//the verification of the transaction with the ledger. only the pool resolves
+//the pooled parents of a transaction, whose inputs spending them are checked
+//for double spends by the pool. a transaction of a block is verified with the
+//ledger alone.
+func (this *TXNPool) ledgerVerifierOf(txn *transaction.Transaction, poolVerify bool) func(*transaction.Transaction) ErrCode {
+	verifier := this.verifier
+	if !poolVerify || !this.spendsPooled(txn) {
+		return verifier.VerifyTransactionWithLedger
+	}
+	store := poolTxStore{ILedgerStore: transaction.TxStore, pool: this}
+	return func(txn *transaction.Transaction) ErrCode {
+		return verifier.VerifyTransactionWithLedgerAndStore(txn, store)
+	}
+}
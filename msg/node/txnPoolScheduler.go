@@ -0,0 +1,111 @@
+package node
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	SCHEDULERTICK = time.Second // how often the scheduler checks for due tasks
+	POOLWORKERS   = 2           // goroutines running the periodic tasks
+)
+
+//maintenance task run periodically after Start
+type periodicTask struct {
+	name     string
+	interval func() time.Duration // read on every check, 0 disables the task
+	run      func()
+	next     time.Duration // clock.Elapsed when the task is due, zero until scheduled
+	running  bool          // a worker is running the task
+}
+
+//runs the periodic tasks on a fixed number of workers
+type scheduler struct {
+	sync.Mutex
+	tasks []*periodicTask
+}
+
+//register a periodic task, it must be added before Start
+func (s *scheduler) add(name string, interval func() time.Duration, run func()) {
+	s.Lock()
+	defer s.Unlock()
+	s.tasks = append(s.tasks, &periodicTask{name: name, interval: interval, run: run})
+}
+
+//the tasks due at the time, they are marked running until done is called.
+//a task is scheduled again relative to when it was due so it doesn't drift.
+func (s *scheduler) due(now time.Duration) []*periodicTask {
+	s.Lock()
+	defer s.Unlock()
+	due := []*periodicTask{}
+	for _, task := range s.tasks {
+		interval := task.interval()
+		if interval <= 0 {
+			task.next = 0
+			continue
+		}
+		if task.next == 0 {
+			task.next = now + interval
+			continue
+		}
+		if task.running || now < task.next {
+			continue
+		}
+		for now >= task.next {
+			task.next += interval
+		}
+		task.running = true
+		due = append(due, task)
+	}
+	return due
+}
+
+func (s *scheduler) done(task *periodicTask) {
+	s.Lock()
+	defer s.Unlock()
+	task.running = false
+}
+
+//start the background goroutines of the pool
+func (this *TXNPool) Start() {
+	this.blockCommitted()
+	jobs := make(chan *periodicTask)
+	for i := 0; i < POOLWORKERS; i++ {
+		this.workers.Add(1)
+		go func() {
+			defer this.workers.Done()
+			for task := range jobs {
+				task.run()
+				this.tasks.done(task)
+			}
+		}()
+	}
+	this.workers.Add(1)
+	go func() {
+		defer this.workers.Done()
+		defer close(jobs)
+		this.tasks.due(this.clock.Elapsed())
+		ticker := this.clock.NewTicker(SCHEDULERTICK)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C():
+				for _, task := range this.tasks.due(this.clock.Elapsed()) {
+					select {
+					case jobs <- task:
+					case <-this.quit:
+						return
+					}
+				}
+			case <-this.quit:
+				return
+			}
+		}
+	}()
+}
+
+//stop the background goroutines and wait for them to exit
+func (this *TXNPool) Shutdown() {
+	close(this.quit)
+	this.workers.Wait()
+}
@@ -0,0 +1,26 @@
+package node
+
+import (
+	"IPT/core/transaction"
+	"sort"
+)
+
+//get the pooled transactions admitted after the sequence number in the order
+//of admission, and the sequence number of the last admission to poll from
+//next time. the removed transactions are not reported.
+func (this *TXNPool) TransactionsSince(seq uint64) ([]*transaction.Transaction, uint64) {
+	this.RLock()
+	defer this.RUnlock()
+	entries := []*txnEntry{}
+	for _, entry := range this.txnList {
+		if entry.seq > seq {
+			entries = append(entries, entry)
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].seq < entries[j].seq })
+	txns := make([]*transaction.Transaction, 0, len(entries))
+	for _, entry := range entries {
+		txns = append(txns, entry.txn)
+	}
+	return txns, this.admissionSeq
+}
@@ -0,0 +1,110 @@
+package node
+
+import (
+	"IPT/common"
+	"IPT/core/transaction"
+	"sort"
+	"sync"
+	"time"
+)
+
+//the transactions in the pool at a point of time
+type Snapshot struct {
+	Time   time.Time
+	Hashes []common.Uint256 // the pooled transactions in ascending order
+	view   *snapshotView
+}
+
+//the pooled transactions captured for a generation of the pool. a view is
+//never changed once captured, the snapshots of the same generation share it.
+type snapshotView struct {
+	generation uint64
+	hashes     []common.Uint256
+	txns       []*transaction.Transaction // in the order of hashes
+}
+
+//the view of the last snapshot, reused while the pool is unchanged
+type snapshotCache struct {
+	sync.Mutex
+	view *snapshotView
+}
+
+//take a snapshot of the pooled transactions, e.g. for monitoring the churn
+//between sampling intervals with DiffSnapshots. the pool lock is only held
+//to copy the pointers of the pooled transactions, they are sorted after it's
+//released, and when nothing was admitted or removed since the last snapshot
+//its view is reused. each snapshot gets its own copy of the hashes.
+func (this *TXNPool) Snapshot() Snapshot {
+	this.snapshots.Lock()
+	defer this.snapshots.Unlock()
+	this.RLock()
+	if view := this.snapshots.view; view != nil && view.generation == this.generation {
+		this.RUnlock()
+		return Snapshot{Time: this.clock.Now(), Hashes: view.copyHashes(), view: view}
+	}
+	view := &snapshotView{
+		generation: this.generation,
+		hashes:     make([]common.Uint256, 0, len(this.txnList)),
+		txns:       make([]*transaction.Transaction, 0, len(this.txnList)),
+	}
+	for hash, entry := range this.txnList {
+		view.hashes = append(view.hashes, hash)
+		view.txns = append(view.txns, entry.txn)
+	}
+	this.RUnlock()
+	sort.Sort(view)
+	this.snapshots.view = view
+	return Snapshot{Time: this.clock.Now(), Hashes: view.copyHashes(), view: view}
+}
+
+func (view *snapshotView) copyHashes() []common.Uint256 {
+	hashes := make([]common.Uint256, len(view.hashes))
+	copy(hashes, view.hashes)
+	return hashes
+}
+
+func (view *snapshotView) Len() int {
+	return len(view.hashes)
+}
+
+func (view *snapshotView) Less(i, j int) bool {
+	return view.hashes[i].CompareTo(view.hashes[j]) < 0
+}
+
+func (view *snapshotView) Swap(i, j int) {
+	view.hashes[i], view.hashes[j] = view.hashes[j], view.hashes[i]
+	view.txns[i], view.txns[j] = view.txns[j], view.txns[i]
+}
+
+//get the transactions of the snapshot in the order of Hashes, they are
+//materialized from the captured view when asked for.
+func (snapshot Snapshot) Transactions() []*transaction.Transaction {
+	if snapshot.view == nil {
+		return nil
+	}
+	txns := make([]*transaction.Transaction, len(snapshot.view.txns))
+	copy(txns, snapshot.view.txns)
+	return txns
+}
+
+//get the transactions in snapshot b but not in a, and the ones in a but not
+//in b. both are in ascending order.
+func DiffSnapshots(a, b Snapshot) (added, removed []common.Uint256) {
+	inA := make(map[common.Uint256]struct{}, len(a.Hashes))
+	for _, hash := range a.Hashes {
+		inA[hash] = struct{}{}
+	}
+	inB := make(map[common.Uint256]struct{}, len(b.Hashes))
+	for _, hash := range b.Hashes {
+		inB[hash] = struct{}{}
+		if _, ok := inA[hash]; !ok {
+			added = append(added, hash)
+		}
+	}
+	for _, hash := range a.Hashes {
+		if _, ok := inB[hash]; !ok {
+			removed = append(removed, hash)
+		}
+	}
+	return added, removed
+}
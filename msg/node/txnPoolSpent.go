@@ -0,0 +1,65 @@
+package node
+
+import (
+	"IPT/common"
+	. "IPT/common/errors"
+	"IPT/core/ledger"
+	"IPT/core/transaction"
+	"errors"
+)
+
+//tell weather the output of a confirmed transaction is unspent in the ledger
+type UnspentIndex func(txid common.Uint256, index uint16) (bool, error)
+
+//replace the index of the unspent outputs of the ledger, e.g. by a mock in tests
+func WithUnspentIndex(index UnspentIndex) TXNPoolOption {
+	return func(pool *TXNPool) {
+		pool.unspentIndex = index
+	}
+}
+
+func ledgerUnspent(txid common.Uint256, index uint16) (bool, error) {
+	if ledger.DefaultLedger == nil {
+		return false, errors.New("the ledger is not available")
+	}
+	unspent, err := ledger.DefaultLedger.Store.ContainsUnspent(txid, index)
+	//no output of the transaction is left unspent, as IsDoubleSpend of the store
+	if ledger.IsUnspentNotFound(err) {
+		return false, nil
+	}
+	return unspent, err
+}
+
+//check weather the output is spent by a pooled transaction or in the ledger.
+//the outputs of the pooled transactions are only spent in the pool, and an
+//output the ledger doesn't know is reported spent since it can't be spent.
+func (this *TXNPool) IsInputSpent(input *transaction.UTXOTxInput) (bool, error) {
+	if this.getInputUTXOList(input) != nil {
+		return true, nil
+	}
+	if producer := this.GetTransaction(input.ReferTxID); producer != nil {
+		return int(input.ReferTxOutputIndex) >= len(producer.Outputs), nil
+	}
+	unspent, err := this.unspentIndex(input.ReferTxID, input.ReferTxOutputIndex)
+	if err != nil {
+		return false, err
+	}
+	return !unspent, nil
+}
+
+//get the inputs already spent by a pooled transaction or in the ledger, e.g.
+//for a wallet to check the inputs it picked before broadcasting. an error is
+//returned when the ledger can't be read.
+func (this *TXNPool) ValidateInputsSpendable(inputs []*transaction.UTXOTxInput) (spent []*transaction.UTXOTxInput, err error) {
+	spent = []*transaction.UTXOTxInput{}
+	for _, input := range inputs {
+		isSpent, err := this.IsInputSpent(input)
+		if err != nil {
+			return nil, NewDetailErr(err, ErrNoCode, "[TXNPool], ValidateInputsSpendable failed.")
+		}
+		if isSpent {
+			spent = append(spent, input)
+		}
+	}
+	return spent, nil
+}
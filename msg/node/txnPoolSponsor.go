@@ -0,0 +1,91 @@
+package node
+
+import (
+	"IPT/common"
+	"IPT/common/config"
+	"IPT/core/transaction"
+	"sort"
+	"time"
+)
+
+//get the transactions a wallet may bump by a child paying for them, lowest
+//fee rate first. they are pooled for at least minAgeSeconds, rank below what
+//the next block holds by MaxTxInBlock, and have an output not spent by a
+//pooled transaction for the child to spend.
+func (this *TXNPool) FindSponsorshipTargets(minAgeSeconds int) []*transaction.Transaction {
+	targets := []*transaction.Transaction{}
+	blockCount := config.Parameters.MaxTxInBlock
+	if blockCount <= 0 {
+		return targets
+	}
+	minAge := time.Duration(minAgeSeconds) * time.Second
+	this.RLock()
+	defer this.RUnlock()
+	entries := this.sortedTxnList()
+	for i := len(entries) - 1; i >= blockCount; i-- {
+		entry := entries[i]
+		if this.age(entry.added) < minAge || !this.hasUnspentOutput(entry.txn) {
+			continue
+		}
+		targets = append(targets, entry.txn)
+	}
+	return targets
+}
+
+//get the transactions pooled for longer than olderThan, oldest first, e.g.
+//to alert about the transactions which may need a fee bump.
+func (this *TXNPool) StuckTransactions(olderThan time.Duration) []*transaction.Transaction {
+	this.RLock()
+	defer this.RUnlock()
+	entries := []*txnEntry{}
+	for _, entry := range this.txnList {
+		if this.age(entry.added) > olderThan {
+			entries = append(entries, entry)
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].seq < entries[j].seq })
+	stuck := make([]*transaction.Transaction, 0, len(entries))
+	for _, entry := range entries {
+		stuck = append(stuck, entry.txn)
+	}
+	return stuck
+}
+
+//get the age of the oldest pooled transaction of each sender, the program
+//hashes owning the outputs the transactions spend, e.g. for a dashboard of
+//the senders with stuck transactions. a transaction with several senders
+//counts for each of them.
+func (this *TXNPool) OldestPerSender() map[common.Uint160]time.Duration {
+	this.RLock()
+	added := make(map[*transaction.Transaction]time.Duration, len(this.txnList))
+	for _, entry := range this.txnList {
+		added[entry.txn] = entry.added
+	}
+	this.RUnlock()
+	oldest := make(map[common.Uint160]time.Duration)
+	for txn, since := range added {
+		owners, err := this.senders(txn)
+		if err != nil {
+			continue
+		}
+		age := this.age(since)
+		for owner := range owners {
+			if age > oldest[owner] {
+				oldest[owner] = age
+			}
+		}
+	}
+	return oldest
+}
+
+//check weather an output of the pooled transaction is not spent by another
+//pooled transaction, the caller must hold the lock.
+func (this *TXNPool) hasUnspentOutput(txn *transaction.Transaction) bool {
+	for i := range txn.Outputs {
+		input := &transaction.UTXOTxInput{ReferTxID: txn.Hash(), ReferTxOutputIndex: uint16(i)}
+		if _, ok := this.inputUTXOList[*input]; !ok {
+			return true
+		}
+	}
+	return false
+}
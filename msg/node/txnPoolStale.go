@@ -0,0 +1,89 @@
+package node
+
+import (
+	"IPT/common/config"
+	. "IPT/common/errors"
+	"IPT/common/log"
+	"IPT/core/transaction"
+	"fmt"
+	"time"
+)
+
+const (
+	STALECHECKINTERVAL = 10 * time.Second // how often the gap since the last block is checked
+	STALEREVALIDATE    = "revalidate"     // verify the pooled transactions with the ledger again
+	STALECLEAR         = "clear"          // remove all the pooled transactions
+)
+
+func staleCheckInterval() time.Duration {
+	if config.Parameters.MaxBlockGap == 0 {
+		return 0
+	}
+	return STALECHECKINTERVAL
+}
+
+//record a block was committed
+func (this *TXNPool) blockCommitted() {
+	this.Lock()
+	defer this.Unlock()
+	this.lastBlockTime = this.clock.Elapsed()
+}
+
+//when no block was committed for MaxBlockGap seconds the node may be stalled
+//or partitioned, and the state the pooled transactions depend on may have
+//changed. they are revalidated or cleared according to StalePoolAction.
+func (this *TXNPool) checkStale() {
+	gap := time.Duration(config.Parameters.MaxBlockGap) * time.Second
+	if gap == 0 {
+		return
+	}
+	this.RLock()
+	since := this.age(this.lastBlockTime)
+	this.RUnlock()
+	if since < gap {
+		return
+	}
+	log.Info(fmt.Sprintf("No block committed for %v, %s the transaction pool", since, config.Parameters.StalePoolAction))
+	this.commitLock.Lock()
+	switch config.Parameters.StalePoolAction {
+	case STALECLEAR:
+		this.clearTransactions()
+	default:
+		this.revalidateTransactions()
+	}
+	this.commitLock.Unlock()
+	//wait another gap before acting again
+	this.blockCommitted()
+}
+
+//remove all the pooled transactions, the caller must hold the commit lock.
+func (this *TXNPool) clearTransactions() {
+	for {
+		var txn *transaction.Transaction
+		this.RLock()
+		for _, entry := range this.txnList {
+			txn = entry.txn
+			break
+		}
+		this.RUnlock()
+		if txn == nil {
+			return
+		}
+		this.removeWithDescendants(txn)
+	}
+}
+
+//remove the pooled transactions failing the verification with the ledger,
+//the caller must hold the commit lock.
+func (this *TXNPool) revalidateTransactions() {
+	for _, txn := range this.copytxnList() {
+		if this.GetTransaction(txn.Hash()) == nil {
+			continue
+		}
+		if errCode := this.ledgerVerifierOf(txn, true)(txn); errCode != ErrNoError {
+			for _, removed := range this.removeWithDescendants(txn) {
+				log.Info(fmt.Sprintf("Transaction =%x removed by revalidation, %v", removed.Hash(), errCode))
+			}
+		}
+	}
+}
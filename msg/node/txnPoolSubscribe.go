@@ -0,0 +1,92 @@
+package node
+
+import (
+	"IPT/core/transaction"
+	"sync"
+)
+
+//what a subscription does with a new transaction when its buffer is full
+type BackpressurePolicy int
+
+const (
+	DROPNEWEST    BackpressurePolicy = iota // drop the new transaction
+	DROPOLDEST                              // drop the oldest buffered transaction to make room
+	BLOCKWHENFULL                           // wait for the subscriber to make room, see Subscribe
+)
+
+type txnSubscriber struct {
+	txns   chan *transaction.Transaction
+	policy BackpressurePolicy
+	done   chan struct{} // closed when unsubscribed, releases a blocked send
+}
+
+//the subscribers to the admitted transactions
+type txnSubscriptions struct {
+	sync.Mutex
+	subscribers map[*txnSubscriber]struct{}
+}
+
+//subscribe to the transactions admitted to the pool, in the order they are
+//admitted. up to buffer transactions wait for the subscriber, the policy
+//decides what happens when it's slower than the admissions. BLOCKWHENFULL
+//never loses a transaction but stalls all the admissions until the
+//subscriber makes room, the subscriber must not admit transactions to the
+//pool while it's not reading. the returned function unsubscribes and closes
+//the channel.
+func (this *TXNPool) Subscribe(buffer int, policy BackpressurePolicy) (<-chan *transaction.Transaction, func()) {
+	if buffer < 1 {
+		buffer = 1
+	}
+	sub := &txnSubscriber{
+		txns:   make(chan *transaction.Transaction, buffer),
+		policy: policy,
+		done:   make(chan struct{}),
+	}
+	this.subscriptions.Lock()
+	this.subscriptions.subscribers[sub] = struct{}{}
+	this.subscriptions.Unlock()
+	var once sync.Once
+	return sub.txns, func() {
+		once.Do(func() {
+			close(sub.done)
+			this.subscriptions.Lock()
+			delete(this.subscriptions.subscribers, sub)
+			this.subscriptions.Unlock()
+			close(sub.txns)
+		})
+	}
+}
+
+//send the admitted transaction to the subscribers, the caller must hold the
+//commit lock so they get the transactions in the order of admission.
+func (this *TXNPool) publish(txn *transaction.Transaction) {
+	this.subscriptions.Lock()
+	defer this.subscriptions.Unlock()
+	for sub := range this.subscriptions.subscribers {
+		sub.send(txn)
+	}
+}
+
+func (sub *txnSubscriber) send(txn *transaction.Transaction) {
+	select {
+	case sub.txns <- txn:
+		return
+	default:
+	}
+	switch sub.policy {
+	case DROPOLDEST:
+		select {
+		case <-sub.txns:
+		default:
+		}
+		select {
+		case sub.txns <- txn:
+		default:
+		}
+	case BLOCKWHENFULL:
+		select {
+		case sub.txns <- txn:
+		case <-sub.done:
+		}
+	}
+}
@@ -0,0 +1,36 @@
+package node
+
+import (
+	"IPT/common"
+	"IPT/core/transaction"
+)
+
+//attach an opaque tag to a pooled transaction, e.g. where it was submitted from.
+//the tags are dropped when the transaction leaves the pool.
+func (this *TXNPool) TagTransaction(hash common.Uint256, tag string) bool {
+	this.Lock()
+	defer this.Unlock()
+	if _, ok := this.txnList[hash]; !ok {
+		return false
+	}
+	tags, ok := this.tagList[hash]
+	if !ok {
+		tags = make(map[string]struct{})
+		this.tagList[hash] = tags
+	}
+	tags[tag] = struct{}{}
+	return true
+}
+
+//get the pooled transactions with the tag
+func (this *TXNPool) GetTransactionsByTag(tag string) []*transaction.Transaction {
+	this.RLock()
+	defer this.RUnlock()
+	txns := []*transaction.Transaction{}
+	for hash, tags := range this.tagList {
+		if _, ok := tags[tag]; ok {
+			txns = append(txns, this.txnList[hash].txn)
+		}
+	}
+	return txns
+}
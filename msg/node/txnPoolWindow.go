@@ -0,0 +1,94 @@
+package node
+
+import (
+	. "IPT/common/errors"
+	"sync"
+	"time"
+)
+
+const (
+	STATSBUCKET    = time.Second // the events are counted per bucket of this duration
+	STATSRETENTION = time.Hour   // the longest window of WindowStats
+)
+
+//the activity of the pool over a trailing window
+type WindowStats struct {
+	Window        time.Duration
+	Admissions    uint64             // transactions admitted by AppendTxnPool or reinserted
+	Rejections    map[ErrCode]uint64 // AppendTxnPool errors by reason
+	Evictions     uint64             // transactions evicted to fit the size limits
+	Confirmations uint64             // pooled transactions included by the committed blocks
+}
+
+//the events of one STATSBUCKET
+type statsBucket struct {
+	start         time.Duration // clock.Elapsed at the start of the bucket
+	admissions    uint64
+	rejections    map[ErrCode]uint64
+	evictions     uint64
+	confirmations uint64
+}
+
+//counters of the events by time, kept for STATSRETENTION
+type eventWindow struct {
+	sync.Mutex
+	buckets []*statsBucket // oldest first
+}
+
+//count an event at the time in its bucket, dropping the buckets older than
+//STATSRETENTION.
+func (w *eventWindow) record(now time.Duration, count func(*statsBucket)) {
+	w.Lock()
+	defer w.Unlock()
+	start := now - now%STATSBUCKET
+	n := len(w.buckets)
+	if n == 0 || w.buckets[n-1].start != start {
+		w.buckets = append(w.buckets, &statsBucket{start: start, rejections: make(map[ErrCode]uint64)})
+	}
+	count(w.buckets[len(w.buckets)-1])
+	for len(w.buckets) > 0 && w.buckets[0].start+STATSRETENTION < start {
+		w.buckets = w.buckets[1:]
+	}
+}
+
+func (w *eventWindow) admitted(now time.Duration) {
+	w.record(now, func(b *statsBucket) { b.admissions++ })
+}
+
+func (w *eventWindow) rejected(now time.Duration, errCode ErrCode) {
+	w.record(now, func(b *statsBucket) { b.rejections[errCode]++ })
+}
+
+func (w *eventWindow) evicted(now time.Duration, n int) {
+	w.record(now, func(b *statsBucket) { b.evictions += uint64(n) })
+}
+
+func (w *eventWindow) confirmed(now time.Duration, n int) {
+	w.record(now, func(b *statsBucket) { b.confirmations += uint64(n) })
+}
+
+//get the admissions, the rejections by reason, the evictions and the
+//confirmations of the trailing window, e.g. for the rates of a dashboard. the
+//events are counted per STATSBUCKET so the window starts at a bucket
+//boundary, and it's capped to STATSRETENTION.
+func (this *TXNPool) WindowStats(window time.Duration) WindowStats {
+	if window > STATSRETENTION {
+		window = STATSRETENTION
+	}
+	now := this.clock.Elapsed()
+	stats := WindowStats{Window: window, Rejections: make(map[ErrCode]uint64)}
+	this.events.Lock()
+	defer this.events.Unlock()
+	for _, b := range this.events.buckets {
+		if b.start < now-window {
+			continue
+		}
+		stats.Admissions += b.admissions
+		stats.Evictions += b.evictions
+		stats.Confirmations += b.confirmations
+		for errCode, n := range b.rejections {
+			stats.Rejections[errCode] += n
+		}
+	}
+	return stats
+}
@@ -102,6 +102,8 @@ type Noder interface {
 	GetTxnPool(bool) map[common.Uint256]*transaction.Transaction
 	AppendTxnPool(*transaction.Transaction, bool) ErrCode
 	ExistedID(id common.Uint256) bool
+	WasRecentlyConfirmed(hash common.Uint256) bool
+	ShouldRelay(txn *transaction.Transaction) bool
 	ReqNeighborList()
 	DumpInfo()
 	UpdateInfo(t time.Time, version uint32, services uint64,
@@ -3,6 +3,7 @@ package rpc
 import (
 	. "IPT/common/config"
 	"IPT/common/log"
+	"IPT/common/metrics"
 	"net/http"
 	"strconv"
 )
@@ -14,6 +15,7 @@ const (
 func StartRPCServer() {
 	log.Debug()
 	http.HandleFunc("/", Handle)
+	http.HandleFunc("/metrics", handleMetrics)
 
 	HandleFunc("getbestblockhash", getBestBlockHash)
 	HandleFunc("getblock", getBlock)
@@ -38,3 +40,9 @@ func StartRPCServer() {
 		log.Fatal("ListenAndServe: ", err.Error())
 	}
 }
+
+//serve the registered metrics in the text exposition format
+func handleMetrics(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	metrics.WriteText(w, metrics.Gather())
+}
@@ -276,6 +276,10 @@ func VerifyAndSendTx(txn *tx.Transaction) ErrCode {
 		log.Info("[httpjsonrpc] VerifyTransaction failed when AppendTxnPool.")
 		return errCode
 	}
+	if !node.ShouldRelay(txn) {
+		log.Info("Transaction below the min relay fee kept in TxnPool without relay", txn.Hash())
+		return ErrNoError
+	}
 	if err := node.Xmit(txn); err != nil {
 		log.Error("Xmit Tx Error:Xmit transaction failed.", err)
 		return ErrXmitFail